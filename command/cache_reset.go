@@ -0,0 +1,68 @@
+package command
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"g-tech.com/constant"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/bootstrap"
+	"g-tech.com/module/minigame/service"
+	"time"
+)
+
+/*
+	Reset a cache family, waits for keys of older versions to be purged
+ */
+func cacheReset(args []string) error {
+	flagSet := newFlagSet("cache reset")
+	family := flagSet.String("family", "", "cache family, or All")
+	userId := flagSet.String("user-id", "", "reset keys of a single user, empty for every user")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *family == "" {
+		flagSet.Usage()
+		return errors.New("--family is required")
+	}
+	err = setUp(`Log.PrefixCommand`)
+	if err != nil {
+		return err
+	}
+	timeout := bootstrap.Timeout()
+
+	cacheManager, err := bootstrap.ConnectRedis()
+	if err != nil {
+		return err
+	}
+	cacheService := service.NewCacheService(cacheManager, timeout)
+
+	reset, errorCode, err := cacheService.ResetCache(context.Background(), dto.CacheReset{Family: *family, UserId: *userId})
+	if err != nil {
+		return err
+	}
+	if errorCode != 0 {
+		return errors.New(gerror.T(errorCode))
+	}
+
+	// The purge runs in this process, it stops with it
+	for reset.Status == constant.StatusCacheResetRunning {
+		time.Sleep(time.Second)
+		reset, errorCode, err = cacheService.GetCacheReset(context.Background(), reset.Id)
+		if err != nil {
+			return err
+		}
+		if errorCode != 0 {
+			return errors.New(gerror.T(errorCode))
+		}
+		fmt.Printf("Scanned %d, deleted %d\n", reset.Scanned, reset.Deleted)
+	}
+
+	fmt.Printf("%s: %s, deleted %d keys\n", reset.Family, reset.Status, reset.Deleted)
+	if reset.Status == constant.StatusCacheResetFailed {
+		return errors.New(reset.Error)
+	}
+	return nil
+}
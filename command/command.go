@@ -0,0 +1,98 @@
+package command
+
+import (
+	"flag"
+	"fmt"
+	"g-tech.com/infrastructure/bootstrap"
+	"os"
+	"strings"
+)
+
+/*
+	A subcommand of the binary, Name may have several words (e.g. "cache reset")
+ */
+type command struct {
+	Name		string
+	Usage		string
+	Run			func(args []string) error
+}
+
+var commands = []command{
+	{Name: "serve", Usage: "Run the api", Run: serve},
+	{Name: "consume-lottery", Usage: "Consume daily lottery results and pay winners", Run: consumeLottery},
+	{Name: "schedule", Usage: "Run scheduled jobs", Run: schedule},
+	{Name: "migrate", Usage: "Apply schema changes", Run: migrate},
+	{Name: "cache reset", Usage: "Reset a cache family, of every user or a single one", Run: cacheReset},
+	{Name: "import-cards", Usage: "Import mobile cards from a CSV file", Run: importCards},
+	{Name: "settle-lottery", Usage: "Pay winners of a lottery date", Run: settleLottery},
+}
+
+var configPath string
+
+/*
+	Run the subcommand in args, returns the exit code
+	--config is accepted before or after the subcommand
+ */
+func Execute(args []string) int {
+	global := flag.NewFlagSet("minigame", flag.ContinueOnError)
+	global.StringVar(&configPath, "config", "config.json", "configuration file")
+	global.Usage = usage
+	err := global.Parse(args)
+	if err != nil {
+		return exitCode(err)
+	}
+
+	args = global.Args()
+	for _, c := range commands {
+		words := strings.Fields(c.Name)
+		if len(args) < len(words) || strings.Join(args[:len(words)], " ") != c.Name {
+			continue
+		}
+
+		err = c.Run(args[len(words):])
+		if err != nil && err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", c.Name, err.Error())
+		}
+		return exitCode(err)
+	}
+
+	usage()
+	return 2
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: minigame [--config config.json] <command> [flags]\n\nCommands:\n")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", c.Name, c.Usage)
+	}
+	fmt.Fprintf(os.Stderr, "\nRun minigame <command> --help for the flags of a command\n")
+}
+
+func exitCode(err error) int {
+	switch err {
+	case nil, flag.ErrHelp:
+		return 0
+	}
+	return 1
+}
+
+/*
+	Flags of a subcommand, --config included
+ */
+func newFlagSet(name string) *flag.FlagSet {
+	flagSet := flag.NewFlagSet(name, flag.ContinueOnError)
+	flagSet.StringVar(&configPath, "config", configPath, "configuration file")
+	return flagSet
+}
+
+/*
+	Load configuration and open the log, file names start with the value of logPrefixKey
+ */
+func setUp(logPrefixKey string) error {
+	err := bootstrap.LoadConfig(configPath)
+	if err != nil {
+		return err
+	}
+	bootstrap.NewLogger(logPrefixKey)
+	return nil
+}
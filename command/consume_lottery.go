@@ -0,0 +1,52 @@
+package command
+
+import (
+	"g-tech.com/infrastructure/bootstrap"
+	"g-tech.com/migration"
+	"g-tech.com/module/lottery"
+)
+
+/*
+	Consume lottery results until SIGTERM, the result being summarized is acked before exit
+ */
+func consumeLottery(args []string) error {
+	err := newFlagSet("consume-lottery").Parse(args)
+	if err != nil {
+		return err
+	}
+	err = setUp(`Log.PrefixLottery`)
+	if err != nil {
+		return err
+	}
+	timeout := bootstrap.Timeout()
+
+	// Connected once modules are initialized, reconnects until the process stops
+	rbClient, err := bootstrap.NewBroker()
+	if err != nil {
+		return err
+	}
+
+	dbContext, err := bootstrap.ConnectMySql()
+	if err != nil {
+		return err
+	}
+	defer dbContext.Close()
+
+	// Refuse to run against a schema missing migrations of this build
+	err = migration.NewMigrator(dbContext).Check()
+	if err != nil {
+		return err
+	}
+
+	cacheManager, err := bootstrap.ConnectRedis()
+	if err != nil {
+		return err
+	}
+
+	lottery.Initialize(rbClient, dbContext, cacheManager, timeout)
+	bootstrap.StartBroker(rbClient)
+
+	<-bootstrap.WaitForSignal()
+	rbClient.Close()
+	return nil
+}
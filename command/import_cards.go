@@ -0,0 +1,91 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"g-tech.com/dto"
+	"g-tech.com/infrastructure/bootstrap"
+	"g-tech.com/infrastructure/util"
+	"g-tech.com/module/minigame/service"
+	"os"
+	"strconv"
+	"strings"
+)
+
+var mobileCardColumns = []string{"VendorCode", "Serial", "Code", "Value"}
+
+/*
+	Import mobile cards into stock from a CSV file with columns VendorCode, Serial, Code, Value
+	Nothing is imported when a row is invalid
+ */
+func importCards(args []string) error {
+	flagSet := newFlagSet("import-cards")
+	file := flagSet.String("file", "", "CSV file of the cards")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *file == "" {
+		flagSet.Usage()
+		return errors.New("--file is required")
+	}
+	err = setUp(`Log.PrefixCommand`)
+	if err != nil {
+		return err
+	}
+	timeout := bootstrap.Timeout()
+
+	csvFile, err := os.Open(*file)
+	if err != nil {
+		return err
+	}
+	defer csvFile.Close()
+
+	records, err := util.ReadCSVRecords(csvFile, mobileCardColumns)
+	if err != nil {
+		return err
+	}
+
+	// Rows with an invalid value are reported by the service along with the other errors
+	mobileCards := make([]dto.MobileCard, len(records))
+	for i, record := range records {
+		value, _ := strconv.Atoi(strings.TrimSpace(record["value"]))
+		mobileCards[i] = dto.MobileCard{
+			VendorCode:	strings.TrimSpace(record["vendorcode"]),
+			Serial:		strings.TrimSpace(record["serial"]),
+			Code:		strings.TrimSpace(record["code"]),
+			Value:		value,
+		}
+	}
+
+	dbContext, err := bootstrap.ConnectMySql()
+	if err != nil {
+		return err
+	}
+	defer dbContext.Close()
+
+	cacheManager, err := bootstrap.ConnectRedis()
+	if err != nil {
+		return err
+	}
+
+	redisService := service.NewRedisService(dbContext, cacheManager, timeout)
+	configService := service.NewConfigService(dbContext, cacheManager, redisService, timeout)
+	outboxService := service.NewOutboxService(dbContext, redisService, timeout)
+	walletService := service.NewWalletService(dbContext, cacheManager, redisService, configService, outboxService, timeout)
+	mobileCardService := service.NewMobileCardService(dbContext, cacheManager, redisService, configService, walletService, outboxService, timeout)
+
+	result, err := mobileCardService.ImportMobileCards(context.Background(), mobileCards)
+	if err != nil {
+		return err
+	}
+
+	output, _ := json.MarshalIndent(result, "", "  ")
+	fmt.Println(string(output))
+	if len(result.Errors) > 0 {
+		return fmt.Errorf("%d invalid rows, nothing imported", len(result.Errors))
+	}
+	return nil
+}
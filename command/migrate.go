@@ -0,0 +1,96 @@
+package command
+
+import (
+	"errors"
+	"fmt"
+	"g-tech.com/infrastructure/bootstrap"
+	"g-tech.com/migration"
+	"strings"
+)
+
+const migrateUsage = `migrate [up|down|status|baseline] [--to version]
+  up        apply pending migrations, up to --to (default latest)
+  down      revert applied migrations above --to (required)
+  status    list migrations with the time they were applied
+  baseline  record migrations up to --to (default latest) as applied without running them`
+
+/*
+	Manage the schema, migrations are part of the build (package migration)
+ */
+func migrate(args []string) error {
+	action := "up"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		action, args = args[0], args[1:]
+	}
+
+	switch action {
+	case "up", "down", "status", "baseline":
+	default:
+		fmt.Println(migrateUsage)
+		return errors.New("Unknown action " + action)
+	}
+
+	flagSet := newFlagSet("migrate " + action)
+	target := flagSet.Int("to", -1, "target version")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *target < 0 && action != "down" {
+		*target = migration.Latest()
+	}
+	if *target < 0 || *target > migration.Latest() {
+		return fmt.Errorf("--to must be between 0 and %d", migration.Latest())
+	}
+
+	err = setUp(`Log.PrefixCommand`)
+	if err != nil {
+		return err
+	}
+
+	dbContext, err := bootstrap.ConnectMySql()
+	if err != nil {
+		return err
+	}
+	defer dbContext.Close()
+
+	migrator := migration.NewMigrator(dbContext)
+	switch action {
+	case "up":
+		err = migrator.Up(*target, printMigration("Applied"))
+	case "down":
+		err = migrator.Down(*target, printMigration("Reverted"))
+	case "baseline":
+		err = migrator.Baseline(*target, printMigration("Recorded"))
+	case "status":
+		err = printMigrationStatus(migrator)
+	}
+	if err != nil {
+		return err
+	}
+	if action != "status" {
+		fmt.Println("Done")
+	}
+	return nil
+}
+
+func printMigration(verb string) func(migration.Migration) {
+	return func(applied migration.Migration) {
+		fmt.Printf("%s %03d_%s\n", verb, applied.Version, applied.Name)
+	}
+}
+
+func printMigrationStatus(migrator *migration.Migrator) error {
+	listStatus, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+	for _, status := range listStatus {
+		appliedAt := "pending"
+		if status.AppliedAt != nil {
+			appliedAt = status.AppliedAt.Format("2006-01-02 15:04:05")
+		}
+		fmt.Printf("%03d_%-28s %s\n", status.Version, status.Name, appliedAt)
+	}
+	return nil
+}
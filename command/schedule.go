@@ -0,0 +1,52 @@
+package command
+
+import (
+	"g-tech.com/infrastructure/bootstrap"
+	"g-tech.com/migration"
+	"g-tech.com/module/scheduler"
+)
+
+/*
+	Run scheduled jobs until SIGTERM, running jobs are completed before exit
+ */
+func schedule(args []string) error {
+	err := newFlagSet("schedule").Parse(args)
+	if err != nil {
+		return err
+	}
+	err = setUp(`Log.PrefixScheduler`)
+	if err != nil {
+		return err
+	}
+	timeout := bootstrap.Timeout()
+
+	dbContext, err := bootstrap.ConnectMySql()
+	if err != nil {
+		return err
+	}
+	defer dbContext.Close()
+
+	// Refuse to run against a schema missing migrations of this build
+	err = migration.NewMigrator(dbContext).Check()
+	if err != nil {
+		return err
+	}
+
+	// Not fatal, jobs needing a lock are skipped until Redis is back
+	cacheManager, err := bootstrap.ConnectRedis()
+	if err != nil {
+		return err
+	}
+
+	// Not fatal, outbox messages stay pending until RabbitMQ is back
+	rbClient, err := bootstrap.NewBroker()
+	if err != nil {
+		return err
+	}
+	bootstrap.StartBroker(rbClient)
+	defer rbClient.Close()
+
+	scheduler.Initialize(rbClient, dbContext, cacheManager, timeout)
+	scheduler.Execute(bootstrap.WaitForSignal())
+	return nil
+}
@@ -0,0 +1,83 @@
+package command
+
+import (
+	"context"
+	"g-tech.com/infrastructure/bootstrap"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/migration"
+	"g-tech.com/module/healthcheck"
+	"g-tech.com/module/minigame"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/middleware"
+	"github.com/spf13/viper"
+	"net/http"
+)
+
+/*
+	Run the api until SIGTERM, requests in progress are completed
+ */
+func serve(args []string) error {
+	err := newFlagSet("serve").Parse(args)
+	if err != nil {
+		return err
+	}
+	err = setUp(`Log.PrefixMiniGame`)
+	if err != nil {
+		return err
+	}
+	timeout := bootstrap.Timeout()
+
+	dbContext, err := bootstrap.ConnectMySql()
+	if err != nil {
+		return err
+	}
+	defer dbContext.Close()
+
+	// Refuse to run against a schema missing migrations of this build
+	err = migration.NewMigrator(dbContext).Check()
+	if err != nil {
+		return err
+	}
+
+	cacheManager, err := bootstrap.ConnectRedis()
+	if err != nil {
+		return err
+	}
+
+	/********************************************************************/
+	/* CONFIGURE ECHO													*/
+	/********************************************************************/
+	e := echo.New()
+	e.Server.SetKeepAlivesEnabled(false)
+	e.Server.ReadTimeout = timeout
+	e.Server.WriteTimeout = timeout
+	e.Use(middleware.CORS())
+
+	/********************************************************************/
+	/* INITIALIZE MODULES												*/
+	/********************************************************************/
+	minigame.Initialize(e, dbContext, cacheManager, timeout)
+	healthcheck.Initialize(e, dbContext, timeout)
+
+	failed := make(chan error, 1)
+	go func() {
+		err := e.Start(viper.GetString("Server.Address"))
+		if err != nil && err != http.ErrServerClosed {
+			failed <- err
+		}
+	}()
+
+	select {
+	case err = <-failed:
+		return err
+	case <-bootstrap.WaitForSignal():
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	err = e.Shutdown(ctx)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package command
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/bootstrap"
+	"g-tech.com/module/lottery"
+	"io/ioutil"
+)
+
+/*
+	Settle a date whose result was not consumed, e.g. while the feed was down
+	Winners already paid are not paid again, --dry-run lists winners without paying them
+ */
+func settleLottery(args []string) error {
+	flagSet := newFlagSet("settle-lottery")
+	date := flagSet.String("date", "", "date of the lottery, YYYY-MM-DD")
+	special := flagSet.String("special", "", "special prize of the result")
+	resultFile := flagSet.String("result", "", "JSON file of the result, instead of --special")
+	dryRun := flagSet.Bool("dry-run", false, "list winners without paying them")
+	settledBy := flagSet.String("settled-by", "", "admin settling the date")
+	err := flagSet.Parse(args)
+	if err != nil {
+		return err
+	}
+	if *date == "" || (*special == "") == (*resultFile == "") {
+		flagSet.Usage()
+		return errors.New("--date and one of --special or --result are required")
+	}
+
+	settlement := dto.LotterySettlement{
+		Date:		*date,
+		Result:		dto.LotteryResult{Special: *special},
+		DryRun:		*dryRun,
+		SettledBy:	*settledBy,
+	}
+	if *resultFile != "" {
+		content, err := ioutil.ReadFile(*resultFile)
+		if err != nil {
+			return err
+		}
+		err = json.Unmarshal(content, &settlement.Result)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = setUp(`Log.PrefixLottery`)
+	if err != nil {
+		return err
+	}
+	timeout := bootstrap.Timeout()
+
+	dbContext, err := bootstrap.ConnectMySql()
+	if err != nil {
+		return err
+	}
+	defer dbContext.Close()
+
+	cacheManager, err := bootstrap.ConnectRedis()
+	if err != nil {
+		return err
+	}
+
+	summaryService := lottery.NewSummaryService(dbContext, cacheManager, timeout)
+	settlement, errorCode, err := summaryService.SettleLottery(context.Background(), settlement)
+	if err != nil {
+		return err
+	}
+	if errorCode != 0 {
+		return errors.New(gerror.T(errorCode))
+	}
+
+	output, _ := json.MarshalIndent(settlement, "", "  ")
+	fmt.Println(string(output))
+	return nil
+}
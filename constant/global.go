@@ -1,8 +1,11 @@
 package constant
 
+import "time"
+
 const(
 	ProgramInvitingName					string = "Inviting"
 	ProgramInvitedName					string = "Invited"
+	ProgramInvitingSecondLevelName		string = "InvitingSecondLevel"
 	ProgramReadHITDaily					string = "ReadHITDaily"
 	ProgramLotteryWinFirstPrize			string = "LotteryWinFirstPrize"
 	ProgramLotteryWinAnyPrize			string = "LotteryWinAnyPrize"
@@ -12,17 +15,52 @@ const(
 	ProgramExchangeMobileCard100		string = "ExchangeMobileCard100"
 	ProgramExchangeMobileCard200		string = "ExchangeMobileCard200"
 	ProgramExchangeMobileCard500		string = "ExchangeMobileCard500"
-
-
-	RedisPrefixKeyAllTransaction		string = "hitvn_bk_minigame_v1_transaction_all_"
-	RedisPrefixKeyBoughtMobileCards		string = "hitvn_bk_minigame_v1_bought_mobile_card_"
+	ProgramCoinExpired					string = "CoinExpired"
+	ProgramAdminAdjustment				string = "AdminAdjustment"
+	ProgramTransferSent					string = "TransferSent"
+	ProgramTransferReceived				string = "TransferReceived"
+
+
+	RedisPrefixKeyAllTransaction		string = "hitvn_bk_minigame_v1_transactions_all_"			// Sorted set, scored by date
+	RedisPrefixKeyReceivedTransaction	string = "hitvn_bk_minigame_v1_transactions_received_"		// Sorted set, scored by date
+	RedisPrefixKeyUsedTransaction		string = "hitvn_bk_minigame_v1_transactions_used_"			// Sorted set, scored by date
+	RedisPrefixKeyBoughtMobileCards		string = "hitvn_bk_minigame_v1_bought_mobile_cards_"		// Sorted set, scored by date
+	RedisPrefixKeyLegacyAllTransaction	string = "hitvn_bk_minigame_v1_transaction_all_"			// JSON blob, replaced by sorted sets
+	RedisPrefixKeyLegacyBoughtCards		string = "hitvn_bk_minigame_v1_bought_mobile_card_"		// JSON blob, replaced by sorted sets
 	RedisPrefixKeyAllPrize				string = "hitvn_bk_minigame_v1_all_prize"
 	RedisPrefixKeyAllVendor				string = "hitvn_bk_minigame_v1_all_vendor"
 	RedisPrefixKeyUserWallet			string = "hitvn_bk_minigame_v1_user_wallet_"
+	RedisPrefixKeyReferralLeaderboard	string = "hitvn_bk_minigame_v1_referral_leaderboard_"
+	RedisPrefixKeyCacheReset			string = "hitvn_bk_minigame_v1_cache_reset_"
+	RedisPrefixKeyJobLock				string = "hitvn_bk_minigame_v1_scheduler_lock_"
+	RedisPrefixKeyJobTick				string = "hitvn_bk_minigame_v1_scheduler_tick_"
+
+	ReferralLeaderboardRetention		time.Duration = 5 * 7 * 24 * time.Hour
+
+	// Cache key families, TTL of each one is configured in Cache.TTLSeconds
+	CacheFamilyTransaction				string = "Transaction"
+	CacheFamilyBoughtMobileCard			string = "BoughtMobileCard"
+	CacheFamilyPrize					string = "Prize"
+	CacheFamilyVendor					string = "Vendor"
+	CacheFamilyUserWallet				string = "UserWallet"
+	CacheFamilyAll						string = "All"
+
+	CacheResetRetention					time.Duration = 24 * time.Hour
 
 	// RabbitMQ
 	RbSuperExchange						string = "super_exchange"
 	RbRouteResult 						string = "minigame.result.daily.lottery"
+	RbRouteWalletCredited				string = "minigame.wallet.credited"
+	RbRouteCardRedeemed					string = "minigame.card.redeemed"
+	RbRouteInvitationCreated			string = "minigame.invitation.created"
+
+	// Domain events (outbox) and their version, bumped on breaking changes of payload
+	EventWalletCredited					string = "WalletCredited"
+	EventWalletCreditedVersion			int = 1
+	EventCardRedeemed					string = "CardRedeemed"
+	EventCardRedeemedVersion			int = 1
+	EventInvitationCreated				string = "InvitationCreated"
+	EventInvitationCreatedVersion		int = 1
 
 	/*
 		STATUS FOR API
@@ -66,10 +104,100 @@ const(
 	StatusMobileCardVendorNotActive			int = 0
 	StatusMobileCardVendorActive			int = 1
 
+	/*
+		Invitation
+	 */
+	// Reward status of inviting user (game_inviting)
+	StatusInvitingPending					int = 0		// Waiting for invited user activity
+	StatusInvitingSettled					int = 1
+	StatusInvitingExpired					int = 2
+	StatusInvitingOnHold					int = 3		// Waiting for fraud review
+	StatusInvitingRejected					int = 4
+
+	StatusInvitationCampaignNotActive		int = 0
+	StatusInvitationCampaignActive			int = 1
+
+	/*
+		Invitation Fraud Review
+	 */
+	StatusInvitationReviewPending			int = 0
+	StatusInvitationReviewAccepted			int = 1		// Low score, rewards paid immediately
+	StatusInvitationReviewApproved			int = 2
+	StatusInvitationReviewRejected			int = 3
+
+	/*
+		Cache reset
+	 */
+	StatusCacheResetRunning					string = "Running"
+	StatusCacheResetDone					string = "Done"
+	StatusCacheResetFailed					string = "Failed"
+
+	/*
+		Scheduled job run
+	 */
+	StatusJobRunQueued						string = "Queued"
+	StatusJobRunRunning						string = "Running"
+	StatusJobRunSucceeded					string = "Succeeded"
+	StatusJobRunFailed						string = "Failed"
+	StatusJobRunSkipped						string = "Skipped"
+
+	JobTriggerSchedule						string = "Schedule"
+	JobTriggerManual						string = "Manual"
+
+	/*
+		Scheduled jobs
+	 */
+	JobSettleReferralRewards				string = "SettleReferralRewards"
+	JobExpireCredits						string = "ExpireCredits"
+	JobRelayOutbox							string = "RelayOutbox"
+	JobPurgeOutbox							string = "PurgeOutbox"
+	JobWarmUpCache							string = "WarmUpCache"
+	JobAlertMobileCardStock					string = "AlertMobileCardStock"
+	JobPurgeJobRuns							string = "PurgeJobRuns"
+
+	/*
+		Lottery settlement
+	 */
+	LotterySourceFeed						string = "Feed"			// Result consumed from RabbitMQ
+	LotterySourceManual						string = "Manual"
+
+	/*
+		Outbox message
+	 */
+	StatusOutboxPending						int = 0
+	StatusOutboxProcessed					int = 1
+	StatusOutboxFailed						int = 2		// MaxAttempts reached
+
+	/*
+		Transaction reference
+	 */
+	TransactionReferenceMobileCard			string = "MobileCard"
+	TransactionReferenceInvitation			string = "Invitation"
+	TransactionReferenceLottery				string = "Lottery"		// Reference id is the draw date
+	TransactionReferenceReadDaily			string = "ReadDaily"
+	TransactionReferenceTransfer			string = "Transfer"
+	TransactionReferenceAdjustment			string = "Adjustment"
+
+	DefaultTransactionPageSize				int = 20
+	MaximumTransactionPageSize				int = 100
+
+	/*
+		Paging of lists (pageSize, pageIndex)
+	 */
+	DefaultPageSize							int = 20
+	MaximumPageSize							int = 100
+
+	/*
+		Id of the user authenticated by the gateway
+	 */
+	HeaderUserId							string = "X-User-Id"
+
 	/*
 		Date format
 	 */
 	DateTimeLayout							string = "02/01/2006"
+	DateLayout								string = "2006-01-02"		// Date of query parameters
+	CampaignDateLayout						string = "2006-01-02 15:04:05"	// StartDate, EndDate of campaign
 
 
 )
\ No newline at end of file
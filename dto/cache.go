@@ -0,0 +1,13 @@
+package dto
+
+type CacheReset struct {
+	Id					string		`json:"Id"`
+	Family				string		`json:"Family"`		// A cache family or All
+	UserId				string		`json:"UserId"`		// Empty: every user
+	Status				string		`json:"Status"`
+	Scanned				int64		`json:"Scanned"`
+	Deleted				int64		`json:"Deleted"`
+	Error				string		`json:"Error"`
+	StartedAt			string		`json:"StartedAt"`
+	FinishedAt			string		`json:"FinishedAt"`
+}
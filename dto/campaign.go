@@ -0,0 +1,26 @@
+package dto
+
+type InvitationCampaign struct {
+	Id							string	`json:"Id"`
+	Name						string	`json:"Name"`
+	Code						string	`json:"Code"`			// Empty: applies to every invitation
+	StartDate					string	`json:"StartDate"`		// yyyy-MM-dd HH:mm:ss
+	EndDate						string	`json:"EndDate"`
+	InvitingValue				int		`json:"InvitingValue"`
+	InvitedValue				int		`json:"InvitedValue"`
+	MaxInvitationsPerInviter	int		`json:"MaxInvitationsPerInviter"`	// 0: unlimited
+	Status						int		`json:"Status"`
+	CreatedAt					string	`json:"CreatedAt"`
+	LastUpdatedAt				string	`json:"LastUpdatedAt"`
+}
+
+type CampaignStatistic struct {
+	CampaignId			string	`json:"CampaignId"`
+	Invitations			int		`json:"Invitations"`
+	Inviters			int		`json:"Inviters"`
+	Pending				int		`json:"Pending"`
+	Settled				int		`json:"Settled"`
+	Expired				int		`json:"Expired"`
+	InvitingCoins		int		`json:"InvitingCoins"`		// Paid to inviting users
+	InvitedCoins		int		`json:"InvitedCoins"`		// Paid to invited users
+}
@@ -12,6 +12,16 @@ type MobileCard struct {
 	LastUpdatedAt	string	`json:"LastUpdatedAt"`
 }
 
+/*
+	Result of a card import, Errors lists rejected rows (row number of the file)
+ */
+type MobileCardImport struct {
+	Total			int			`json:"Total"`
+	Imported		int			`json:"Imported"`
+	Duplicated		int			`json:"Duplicated"`
+	Errors			[]string	`json:"Errors"`
+}
+
 type MobileCardFilter struct {
 	Name			string	`json:"Name"`
 	Value 			int 	`json:"Value"`
@@ -0,0 +1,52 @@
+package dto
+
+import "time"
+
+/*
+	Message of the transactional outbox
+	+ CacheRefresh: cache families of user refreshed by the relay
+	+ EventType empty: cache refresh only, nothing is published
+ */
+type OutboxMessage struct {
+	Id					string		`json:"Id"`
+	EventType			string		`json:"EventType"`
+	RoutingKey			string		`json:"RoutingKey"`
+	UserId				string		`json:"UserId"`
+	CacheRefresh		[]string	`json:"CacheRefresh"`
+	WalletIds			[]string	`json:"WalletIds"`
+	Version				int			`json:"Version"`
+	Payload				string		`json:"Payload"`
+	Attempts			int			`json:"Attempts"`
+	CreatedAt			time.Time	`json:"CreatedAt"`
+}
+
+/*
+	Payload of event WalletCredited (minigame.wallet.credited), version 1
+ */
+type WalletCreditedEvent struct {
+	UserId				string		`json:"UserId"`
+	WalletId			string		`json:"WalletId"`
+	Program				string		`json:"Program"`
+	Value				int			`json:"Value"`
+}
+
+/*
+	Payload of event CardRedeemed (minigame.card.redeemed), version 1
+ */
+type CardRedeemedEvent struct {
+	UserId				string		`json:"UserId"`
+	VendorName			string		`json:"VendorName"`
+	Value				int			`json:"Value"`
+	Quantity			int			`json:"Quantity"`
+	MobileCardIds		[]string	`json:"MobileCardIds"`
+}
+
+/*
+	Payload of event InvitationCreated (minigame.invitation.created), version 1
+ */
+type InvitationCreatedEvent struct {
+	InvitingUserId		string		`json:"InvitingUserId"`
+	InvitedUserId		string		`json:"InvitedUserId"`
+	CampaignId			string		`json:"CampaignId"`
+	IsOnHold			bool		`json:"IsOnHold"`		// Suspicious, rewards held until reviewed
+}
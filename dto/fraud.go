@@ -0,0 +1,26 @@
+package dto
+
+type InvitationReview struct {
+	Id				string		`json:"Id"`
+	WalletId		string		`json:"WalletId"`
+	InvitingUser	string		`json:"InvitingUser"`
+	InvitedUser		string		`json:"InvitedUser"`
+	DeviceId		string		`json:"DeviceId"`
+	IpAddress		string		`json:"IpAddress"`
+	Score			int			`json:"Score"`
+	Reasons			string		`json:"Reasons"`
+	Status			int			`json:"Status"`
+	InvitingPrizeId	string		`json:"InvitingPrizeId"`
+	InvitingValue	int			`json:"InvitingValue"`
+	InvitedPrizeId	string		`json:"InvitedPrizeId"`
+	InvitedValue	int			`json:"InvitedValue"`
+	ReviewedBy		string		`json:"ReviewedBy"`
+	CreatedAt		string		`json:"CreatedAt"`
+	LastUpdatedAt	string		`json:"LastUpdatedAt"`
+}
+
+type FraudAssessment struct {
+	Score			int			`json:"Score"`
+	Reasons			[]string	`json:"Reasons"`
+	IsSuspicious	bool		`json:"IsSuspicious"`
+}
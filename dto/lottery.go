@@ -36,3 +36,20 @@ type LotteryPlayer struct {
 	Date 			string  `json:"Date"`
 	WalletId		string 	`json:"WalletId"`
 }
+
+/*
+	Settlement of a lottery date
+	+ DryRun: winners are listed, nobody is paid
+	+ Winners: paid by this settlement (to be paid on dry-run), AlreadyPaid were paid by an earlier one
+ */
+type LotterySettlement struct {
+	Date			string				`json:"Date"`		// YYYY-MM-DD, empty for today
+	Result			LotteryResult		`json:"Result"`
+	DryRun			bool				`json:"DryRun"`
+	SettledBy		string				`json:"SettledBy"`
+	Source			string				`json:"Source"`
+	PrizeValue		int					`json:"PrizeValue"`
+	Players			int					`json:"Players"`
+	Winners			[]LotteryPlayer		`json:"Winners"`
+	AlreadyPaid		int					`json:"AlreadyPaid"`
+}
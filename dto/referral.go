@@ -0,0 +1,21 @@
+package dto
+
+type Invitee struct {
+	UserId			string		`json:"UserId"`
+	Status			int			`json:"Status"`
+	Value			int			`json:"Value"`
+	InviteeCount	int			`json:"InviteeCount"`
+	CreatedAt		string		`json:"CreatedAt"`
+}
+
+type ReferralRank struct {
+	UserId			string		`json:"UserId"`
+	Invitations		int			`json:"Invitations"`
+	Rank			int			`json:"Rank"`
+}
+
+type ReferralLeaderboard struct {
+	Week			string			`json:"Week"`
+	Items			[]ReferralRank	`json:"Items"`
+	Me				*ReferralRank	`json:"Me"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+import "time"
+
+/*
+	Run of a scheduled job
+	+ TriggerType: Schedule or Manual
+	+ Status: Queued (manual, not picked up yet), Running, Succeeded, Failed, Skipped (lock held by another instance)
+ */
+type JobRun struct {
+	Id					string		`json:"Id"`
+	JobName				string		`json:"JobName"`
+	TriggerType			string		`json:"TriggerType"`
+	TriggeredBy			string		`json:"TriggeredBy"`
+	Status				string		`json:"Status"`
+	Result				string		`json:"Result"`
+	Error				string		`json:"Error"`
+	Instance			string		`json:"Instance"`
+	CreatedAt			time.Time	`json:"CreatedAt"`
+	StartedAt			*time.Time	`json:"StartedAt"`
+	FinishedAt			*time.Time	`json:"FinishedAt"`
+}
@@ -1,6 +1,16 @@
 package dto
 
+import "time"
+
+/*
+	Message sent to RabbitMQ
+	Domain events are versioned: Version is bumped on breaking changes of Data,
+	EventId is unique per event (consumers use it to skip duplicates)
+ */
 type Task struct {
 	MessageType string      `json:"MessageType"`
+	Version		int			`json:"Version,omitempty"`
+	EventId		string		`json:"EventId,omitempty"`
+	OccurredAt	*time.Time	`json:"OccurredAt,omitempty"`
 	Data		interface{} `json:"Data"`
 }
\ No newline at end of file
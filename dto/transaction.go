@@ -0,0 +1,66 @@
+package dto
+
+import "time"
+
+type Transaction struct {
+	Id				string		`json:"Id"`				// Id of the user_wallet record
+	UserId			string		`json:"UserId"`
+	Program			string		`json:"Program"`			// Name of the prize (program) of the record
+	Description		string		`json:"Description"`
+	Value			int			`json:"Value"`
+	ReferenceType	string		`json:"ReferenceType"`	// MobileCard, Invitation, Lottery, ReadDaily, Transfer, Adjustment
+	ReferenceId		string		`json:"ReferenceId"`
+	Counterparty	string		`json:"Counterparty"`
+	CreatedAt		string		`json:"CreatedAt"`
+}
+
+/*
+	Filter of transaction history, zero values are not applied
+	+ Direction: 1 received, -1 used
+	+ ToDate: exclusive
+	+ CursorTime, CursorId: last transaction of previous page
+ */
+type TransactionFilter struct {
+	Programs		[]string
+	FromDate		time.Time
+	ToDate			time.Time
+	Direction		int
+	CursorTime		time.Time
+	CursorId		string
+	PageSize		int
+}
+
+type TransactionPage struct {
+	Items			[]Transaction	`json:"Items"`
+	NextCursor		string			`json:"NextCursor"`		// Empty on the last page
+}
+
+/*
+	Monthly statement of a user's wallet
+ */
+type WalletStatement struct {
+	UserId			string				`json:"UserId"`
+	Month			string				`json:"Month"`			// yyyy-mm
+	OpeningBalance	int					`json:"OpeningBalance"`
+	Credits			[]StatementLine		`json:"Credits"`		// Grouped by program
+	TotalCredit		int					`json:"TotalCredit"`
+	Debits			[]StatementLine		`json:"Debits"`			// Grouped by program
+	TotalDebit		int					`json:"TotalDebit"`
+	ClosingBalance	int					`json:"ClosingBalance"`
+	Entries			[]StatementEntry	`json:"Entries"`
+}
+
+type StatementLine struct {
+	Program			string		`json:"Program"`
+	Description		string		`json:"Description"`
+	Count			int			`json:"Count"`
+	Value			int			`json:"Value"`
+}
+
+type StatementEntry struct {
+	Date			string		`json:"Date"`
+	Program			string		`json:"Program"`
+	Description		string		`json:"Description"`
+	Value			int			`json:"Value"`
+	Balance			int			`json:"Balance"`		// Balance after this entry
+}
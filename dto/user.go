@@ -6,16 +6,27 @@ type User struct{
 	Code 			string  `json:"Code"`
 	IsInvited		bool 	`json:"IsInvited"`
 	Wallet 			int 	`json:"Wallet"`
+	ExpiringSoon	int 	`json:"ExpiringSoon"`
 	ReadDaysOfWeek	[]int	`json:"ReadDaysOfWeek"`
 	DayOfWeek		int 	`json:"DayOfWeek"`
 	Description 	string 	`json:"Description"`
 	Value 			int 	`json:"Value"`
 	LastUpdatedAt	string 	`json:"LastUpdatedAt"`
+	Counterparty	string 	`json:"Counterparty"`		// Other user of a transfer
 }
 
 type Invitation struct {
 	UserId 		string 		`json:"UserId"`
 	Code 		string 		`json:"Code"`
+	CampaignCode	string	`json:"CampaignCode"`
+	DeviceId	string		`json:"DeviceId"`
+	IpAddress	string		`json:"-"`
+}
+
+type InvitationLink struct {
+	Code 		string 		`json:"Code"`
+	Link 		string 		`json:"Link"`
+	Message 	string 		`json:"Message"`
 }
 
 type Generation struct {
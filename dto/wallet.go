@@ -0,0 +1,35 @@
+package dto
+
+type WalletAdjustment struct {
+	Id					string		`json:"Id"`
+	WalletId			string		`json:"WalletId"`
+	UserId				string		`json:"UserId"`
+	Value				int			`json:"Value"`		// Positive: credit, negative: debit
+	Reason				string		`json:"Reason"`
+	TicketReference		string		`json:"TicketReference"`
+	CreatedBy			string		`json:"CreatedBy"`
+	BatchId				string		`json:"BatchId"`
+	CreatedAt			string		`json:"CreatedAt"`
+}
+
+type BulkAdjustmentResult struct {
+	BatchId				string						`json:"BatchId"`
+	Total				int							`json:"Total"`
+	Succeeded			int							`json:"Succeeded"`
+	Failed				[]BulkAdjustmentFailure		`json:"Failed"`
+}
+
+type BulkAdjustmentFailure struct {
+	UserId				string		`json:"UserId"`
+	ErrorCode			int			`json:"ErrorCode"`
+	Message				string		`json:"Message"`
+}
+
+type WalletTransfer struct {
+	Id					string		`json:"Id"`
+	SenderId			string		`json:"SenderId"`
+	RecipientId			string		`json:"RecipientId"`
+	Value				int			`json:"Value"`
+	Message				string		`json:"Message"`
+	CreatedAt			string		`json:"CreatedAt"`
+}
@@ -6,6 +6,8 @@ package gerror
 const (
 	ErrorBindData			int = 40000
 	ErrorValidData			int = 40001
+	ErrorInvalidPhoneNumber	int = 40002
+	ErrorUnauthenticated	int = 40003
 )
 
 /********************************************************************/
@@ -29,6 +31,11 @@ const (
 	ErrorInvitingUserIsYou					int = 40012
 	ErrorInvitingProgramNotFound			int = 40013
 	ErrorInvitedProgramNotFound				int = 40014
+	ErrorInvitationPendingReview			int = 40015
+	ErrorInvitationReviewNotFound			int = 40016
+	ErrorInvitingCodeInvalid				int = 40017
+	ErrorInvitingCodeNotAllowed				int = 40018
+	ErrorInvitingCodeExisted				int = 40019
 
 
 	ErrorNotEnoughCoin						int = 40020
@@ -43,4 +50,26 @@ const (
 	ErrorLotteryExceedNumberOfSelected		int = 40041
 	ErrorLotteryDuplicatedSelectedNumber	int = 40042
 	ErrorLotteryTimeUp						int = 40043
+	ErrorLotteryDateInvalid					int = 40044
+	ErrorLotteryResultInvalid				int = 40045
+	ErrorLotteryResultMismatch				int = 40046
+
+	ErrorCampaignNotFound					int = 40050
+	ErrorCampaignInvalid					int = 40051
+
+	ErrorWalletUserNotExisted				int = 40060
+	ErrorWalletAdjustmentInvalid			int = 40061
+	ErrorWalletAdjustmentProgramNotFound	int = 40062
+	ErrorTransferInvalid					int = 40063
+	ErrorTransferToYourself					int = 40064
+	ErrorTransferExceedDailyLimit			int = 40065
+	ErrorTransferBelowMinimumBalance		int = 40066
+	ErrorTransferProgramNotFound			int = 40067
+
+	ErrorCacheFamilyNotFound				int = 40070
+	ErrorCacheFamilyNotPerUser				int = 40071
+	ErrorCacheResetNotFound					int = 40072
+
+	ErrorJobNotFound						int = 40080
+	ErrorJobRunNotFound						int = 40081
 )
\ No newline at end of file
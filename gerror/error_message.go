@@ -9,6 +9,10 @@ func T(errorCode int) string {
 		return "Failed to bind data"
 	case ErrorValidData:
 		return "Failed to valid data"
+	case ErrorInvalidPhoneNumber:
+		return "Số điện thoại không hợp lệ"
+	case ErrorUnauthenticated:
+		return "User is not authenticated"
 	case ErrorNotFound:
 		return "Item not found"
 	//////////////////////////
@@ -41,6 +45,16 @@ func T(errorCode int) string {
 		return "Không nhập mã giới thiệu của mình"
 	case ErrorInvitingProgramNotFound:
 		return "Chương trình giới thiệu bạn bè không tồn tại"
+	case ErrorInvitationPendingReview:
+		return "Phần thưởng giới thiệu đang chờ xét duyệt"
+	case ErrorInvitationReviewNotFound:
+		return "Không tìm thấy yêu cầu xét duyệt"
+	case ErrorInvitingCodeInvalid:
+		return "Mã giới thiệu không hợp lệ"
+	case ErrorInvitingCodeNotAllowed:
+		return "Mã giới thiệu chứa từ không phù hợp"
+	case ErrorInvitingCodeExisted:
+		return "Mã giới thiệu đã được sử dụng"
 	case ErrorMobileCardProgramNotFound:
 		return "Chương trình đổi thẻ nạp không tồn tại"
 	case ErrorNotEnoughCoin:
@@ -62,6 +76,46 @@ func T(errorCode int) string {
 		return "Số này đã được chọn trước đó"
 	case ErrorLotteryTimeUp:
 		return "Đã hết thời gian chọn số trong ngày"
+	case ErrorLotteryDateInvalid:
+		return "Ngày xổ số không hợp lệ"
+	case ErrorLotteryResultInvalid:
+		return "Kết quả xổ số không hợp lệ"
+	case ErrorLotteryResultMismatch:
+		return "Ngày xổ số đã được trả thưởng với kết quả khác"
+
+	case ErrorCampaignNotFound:
+		return "Chương trình khuyến mãi không tồn tại hoặc đã kết thúc"
+	case ErrorCampaignInvalid:
+		return "Ngày bắt đầu phải trước ngày kết thúc và số xu thưởng không được âm"
+
+	case ErrorWalletUserNotExisted:
+		return "Người dùng không tồn tại"
+	case ErrorWalletAdjustmentInvalid:
+		return "Số xu, lý do và người thực hiện điều chỉnh là bắt buộc"
+	case ErrorWalletAdjustmentProgramNotFound:
+		return "Chương trình điều chỉnh xu không tồn tại"
+	case ErrorTransferInvalid:
+		return "Số xu tặng không hợp lệ"
+	case ErrorTransferToYourself:
+		return "Bạn không thể tự tặng xu cho mình"
+	case ErrorTransferExceedDailyLimit:
+		return "Bạn đã vượt quá hạn mức tặng xu trong ngày"
+	case ErrorTransferBelowMinimumBalance:
+		return "Số xu còn lại sau khi tặng thấp hơn mức tối thiểu"
+	case ErrorTransferProgramNotFound:
+		return "Chương trình tặng xu không tồn tại"
+
+	case ErrorCacheFamilyNotFound:
+		return "Nhóm cache không tồn tại"
+	case ErrorCacheFamilyNotPerUser:
+		return "Nhóm cache không thuộc về người dùng"
+	case ErrorCacheResetNotFound:
+		return "Yêu cầu xoá cache không tồn tại hoặc đã hết hạn"
+
+	case ErrorJobNotFound:
+		return "Tác vụ định kỳ không tồn tại"
+	case ErrorJobRunNotFound:
+		return "Lần chạy tác vụ không tồn tại"
 	}
 
 	return "Unknown error"
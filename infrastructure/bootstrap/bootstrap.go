@@ -0,0 +1,111 @@
+package bootstrap
+
+import (
+	"database/sql"
+	"g-tech.com/infrastructure/broker"
+	"g-tech.com/infrastructure/cache"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/repository"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+/*
+	Connections shared by the commands, each command opens the ones it needs
+ */
+
+func LoadConfig(path string) error {
+	viper.SetConfigFile(path)
+	return viper.ReadInConfig()
+}
+
+/*
+	Log to Log.Path, file names start with the value of prefixKey
+ */
+func NewLogger(prefixKey string) {
+	logPath 	:= viper.GetString(`Log.Path`)
+	logPrefix 	:= viper.GetString(prefixKey)
+	logger.NewLogger(logPath, logPrefix)
+}
+
+func Timeout() time.Duration {
+	return time.Duration(viper.GetInt("Context.Timeout")) * time.Second
+}
+
+func ConnectMySql() (*sql.DB, error) {
+	// Load MySql configuration
+	MySqlHost 				:= viper.GetString(`MySql.Host`)
+	MySqlUserName 			:= viper.GetString(`MySql.UserName`)
+	MySqlPassword			:= viper.GetString(`MySql.Password`)
+	MySqlDatabase			:= viper.GetString(`MySql.Database`)
+	MySqlMaxOpenConnections	:= viper.GetInt(`MySql.MaxOpenConnections`)
+	MySqlMaxIdleConnections	:= viper.GetInt(`MySql.MaxIdleConnections`)
+
+	// Open a MySql infrastructure
+	dbContext := repository.ConnectMySql(MySqlHost, MySqlUserName, MySqlPassword, MySqlDatabase, MySqlMaxOpenConnections, MySqlMaxIdleConnections)
+	if dbContext == nil {
+		return nil, errors.New("Failed to connect to MySql")
+	}
+
+	err := dbContext.Ping()
+	if err != nil {
+		_ = dbContext.Close()
+		return nil, err
+	}
+	logger.Info("Connected")
+
+	return dbContext, nil
+}
+
+/*
+	Redis being unavailable is not fatal: reads go to MySql until it is back
+ */
+func ConnectRedis() (cache.CacheManager, error) {
+	cacheManager := cache.CacheManager{}
+	err := cacheManager.Init(cache.LoadConfig())
+	if err != nil {
+		return cacheManager, err
+	}
+
+	err = cacheManager.Ping()
+	if err != nil {
+		logger.Error("Redis is unavailable: %s", err.Error())
+	}
+	return cacheManager, nil
+}
+
+/*
+	Setups and consumers are registered on the client before Start
+ */
+func NewBroker() (*broker.Client, error) {
+	return broker.NewClient(broker.LoadConfig())
+}
+
+/*
+	Start the client, RabbitMQ being unavailable is not fatal: the client keeps reconnecting
+ */
+func StartBroker(rbClient *broker.Client) {
+	err := rbClient.Start()
+	if err != nil {
+		logger.Error("RabbitMQ is unavailable, retrying: %s", err.Error())
+	}
+}
+
+/*
+	Closed on SIGINT or SIGTERM
+ */
+func WaitForSignal() <-chan struct{} {
+	stop := make(chan struct{})
+	go func() {
+		quit := make(chan os.Signal, 1)
+		signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+		<-quit
+		logger.Info("Shutting down")
+		close(stop)
+	}()
+	return stop
+}
@@ -0,0 +1,420 @@
+package broker
+
+import (
+	"crypto/tls"
+	"fmt"
+	"g-tech.com/dto"
+	"g-tech.com/infrastructure/logger"
+	"github.com/pkg/errors"
+	"github.com/streadway/amqp"
+	"sync"
+	"time"
+)
+
+const (
+	reconnectMinDelay	= 1 * time.Second
+	reconnectMaxDelay	= 30 * time.Second
+	heartbeat			= 10 * time.Second
+)
+
+var ErrNotConnected = errors.New("RabbitMQ is not connected")
+var ErrNotConfirmed = errors.New("RabbitMQ did not confirm the message")
+var ErrUnroutable = errors.New("RabbitMQ could not route the message to any queue")
+
+/*
+	Setup of a channel (queues, bindings, QoS), run again after each reconnect
+ */
+type SetupFunc func(ch *amqp.Channel) error
+
+type consumer struct {
+	Queue		string
+	Tag			string
+	Handler		func(delivery amqp.Delivery)
+}
+
+/*
+	Channel in confirm mode, messages are published one at a time and each one waits for its confirmation
+ */
+type publisher struct {
+	mutex			sync.Mutex
+	channel			*amqp.Channel
+	confirms		chan amqp.Confirmation
+	returns			chan amqp.Return
+	deliveryTag		uint64
+}
+
+/*
+	RabbitMQ client reconnecting with backoff when the connection or the channel is closed
+	Setups are run again and consumers resumed on the new channel
+ */
+type Client struct {
+	config			Config
+	tlsConfig		*tls.Config
+	mutex			sync.RWMutex
+	connection		*amqp.Connection
+	channel			*amqp.Channel
+	publisher		*publisher
+	setups			[]SetupFunc
+	consumers		[]consumer
+	handlers		sync.WaitGroup
+	done			chan struct{}
+	closeOnce		sync.Once
+}
+
+func NewClient(config Config) (*Client, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	if config.Concurrency < 1 {
+		config.Concurrency = 1
+	}
+
+	return &Client{
+		config:		config,
+		tlsConfig:	tlsConfig,
+		done:		make(chan struct{}),
+	}, nil
+}
+
+/*
+	Exchange of the client, events are published to it and queues bound to it
+ */
+func (client *Client) Exchange() string {
+	return client.config.Exchange
+}
+
+/*
+	Register a setup, run on the current channel when connected
+ */
+func (client *Client) Setup(setup SetupFunc) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	client.setups = append(client.setups, setup)
+	if client.channel == nil {
+		return nil
+	}
+	return setup(client.channel)
+}
+
+/*
+	Register a consumer of queue, deliveries are handled by Concurrency goroutines
+	Handler must ack or nack the delivery
+ */
+func (client *Client) Consume(queue string, handler func(delivery amqp.Delivery)) error {
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	c := consumer{
+		Queue:		queue,
+		Tag:		fmt.Sprintf("%s-%d", queue, len(client.consumers)),
+		Handler:	handler,
+	}
+	client.consumers = append(client.consumers, c)
+	if client.channel == nil {
+		return nil
+	}
+	return client.startConsumer(client.channel, c)
+}
+
+/*
+	Current channel, nil while disconnected
+ */
+func (client *Client) Channel() *amqp.Channel {
+	client.mutex.RLock()
+	defer client.mutex.RUnlock()
+	return client.channel
+}
+
+func (client *Client) IsConnected() bool {
+	return client.Channel() != nil
+}
+
+/*
+	Publish to the exchange of the client and wait until RabbitMQ has taken the message
+	+ ErrUnroutable: no queue is bound for the routing key (mandatory)
+	+ ErrNotConfirmed: RabbitMQ rejected the message or did not confirm it within PublishTimeout
+ */
+func (client *Client) Publish(routeKey string, task dto.Task) error {
+	client.mutex.RLock()
+	publisher := client.publisher
+	client.mutex.RUnlock()
+	if publisher == nil {
+		return ErrNotConnected
+	}
+
+	publishing, err := newPublishing(task)
+	if err != nil {
+		return err
+	}
+
+	publisher.mutex.Lock()
+	defer publisher.mutex.Unlock()
+
+	err = publisher.channel.Publish(client.config.Exchange, routeKey, true, false, publishing)
+	if err != nil {
+		logger.Error("Failed to publish a message %s", err.Error())
+		return err
+	}
+	publisher.deliveryTag++
+
+	timeout := time.After(client.config.PublishTimeout)
+	for {
+		select {
+		case confirmation, ok := <-publisher.confirms:
+			if !ok {
+				return ErrNotConnected
+			}
+			// Confirmation of a message which timed out before
+			if confirmation.DeliveryTag < publisher.deliveryTag {
+				continue
+			}
+			if !confirmation.Ack {
+				return ErrNotConfirmed
+			}
+			return publisher.returned(publishing.MessageId)
+		case <-timeout:
+			return ErrNotConfirmed
+		}
+	}
+}
+
+/*
+	Unroutable message is returned before it is confirmed, returns of older messages are skipped
+ */
+func (publisher *publisher) returned(messageId string) error {
+	for {
+		select {
+		case returned := <-publisher.returns:
+			if returned.MessageId == messageId {
+				return ErrUnroutable
+			}
+		default:
+			return nil
+		}
+	}
+}
+
+/*
+	Connect and keep the connection open until Close
+	Returns the error of the first attempt, the client keeps reconnecting in background anyway
+ */
+func (client *Client) Start() error {
+	err := client.connect()
+	go client.watch()
+	return err
+}
+
+/*
+	Stop consumers, wait for deliveries in progress, then close the connection
+ */
+func (client *Client) Close() {
+	client.closeOnce.Do(func() {
+		close(client.done)
+
+		client.mutex.Lock()
+		channel := client.channel
+		if channel != nil {
+			for _, c := range client.consumers {
+				_ = channel.Cancel(c.Tag, false)
+			}
+		}
+		client.mutex.Unlock()
+
+		client.handlers.Wait()
+
+		client.mutex.Lock()
+		defer client.mutex.Unlock()
+		if client.publisher != nil {
+			_ = client.publisher.channel.Close()
+			client.publisher = nil
+		}
+		if client.channel != nil {
+			_ = client.channel.Close()
+			client.channel = nil
+		}
+		if client.connection != nil {
+			_ = client.connection.Close()
+			client.connection = nil
+		}
+	})
+}
+
+func (client *Client) connect() error {
+	connection, err := amqp.DialConfig(client.config.url(), amqp.Config{
+		Heartbeat:			heartbeat,
+		TLSClientConfig:	client.tlsConfig,
+	})
+	if err != nil {
+		return err
+	}
+
+	channel, err := connection.Channel()
+	if err == nil {
+		err = client.prepare(channel)
+	}
+	if err != nil {
+		_ = connection.Close()
+		return err
+	}
+
+	publisher, err := newPublisher(connection)
+	if err != nil {
+		_ = connection.Close()
+		return err
+	}
+
+	client.mutex.Lock()
+	defer client.mutex.Unlock()
+
+	// Closed meanwhile
+	select {
+	case <-client.done:
+		_ = connection.Close()
+		return nil
+	default:
+	}
+
+	for _, setup := range client.setups {
+		err = setup(channel)
+		if err != nil {
+			_ = connection.Close()
+			return err
+		}
+	}
+	for _, c := range client.consumers {
+		err = client.startConsumer(channel, c)
+		if err != nil {
+			_ = connection.Close()
+			return err
+		}
+	}
+
+	client.connection = connection
+	client.channel = channel
+	client.publisher = publisher
+	return nil
+}
+
+/*
+	Confirms and returns are buffered, a confirmation arriving after its timeout is read by the next publish
+ */
+func newPublisher(connection *amqp.Connection) (*publisher, error) {
+	channel, err := connection.Channel()
+	if err != nil {
+		return nil, err
+	}
+
+	err = channel.Confirm(false)
+	if err != nil {
+		_ = channel.Close()
+		return nil, err
+	}
+
+	return &publisher{
+		channel:	channel,
+		confirms:	channel.NotifyPublish(make(chan amqp.Confirmation, 16)),
+		returns:	channel.NotifyReturn(make(chan amqp.Return, 16)),
+	}, nil
+}
+
+/*
+	Declare the exchange and set QoS of the channel
+ */
+func (client *Client) prepare(channel *amqp.Channel) error {
+	if client.config.ExchangeDeclare {
+		err := channel.ExchangeDeclare(
+			client.config.Exchange,
+			client.config.ExchangeType,
+			true,		// durable
+			false,		// auto delete
+			false,		// internal
+			false,		// no-wait
+			nil,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	return channel.Qos(client.config.PrefetchCount, 0, false)
+}
+
+/*
+	Wait for the connection or the channel to close, then reconnect with exponential backoff
+ */
+func (client *Client) watch() {
+	delay := reconnectMinDelay
+	for {
+		client.mutex.RLock()
+		connection, channel, publisher := client.connection, client.channel, client.publisher
+		client.mutex.RUnlock()
+
+		if connection != nil {
+			connectionClosed := connection.NotifyClose(make(chan *amqp.Error, 1))
+			channelClosed := channel.NotifyClose(make(chan *amqp.Error, 1))
+			publisherClosed := publisher.channel.NotifyClose(make(chan *amqp.Error, 1))
+
+			var reason *amqp.Error
+			select {
+			case <-client.done:
+				return
+			case reason = <-connectionClosed:
+			case reason = <-channelClosed:
+			case reason = <-publisherClosed:
+			}
+			if reason != nil {
+				logger.Error("RabbitMQ closed: %s", reason.Error())
+			}
+
+			client.mutex.Lock()
+			if client.connection != nil {
+				_ = client.connection.Close()
+			}
+			client.connection = nil
+			client.channel = nil
+			client.publisher = nil
+			client.mutex.Unlock()
+			delay = reconnectMinDelay
+		}
+
+		select {
+		case <-client.done:
+			return
+		case <-time.After(delay):
+		}
+
+		err := client.connect()
+		if err != nil {
+			logger.Error("Failed to reconnect to RabbitMQ %s", err.Error())
+			delay *= 2
+			if delay > reconnectMaxDelay {
+				delay = reconnectMaxDelay
+			}
+			continue
+		}
+		logger.Info("Reconnected to RabbitMQ")
+	}
+}
+
+/*
+	Deliveries stop when the channel is closed or the consumer is cancelled
+ */
+func (client *Client) startConsumer(channel *amqp.Channel, c consumer) error {
+	deliveries, err := channel.Consume(c.Queue, c.Tag, false, false, false, false, nil)
+	if err != nil {
+		return err
+	}
+
+	for i := 0; i < client.config.Concurrency; i++ {
+		client.handlers.Add(1)
+		go func() {
+			defer client.handlers.Done()
+			for delivery := range deliveries {
+				c.Handler(delivery)
+			}
+		}()
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package broker
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"g-tech.com/constant"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const amqpTopic = "topic"
+
+/*
+	Connection to RabbitMQ
+	+ TLS: amqps with the CA of the server, client certificate when the server requires it
+	+ Exchange: declared on each connection when ExchangeDeclare is set
+	+ PrefetchCount: unacked deliveries per consumer
+	+ Concurrency: deliveries of a consumer handled in parallel
+	+ PublishTimeout: time to wait for RabbitMQ to confirm a published message
+ */
+type Config struct {
+	Host				string
+	Port				int
+	UserName			string
+	Password			string
+	VirtualHost			string
+	TLSEnabled			bool
+	TLSCAFile			string
+	TLSCertFile			string
+	TLSKeyFile			string
+	TLSServerName		string
+	TLSSkipVerify		bool
+	Exchange			string
+	ExchangeType		string
+	ExchangeDeclare		bool
+	PrefetchCount		int
+	Concurrency			int
+	PublishTimeout		time.Duration
+}
+
+func LoadConfig() Config {
+	viper.SetDefault("RabbitMQ.Port", 5672)
+	viper.SetDefault("RabbitMQ.VirtualHost", "/")
+	viper.SetDefault("RabbitMQ.Exchange.Name", constant.RbSuperExchange)
+	viper.SetDefault("RabbitMQ.Exchange.Type", amqpTopic)
+	viper.SetDefault("RabbitMQ.Exchange.Declare", true)
+	viper.SetDefault("RabbitMQ.PrefetchCount", 1)
+	viper.SetDefault("RabbitMQ.Concurrency", 1)
+	viper.SetDefault("RabbitMQ.PublishTimeoutSeconds", 5)
+
+	return Config{
+		Host:				viper.GetString("RabbitMQ.Host"),
+		Port:				viper.GetInt("RabbitMQ.Port"),
+		UserName:			viper.GetString("RabbitMQ.UserName"),
+		Password:			viper.GetString("RabbitMQ.Password"),
+		VirtualHost:		viper.GetString("RabbitMQ.VirtualHost"),
+		TLSEnabled:			viper.GetBool("RabbitMQ.TLS.Enabled"),
+		TLSCAFile:			viper.GetString("RabbitMQ.TLS.CAFile"),
+		TLSCertFile:		viper.GetString("RabbitMQ.TLS.CertFile"),
+		TLSKeyFile:			viper.GetString("RabbitMQ.TLS.KeyFile"),
+		TLSServerName:		viper.GetString("RabbitMQ.TLS.ServerName"),
+		TLSSkipVerify:		viper.GetBool("RabbitMQ.TLS.InsecureSkipVerify"),
+		Exchange:			viper.GetString("RabbitMQ.Exchange.Name"),
+		ExchangeType:		strings.ToLower(viper.GetString("RabbitMQ.Exchange.Type")),
+		ExchangeDeclare:	viper.GetBool("RabbitMQ.Exchange.Declare"),
+		PrefetchCount:		viper.GetInt("RabbitMQ.PrefetchCount"),
+		Concurrency:		viper.GetInt("RabbitMQ.Concurrency"),
+		PublishTimeout:		time.Duration(viper.GetInt64("RabbitMQ.PublishTimeoutSeconds")) * time.Second,
+	}
+}
+
+/*
+	URL of the server, amqps when TLS is enabled
+ */
+func (config Config) url() string {
+	scheme := "amqp"
+	if config.TLSEnabled {
+		scheme = "amqps"
+	}
+
+	uri := url.URL{
+		Scheme:		scheme,
+		User:		url.UserPassword(config.UserName, config.Password),
+		Host:		fmt.Sprintf("%s:%d", config.Host, config.Port),
+		Path:		"/" + config.VirtualHost,
+		RawPath:	"/" + url.PathEscape(config.VirtualHost),
+	}
+	if config.VirtualHost == "/" || config.VirtualHost == "" {
+		uri.Path, uri.RawPath = "/", ""
+	}
+	return uri.String()
+}
+
+func newTLSConfig(config Config) (*tls.Config, error) {
+	if !config.TLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:			config.TLSServerName,
+		InsecureSkipVerify:	config.TLSSkipVerify,
+	}
+
+	if config.TLSCAFile != "" {
+		ca, err := ioutil.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("No certificate found in %s", config.TLSCAFile)
+		}
+	}
+
+	// Client certificate, when the server requires it
+	if config.TLSCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
@@ -2,30 +2,12 @@ package broker
 
 import (
 	"encoding/json"
-	"fmt"
 	"g-tech.com/dto"
 	"g-tech.com/infrastructure"
 	"g-tech.com/infrastructure/logger"
 	"github.com/streadway/amqp"
 )
 
-/**
- * Connects to RabbitMQ server
- */
-func Connect(host string, port int, userName string, password string) (conn *amqp.Connection) {
-	url := fmt.Sprintf("amqp://%s:%s@%s:%d/", userName, password, host, port)
-
-	// Make a infrastructure
-	conn, err := amqp.Dial(url)
-
-	if err != nil {
-		logger.Error("Failed to connect to RabbitMQ %s", err.Error())
-		return nil
-	}
-
-	return conn
-}
-
 /**
  * Creates a RabbitMQ queue
  */
@@ -56,13 +38,16 @@ func PushMessage(rbChannel *amqp.Channel, rbExchange string, rbRouteKey string,
 		MessageType: messageType,
 		Data:     obj,
 	}
-	//if (messageType == "ResultPost"){
-	//	fmt.Println(util.ToJSON(objTask))
-	//}
-	task, err := json.Marshal(objTask)
+	return PushEvent(rbChannel, rbExchange, rbRouteKey, objTask)
+}
 
+/**
+ * Pushes a versioned event to RabbitMQ exchange
+ * EventId and MessageType are also set on the message properties
+ */
+func PushEvent(rbChannel *amqp.Channel, rbExchange string, rbRouteKey string, objTask dto.Task) error {
+	publishing, err := newPublishing(objTask)
 	if err != nil {
-		logger.Error("Failed to parse message %s", err.Error())
 		return err
 	}
 
@@ -71,11 +56,7 @@ func PushMessage(rbChannel *amqp.Channel, rbExchange string, rbRouteKey string,
 		rbRouteKey, 			// routing key
 		false,       	// mandatory
 		false,       	// immediate
-		amqp.Publishing{
-			DeliveryMode: amqp.Persistent,
-			ContentType: infrastructure.DefaultContentType,
-			Body:        task,
-		})
+		publishing)
 
 	if err != nil {
 		logger.Error("Failed to publish a message %s", err.Error())
@@ -85,6 +66,31 @@ func PushMessage(rbChannel *amqp.Channel, rbExchange string, rbRouteKey string,
 	return nil
 }
 
+/**
+ * Persistent message of a task
+ */
+func newPublishing(objTask dto.Task) (amqp.Publishing, error) {
+	task, err := json.Marshal(objTask)
+
+	if err != nil {
+		logger.Error("Failed to parse message %s", err.Error())
+		return amqp.Publishing{}, err
+	}
+
+	publishing := amqp.Publishing{
+		DeliveryMode: amqp.Persistent,
+		ContentType: infrastructure.DefaultContentType,
+		MessageId:   objTask.EventId,
+		Type:        objTask.MessageType,
+		Body:        task,
+	}
+	if objTask.OccurredAt != nil {
+		publishing.Timestamp = *objTask.OccurredAt
+	}
+
+	return publishing, nil
+}
+
 /**
  * Handles error
  */
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"g-tech.com/infrastructure/logger"
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"reflect"
+	"sync"
+	"time"
+)
+
+/*
+	Returned instead of calling Redis while the circuit is open
+ */
+var ErrCacheUnavailable = errors.New("Cache is unavailable")
+
+/*
+	Replies of Redis (redis.Nil, WRONGTYPE...) share the type of redis.Nil, they do not mean Redis is down
+ */
+var redisReplyType = reflect.TypeOf(redis.Nil)
+
+/*
+	Circuit breaker of Redis
+	+ closed: commands are sent, consecutive failures are counted
+	+ open (FailureThreshold reached): commands fail right away, reads go to MySql
+	Keys failing to be invalidated are kept then deleted once Redis is back, before the circuit is closed again
+ */
+type breaker struct {
+	mutex				sync.Mutex
+	failures			int
+	isOpen				bool
+	pending				map[string]bool
+	isOverflowed		bool		// Too many pending keys, every family is reset on recovery
+	threshold			int
+	cooldown			time.Duration
+	maxPending			int
+}
+
+func newBreaker() *breaker {
+	viper.SetDefault("Cache.Breaker.FailureThreshold", 5)
+	viper.SetDefault("Cache.Breaker.CooldownSeconds", 10)
+	viper.SetDefault("Cache.Breaker.MaxPendingInvalidations", 100000)
+
+	return &breaker{
+		pending:		make(map[string]bool),
+		threshold:		viper.GetInt("Cache.Breaker.FailureThreshold"),
+		cooldown:		time.Duration(viper.GetInt64("Cache.Breaker.CooldownSeconds")) * time.Second,
+		maxPending:		viper.GetInt("Cache.Breaker.MaxPendingInvalidations"),
+	}
+}
+
+/*
+* Check if Redis is available (circuit closed)
+*/
+func (manager *CacheManager) IsAvailable() bool {
+	if manager.breaker == nil {
+		return true
+	}
+
+	manager.breaker.mutex.Lock()
+	defer manager.breaker.mutex.Unlock()
+	return !manager.breaker.isOpen
+}
+
+/*
+* Delete keys, they are deleted later if Redis is unavailable
+*/
+func (manager *CacheManager) Invalidate(keys ...string) {
+	var failedKeys []string
+	for _, key := range keys {
+		err := manager.call(func() error {
+			return manager.Client.Del(manager.key(key)).Err()
+		})
+		if err != nil {
+			failedKeys = append(failedKeys, key)
+		}
+	}
+	if len(failedKeys) == 0 || manager.breaker == nil {
+		return
+	}
+
+	manager.breaker.mutex.Lock()
+	defer manager.breaker.mutex.Unlock()
+	for _, key := range failedKeys {
+		if len(manager.breaker.pending) >= manager.breaker.maxPending {
+			manager.breaker.isOverflowed = true
+			break
+		}
+		manager.breaker.pending[key] = true
+	}
+	logger.Error("Cache invalidation queued: %d keys pending", len(manager.breaker.pending))
+}
+
+/*
+	Run a command through the circuit breaker
+ */
+func (manager *CacheManager) call(fn func() error) error {
+	if manager.breaker == nil {
+		return fn()
+	}
+	if !manager.IsAvailable() {
+		return ErrCacheUnavailable
+	}
+
+	err := fn()
+	manager.report(err)
+	return err
+}
+
+func (manager *CacheManager) report(err error) {
+	b := manager.breaker
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if err == nil || reflect.TypeOf(err) == redisReplyType {
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.failures < b.threshold {
+		return
+	}
+	manager.open(err)
+}
+
+/*
+	Must be called with the mutex held
+ */
+func (manager *CacheManager) open(err error) {
+	if manager.breaker.isOpen {
+		return
+	}
+
+	manager.breaker.isOpen = true
+	logger.Error("Cache circuit opened: %s", err.Error())
+	go manager.recover()
+}
+
+/*
+* Check connection to Redis, cache is bypassed until Redis is back if it fails
+*/
+func (manager *CacheManager) Ping() error {
+	err := manager.Client.Ping().Err()
+	if err != nil && manager.breaker != nil {
+		manager.breaker.mutex.Lock()
+		manager.open(err)
+		manager.breaker.mutex.Unlock()
+	}
+	return err
+}
+
+/*
+	Probe Redis until it answers, flush pending invalidations then close the circuit
+ */
+func (manager *CacheManager) recover() {
+	b := manager.breaker
+	for {
+		time.Sleep(b.cooldown)
+
+		err := manager.Client.Ping().Err()
+		if err != nil {
+			continue
+		}
+
+		err = manager.flushPending()
+		if err != nil {
+			logger.Error(err.Error())
+			continue
+		}
+
+		b.mutex.Lock()
+		// Keys invalidated while flushing
+		if len(b.pending) > 0 || b.isOverflowed {
+			b.mutex.Unlock()
+			continue
+		}
+		b.failures = 0
+		b.isOpen = false
+		b.mutex.Unlock()
+
+		logger.Info("Cache circuit closed")
+		return
+	}
+}
+
+func (manager *CacheManager) flushPending() error {
+	b := manager.breaker
+
+	b.mutex.Lock()
+	keys := make([]string, 0, len(b.pending))
+	for key := range b.pending {
+		keys = append(keys, key)
+	}
+	isOverflowed := b.isOverflowed
+	b.mutex.Unlock()
+
+	// Circuit is open, commands go to the client directly
+	if isOverflowed {
+		for family := range manager.Families() {
+			err := manager.Client.Incr(versionKey(family)).Err()
+			if err != nil {
+				return err
+			}
+		}
+	} else {
+		// Keys are deleted under the current versions
+		err := manager.loadVersions()
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			err := manager.Client.Del(manager.key(key)).Err()
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	b.mutex.Lock()
+	for _, key := range keys {
+		delete(b.pending, key)
+	}
+	if isOverflowed {
+		b.pending = make(map[string]bool)
+		b.isOverflowed = false
+	}
+	b.mutex.Unlock()
+
+	// Versions may have been bumped
+	manager.namespace.mutex.Lock()
+	manager.namespace.loadedAt = time.Time{}
+	manager.namespace.mutex.Unlock()
+	return nil
+}
@@ -0,0 +1,105 @@
+package cache
+
+import (
+	"errors"
+	"g-tech.com/infrastructure/logger"
+	"github.com/go-redis/redis"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	logger.NewLogger(os.TempDir(), "cache_test").Out = ioutil.Discard
+	os.Exit(m.Run())
+}
+
+/*
+	Redis is never probed: recovery waits for the cooldown, longer than the test
+ */
+func newTestManager(threshold int, maxPending int) *CacheManager {
+	return &CacheManager{
+		breaker: &breaker{
+			pending:		make(map[string]bool),
+			threshold:		threshold,
+			cooldown:		time.Hour,
+			maxPending:		maxPending,
+		},
+	}
+}
+
+func TestBreakerOpensAfterConsecutiveFailures(t *testing.T) {
+	failure := errors.New("connection refused")
+
+	tests := []struct {
+		name		string
+		replies		[]error
+		isOpen		bool
+	}{
+		{"no failure", []error{nil, nil, nil}, false},
+		{"below threshold", []error{failure, failure}, false},
+		{"threshold reached", []error{failure, failure, failure}, true},
+		{"success resets the count", []error{failure, failure, nil, failure, failure}, false},
+		{"replies of redis are not failures", []error{redis.Nil, redis.Nil, redis.Nil, redis.Nil}, false},
+		{"reply resets the count", []error{failure, failure, redis.Nil, failure}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := newTestManager(3, 10)
+			for _, reply := range test.replies {
+				reply := reply
+				_ = manager.call(func() error {
+					return reply
+				})
+			}
+			if manager.IsAvailable() == test.isOpen {
+				t.Errorf("IsAvailable() = %v, want %v", manager.IsAvailable(), !test.isOpen)
+			}
+		})
+	}
+}
+
+func TestOpenBreakerSkipsRedis(t *testing.T) {
+	manager := newTestManager(1, 10)
+	_ = manager.call(func() error {
+		return errors.New("connection refused")
+	})
+
+	isCalled := false
+	err := manager.call(func() error {
+		isCalled = true
+		return nil
+	})
+	if err != ErrCacheUnavailable || isCalled {
+		t.Errorf("call() = %v, called %v, want %v without calling Redis", err, isCalled, ErrCacheUnavailable)
+	}
+}
+
+func TestInvalidateWhileOpen(t *testing.T) {
+	tests := []struct {
+		name			string
+		maxPending		int
+		keys			[]string
+		pending			int
+		isOverflowed	bool
+	}{
+		{"keys are kept", 10, []string{"a", "b", "a"}, 2, false},
+		{"too many keys", 2, []string{"a", "b", "c"}, 2, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := newTestManager(1, test.maxPending)
+			_ = manager.call(func() error {
+				return errors.New("connection refused")
+			})
+
+			manager.Invalidate(test.keys...)
+			if len(manager.breaker.pending) != test.pending || manager.breaker.isOverflowed != test.isOverflowed {
+				t.Errorf("pending %d, overflowed %v, want %d, %v", len(manager.breaker.pending), manager.breaker.isOverflowed, test.pending, test.isOverflowed)
+			}
+		})
+	}
+}
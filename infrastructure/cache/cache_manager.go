@@ -6,53 +6,90 @@ import (
 	"g-tech.com/infrastructure/logger"
 	"g-tech.com/infrastructure/util"
 	"github.com/go-redis/redis"
-	"strings"
+	"math"
 	"time"
 )
 
+/*
+	Marker of a Sorted List written by ZReplace, ranked first (lowest score) and skipped by ZRevRange and ZCard
+ */
+var zMarker = redis.Z{Score: math.Inf(-1), Member: ""}
+
+/*
+	Add members (ARGV: maxLength then score, member pairs) to a Sorted List only if it exists,
+	then remove the lowest members above maxLength, the marker is kept (rank 0)
+ */
+var zAddBoundedScript = redis.NewScript(`if redis.call("EXISTS", KEYS[1]) == 0 then return 0 end
+for i = 2, #ARGV, 2 do redis.call("ZADD", KEYS[1], ARGV[i], ARGV[i + 1]) end
+local maxLength = tonumber(ARGV[1])
+if maxLength > 0 then redis.call("ZREMRANGEBYRANK", KEYS[1], 1, -(maxLength + 2)) end
+return 1`)
+
 type CacheManager struct {
-	Client *redis.Client
+	Client 		redis.UniversalClient
+	Expiry		ExpiryConfig
+	flight		*flightGroup
+	namespace	*namespace
+	breaker		*breaker
 }
 
 /**
  * Initializes cache
  */
-func (manager *CacheManager) Init(host string, poolSize int, minIdleConns int) {
-
-	manager.Client = redis.NewClient(&redis.Options{
-		Addr:     host,
-		Password: "", // no password set
-		DB:       0,  // use default DB
-		PoolSize: poolSize,
-		MinIdleConns: minIdleConns,
-	})
+func (manager *CacheManager) Init(config Config) error {
+	client, err := newClient(config)
+	if err != nil {
+		return err
+	}
 
+	manager.Client = client
+	manager.Expiry = loadExpiryConfig()
+	manager.flight = newFlightGroup()
+	manager.namespace = newNamespace()
+	manager.breaker = newBreaker()
+	return nil
 }
+
 /**
  * Delete item
  */
 func (manager *CacheManager) DeleteItem(key string) int64 {
-	err := manager.Client.Del(key).Val()
-	return err
+	var affected int64
+	manager.call(func() (err error) {
+		affected, err = manager.Client.Del(manager.key(key)).Result()
+		return err
+	})
+	return affected
 }
 /*
 * Push item to queue list
 */
 func (manager *CacheManager) PushItem(queueKey string, value interface{}) error{
-	err := manager.Client.RPush(queueKey, value).Err()
-	return err
+	return manager.call(func() error {
+		return manager.Client.RPush(manager.key(queueKey), value).Err()
+	})
 }
 /*
 * Pop item from queue list
 */
 func (manager *CacheManager) PopItem(queueKey string) (interface{}, error){
-	return manager.Client.LPop(queueKey).Result()
+	var value interface{}
+	err := manager.call(func() (err error) {
+		value, err = manager.Client.LPop(manager.key(queueKey)).Result()
+		return err
+	})
+	return value, err
 }
 /*
 * Get Score of Member in Sorted List
 */
 func (manager *CacheManager) ZGetScore(key string, member string) float64{
-	return manager.Client.ZScore(key, member).Val()
+	var score float64
+	manager.call(func() (err error) {
+		score, err = manager.Client.ZScore(manager.key(key), member).Result()
+		return err
+	})
+	return score
 }
 /*
 *
@@ -65,37 +102,139 @@ func (manager *CacheManager)ZRevRangeByScore(key string, startScore float64, int
 	}else{
 		max = "+inf"
 	}
-	return manager.Client.ZRevRangeByScoreWithScores(key, redis.ZRangeBy{
-		Max: max,
-		Offset: 0,
-		Count: intPageSize,
-	}).Result()
+
+	var members []redis.Z
+	err := manager.call(func() (err error) {
+		members, err = manager.Client.ZRevRangeByScoreWithScores(manager.key(key), redis.ZRangeBy{
+			Max: max,
+			Offset: 0,
+			Count: intPageSize,
+		}).Result()
+		return err
+	})
+	return members, err
+}
+/*
+* Increase score of member in Sorted List
+*/
+func (manager *CacheManager) ZIncrBy(key string, increment float64, member string) error {
+	return manager.call(func() error {
+		return manager.Client.ZIncrBy(manager.key(key), increment, member).Err()
+	})
+}
+/*
+* Set expiry of a key
+*/
+func (manager *CacheManager) Expire(key string, expireIn time.Duration) error {
+	return manager.call(func() error {
+		return manager.Client.Expire(manager.key(key), expireIn).Err()
+	})
+}
+/*
+* Replace all members of Sorted List in one transaction, readers never see it partly written
+* A marker of lowest score is added so a list without member is cached too, it is never read back
+*/
+func (manager *CacheManager) ZReplace(key string, expireIn time.Duration, members ...redis.Z) error {
+	return manager.call(func() error {
+		versionedKey := manager.key(key)
+		_, err := manager.Client.TxPipelined(func(pipe redis.Pipeliner) error {
+			pipe.Del(versionedKey)
+			pipe.ZAdd(versionedKey, append([]redis.Z{zMarker}, members...)...)
+			if expireIn > 0 {
+				pipe.Expire(versionedKey, expireIn)
+			}
+			return nil
+		})
+		return err
+	})
+}
+/*
+* Add members to a cached Sorted List then keep the members of highest scores only (maxLength, 0 means unbounded)
+* Nothing is added when the list is not cached, it would only hold the new members: the list is built from source on next read
+* Returns whether the list is cached
+*/
+func (manager *CacheManager) ZAddBounded(key string, maxLength int64, members ...redis.Z) (bool, error) {
+	args := []interface{}{maxLength}
+	for _, member := range members {
+		args = append(args, member.Score, member.Member)
+	}
+
+	var isCached bool
+	err := manager.call(func() error {
+		added, err := zAddBoundedScript.Run(manager.Client, []string{manager.key(key)}, args...).Int()
+		isCached = added == 1
+		return err
+	})
+	return isCached, err
+}
+/*
+* Get members of Sorted List by rank, highest score first
+*/
+func (manager *CacheManager) ZRevRange(key string, start int64, stop int64) ([]string, error) {
+	var members []string
+	err := manager.call(func() (err error) {
+		members, err = manager.Client.ZRevRangeByScore(manager.key(key), redis.ZRangeBy{
+			Max:	"+inf",
+			Min:	"(-inf",
+			Offset:	start,
+			Count:	stop - start + 1,
+		}).Result()
+		return err
+	})
+	return members, err
+}
+/*
+* Get number of members in Sorted List
+*/
+func (manager *CacheManager) ZCard(key string) (int64, error) {
+	var count int64
+	err := manager.call(func() (err error) {
+		count, err = manager.Client.ZCount(manager.key(key), "(-inf", "+inf").Result()
+		return err
+	})
+	return count, err
+}
+/*
+* Check if key exists
+*/
+func (manager *CacheManager) Exists(key string) (bool, error) {
+	var count int64
+	err := manager.call(func() (err error) {
+		count, err = manager.Client.Exists(manager.key(key)).Result()
+		return err
+	})
+	return count > 0, err
 }
 /*
 * Get Count of record by member in Sorted List
 */
 func (manager *CacheManager)ZGetCountByMember(key string, member string) (int64){
-	var score = manager.Client.ZScore(key, member).Val()
-
-	return manager.Client.ZCount(key, util.FloatToString(score), "+inf").Val()
+	var count int64
+	manager.call(func() (err error) {
+		versionedKey := manager.key(key)
+		score := manager.Client.ZScore(versionedKey, member).Val()
+		count, err = manager.Client.ZCount(versionedKey, util.FloatToString(score), "+inf").Result()
+		return err
+	})
+	return count
 }
 /*
 * Get a item from cache
 */
 func (manager *CacheManager) Get(key string) string {
-	value, err := manager.Client.Get(key).Result()
-	if err != nil && !strings.Contains(err.Error(), "redis: nil"){
-		logger.Error(err.Error())
-	}
-
+	value, _ := manager.GetWithError(key)
 	return value
 }
 /*
 * Get a item from cache with error
  */
 func (manager *CacheManager) GetWithError(key string) (string, error){
-	value, err := manager.Client.Get(key).Result()
-	if err != nil && !strings.Contains(err.Error(), "redis: nil"){
+	var value string
+	err := manager.call(func() (err error) {
+		value, err = manager.Client.Get(manager.key(key)).Result()
+		return err
+	})
+	if err != nil && err != redis.Nil && err != ErrCacheUnavailable {
 		logger.Error(err.Error())
 	}
 
@@ -106,15 +245,7 @@ func (manager *CacheManager) GetWithError(key string) (string, error){
 * Set a item to cache
 */
 func (manager *CacheManager) Set(key string, object interface{}, expireIn time.Duration){
-	out, err := json.Marshal(object)
-	if err != nil && !strings.Contains(err.Error(), "redis: nil") {
-		logger.Error(err.Error())
-	}
-
-	err = manager.Client.Set(key, out, expireIn).Err()
-	if err != nil {
-		logger.Error(err.Error())
-	}
+	manager.SetWithError(key, object, expireIn)
 }
 
 /*
@@ -122,34 +253,46 @@ func (manager *CacheManager) Set(key string, object interface{}, expireIn time.D
  */
 func (manager *CacheManager) SetWithError(key string, object interface{}, expireIn time.Duration) error{
 	out, err := json.Marshal(object)
-	if err != nil && !strings.Contains(err.Error(), "redis: nil") {
+	if err != nil {
 		logger.Error(err.Error())
 		return err
 	}
 
-	err = manager.Client.Set(key, out, expireIn).Err()
-	if err != nil {
+	err = manager.call(func() error {
+		return manager.Client.Set(manager.key(key), out, expireIn).Err()
+	})
+	if err != nil && err != ErrCacheUnavailable {
 		logger.Error(err.Error())
-		return err
 	}
-	return nil
+	return err
 }
 
 func (manager *CacheManager) RPush(key, value string) {
-	err := manager.Client.RPush(key, value).Err()
-	if err != nil && !strings.Contains(err.Error(), "redis: nil"){
+	err := manager.PushItem(key, value)
+	if err != nil && err != ErrCacheUnavailable {
 		logger.Error(err.Error())
 	}
 }
 
 func (manager *CacheManager) LPop(key string) interface{}{
-	return manager.Client.LPop(key)
+	if !manager.IsAvailable() {
+		return redis.NewStringResult("", ErrCacheUnavailable)
+	}
+	return manager.Client.LPop(manager.key(key))
 }
 
 func (manager *CacheManager) LGetFirst(key string) interface{}{
-	return manager.Client.LRange(key, 0, 0)
+	if !manager.IsAvailable() {
+		return redis.NewStringSliceResult(nil, ErrCacheUnavailable)
+	}
+	return manager.Client.LRange(manager.key(key), 0, 0)
 }
 
 func (manager *CacheManager) LGetAll(key string) []string{
-	return manager.Client.LRange(key, 0, -1).Val()
-}
\ No newline at end of file
+	var values []string
+	manager.call(func() (err error) {
+		values, err = manager.Client.LRange(manager.key(key), 0, -1).Result()
+		return err
+	})
+	return values
+}
@@ -0,0 +1,148 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"io/ioutil"
+	"strings"
+)
+
+const (
+	ModeSingle		= "single"
+	ModeSentinel	= "sentinel"
+	ModeCluster		= "cluster"
+)
+
+/*
+	Connection to Redis
+	+ single: Host
+	+ sentinel: Addrs are the sentinels, MasterName the monitored master
+	+ cluster: Addrs are seed nodes, DB is not supported
+ */
+type Config struct {
+	Mode				string
+	Host				string
+	Addrs				[]string
+	MasterName			string
+	Password			string
+	DB					int
+	PoolSize			int
+	MinIdleConns		int
+	TLSEnabled			bool
+	TLSCAFile			string
+	TLSCertFile			string
+	TLSKeyFile			string
+	TLSServerName		string
+	TLSSkipVerify		bool
+}
+
+func LoadConfig() Config {
+	viper.SetDefault("Redis.Mode", ModeSingle)
+	viper.SetDefault("Redis.DB", 0)
+	viper.SetDefault("Redis.PoolSize", 10)
+	viper.SetDefault("Redis.MinIdleConns", 0)
+
+	return Config{
+		Mode:				strings.ToLower(viper.GetString("Redis.Mode")),
+		Host:				viper.GetString("Redis.Host"),
+		Addrs:				viper.GetStringSlice("Redis.Addrs"),
+		MasterName:			viper.GetString("Redis.MasterName"),
+		Password:			viper.GetString("Redis.Password"),
+		DB:					viper.GetInt("Redis.DB"),
+		PoolSize:			viper.GetInt("Redis.PoolSize"),
+		MinIdleConns:		viper.GetInt("Redis.MinIdleConns"),
+		TLSEnabled:			viper.GetBool("Redis.TLS.Enabled"),
+		TLSCAFile:			viper.GetString("Redis.TLS.CAFile"),
+		TLSCertFile:		viper.GetString("Redis.TLS.CertFile"),
+		TLSKeyFile:			viper.GetString("Redis.TLS.KeyFile"),
+		TLSServerName:		viper.GetString("Redis.TLS.ServerName"),
+		TLSSkipVerify:		viper.GetBool("Redis.TLS.InsecureSkipVerify"),
+	}
+}
+
+/*
+	Create the client of the configured mode
+ */
+func newClient(config Config) (redis.UniversalClient, error) {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return nil, err
+	}
+
+	switch config.Mode {
+	case ModeSingle, "":
+		return redis.NewClient(&redis.Options{
+			Addr:			config.Host,
+			Password:		config.Password,
+			DB:				config.DB,
+			PoolSize:		config.PoolSize,
+			MinIdleConns:	config.MinIdleConns,
+			TLSConfig:		tlsConfig,
+		}), nil
+	case ModeSentinel:
+		if config.MasterName == "" || len(config.Addrs) == 0 {
+			return nil, errors.New("Redis sentinel requires MasterName and Addrs")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:		config.MasterName,
+			SentinelAddrs:	config.Addrs,
+			Password:		config.Password,
+			DB:				config.DB,
+			PoolSize:		config.PoolSize,
+			MinIdleConns:	config.MinIdleConns,
+			TLSConfig:		tlsConfig,
+		}), nil
+	case ModeCluster:
+		if len(config.Addrs) == 0 {
+			return nil, errors.New("Redis cluster requires Addrs")
+		}
+		if config.DB != 0 {
+			return nil, errors.New("Redis cluster supports DB 0 only")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:			config.Addrs,
+			Password:		config.Password,
+			PoolSize:		config.PoolSize,
+			MinIdleConns:	config.MinIdleConns,
+			TLSConfig:		tlsConfig,
+		}), nil
+	}
+
+	return nil, errors.Errorf("Unknown Redis mode %s", config.Mode)
+}
+
+func newTLSConfig(config Config) (*tls.Config, error) {
+	if !config.TLSEnabled {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		ServerName:			config.TLSServerName,
+		InsecureSkipVerify:	config.TLSSkipVerify,
+	}
+
+	if config.TLSCAFile != "" {
+		ca, err := ioutil.ReadFile(config.TLSCAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, errors.Errorf("No certificate found in %s", config.TLSCAFile)
+		}
+	}
+
+	// Client certificate, when the server requires it
+	if config.TLSCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(config.TLSCertFile, config.TLSKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	return tlsConfig, nil
+}
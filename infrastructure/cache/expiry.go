@@ -0,0 +1,62 @@
+package cache
+
+import (
+	"github.com/spf13/viper"
+	"math/rand"
+	"strings"
+	"time"
+)
+
+/*
+	Expiry of cached items
+	+ TTL: per key family (Prize, Vendor, UserWallet, ...), DefaultTTL when the family is not configured, 0 means no expiry
+	+ JitterPercent: TTL is randomly shortened or lengthened so keys cached together do not expire together
+	+ LockTimeout: how long a rebuild holds the Redis lock of a key
+ */
+type ExpiryConfig struct {
+	DefaultTTL		time.Duration
+	TTL				map[string]time.Duration
+	JitterPercent	int
+	LockTimeout		time.Duration
+}
+
+func loadExpiryConfig() ExpiryConfig {
+	viper.SetDefault("Cache.DefaultTTLSeconds", 3600)
+	viper.SetDefault("Cache.JitterPercent", 10)
+	viper.SetDefault("Cache.LockSeconds", 5)
+
+	// Instances must not share the same jitter
+	rand.Seed(time.Now().UnixNano())
+
+	// Viper keys are case insensitive (lower case)
+	ttl := make(map[string]time.Duration)
+	for family := range viper.GetStringMap("Cache.TTLSeconds") {
+		ttl[family] = time.Duration(viper.GetInt64("Cache.TTLSeconds." + family)) * time.Second
+	}
+
+	return ExpiryConfig{
+		DefaultTTL:		time.Duration(viper.GetInt64("Cache.DefaultTTLSeconds")) * time.Second,
+		TTL:			ttl,
+		JitterPercent:	viper.GetInt("Cache.JitterPercent"),
+		LockTimeout:	time.Duration(viper.GetInt64("Cache.LockSeconds")) * time.Second,
+	}
+}
+
+/*
+* Get expiry of a key family, jitter applied
+*/
+func (manager *CacheManager) ExpiryOf(family string) time.Duration {
+	ttl, existed := manager.Expiry.TTL[strings.ToLower(family)]
+	if !existed {
+		ttl = manager.Expiry.DefaultTTL
+	}
+	if ttl <= 0 || manager.Expiry.JitterPercent <= 0 {
+		return ttl
+	}
+
+	jitter := int64(ttl) * int64(manager.Expiry.JitterPercent) / 100
+	if jitter <= 0 {
+		return ttl
+	}
+	return ttl + time.Duration(rand.Int63n(2 * jitter + 1) - jitter)
+}
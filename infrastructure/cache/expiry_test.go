@@ -0,0 +1,47 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExpiryOf(t *testing.T) {
+	tests := []struct {
+		name		string
+		expiry		ExpiryConfig
+		family		string
+		min			time.Duration
+		max			time.Duration
+	}{
+		{"configured family", ExpiryConfig{DefaultTTL: time.Hour, TTL: map[string]time.Duration{"prize": time.Minute}}, "Prize", time.Minute, time.Minute},
+		{"default of a family not configured", ExpiryConfig{DefaultTTL: time.Hour, TTL: map[string]time.Duration{"prize": time.Minute}}, "Vendor", time.Hour, time.Hour},
+		{"no expiry", ExpiryConfig{TTL: map[string]time.Duration{"prize": 0}, JitterPercent: 10}, "Prize", 0, 0},
+		{"jitter within percent", ExpiryConfig{DefaultTTL: 100 * time.Second, JitterPercent: 10}, "Prize", 90 * time.Second, 110 * time.Second},
+		{"jitter smaller than a nanosecond", ExpiryConfig{DefaultTTL: 5, JitterPercent: 10}, "Prize", 5, 5},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			manager := CacheManager{Expiry: test.expiry}
+			for i := 0; i < 1000; i++ {
+				ttl := manager.ExpiryOf(test.family)
+				if ttl < test.min || ttl > test.max {
+					t.Fatalf("ExpiryOf(%q) = %v, want between %v and %v", test.family, ttl, test.min, test.max)
+				}
+			}
+		})
+	}
+}
+
+func TestExpiryOfIsSpread(t *testing.T) {
+	manager := CacheManager{Expiry: ExpiryConfig{DefaultTTL: time.Hour, JitterPercent: 10}}
+
+	ttls := make(map[time.Duration]bool)
+	for i := 0; i < 100; i++ {
+		ttls[manager.ExpiryOf("Prize")] = true
+	}
+	// Keys cached together must not expire together
+	if len(ttls) < 50 {
+		t.Errorf("%d distinct TTLs out of 100, want them spread", len(ttls))
+	}
+}
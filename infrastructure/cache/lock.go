@@ -0,0 +1,49 @@
+package cache
+
+import (
+	"g-tech.com/infrastructure/util"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+/*
+	Extend the lock only if it is still held by the caller
+ */
+var extendScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("PEXPIRE", KEYS[1], ARGV[2]) else return 0 end`)
+
+/*
+	Take a lock shared by every instance, for ttl at most
+	Returns the token to release or extend the lock, empty when the lock is held by another instance
+	Lock is not taken while Redis is unavailable
+ */
+func (manager *CacheManager) Lock(key string, ttl time.Duration) (string, error) {
+	token := util.NewUuid()
+
+	var acquired bool
+	err := manager.call(func() (err error) {
+		acquired, err = manager.Client.SetNX(key, token, ttl).Result()
+		return err
+	})
+	if err != nil || !acquired {
+		return "", err
+	}
+	return token, nil
+}
+
+/*
+	Extend a lock held by the caller, returns false when it was lost
+ */
+func (manager *CacheManager) ExtendLock(key string, token string, ttl time.Duration) (bool, error) {
+	var extended int64
+	err := manager.call(func() (err error) {
+		extended, err = extendScript.Run(manager.Client, []string{key}, token, int64(ttl / time.Millisecond)).Int64()
+		return err
+	})
+	return extended == 1, err
+}
+
+func (manager *CacheManager) Unlock(key string, token string) error {
+	return manager.call(func() error {
+		return unlockScript.Run(manager.Client, []string{key}, token).Err()
+	})
+}
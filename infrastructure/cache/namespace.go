@@ -0,0 +1,288 @@
+package cache
+
+import (
+	"fmt"
+	"g-tech.com/infrastructure/logger"
+	"github.com/go-redis/redis"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	namespacePrefix				= "hitvn_bk_minigame_cache_"
+	namespaceRefreshInterval	= time.Second
+	namespaceLoadFlightKey		= "namespace_versions"
+	scanBatchSize				= 500
+)
+
+/*
+	Keys of a registered family are stored under the current version of the family:
+		hitvn_bk_minigame_cache_<family>_v<version>:<key>
+	Resetting a family is a single version bump, keys of older versions are left to be purged
+ */
+type namespace struct {
+	mutex		sync.Mutex
+	families	map[string][]string		// Family -> key prefixes
+	versions	map[string]int64
+	loadedAt	time.Time
+	bumps		int64					// Versions bumped by this instance, a load started before a bump is discarded
+}
+
+func newNamespace() *namespace {
+	return &namespace{
+		families:	make(map[string][]string),
+		versions:	make(map[string]int64),
+	}
+}
+
+/*
+* Register a family of keys by their prefixes
+*/
+func (manager *CacheManager) RegisterFamily(family string, prefixes ...string) {
+	manager.namespace.mutex.Lock()
+	defer manager.namespace.mutex.Unlock()
+
+	manager.namespace.families[family] = prefixes
+	manager.namespace.loadedAt = time.Time{}
+}
+
+/*
+* Get registered families
+*/
+func (manager *CacheManager) Families() map[string][]string {
+	manager.namespace.mutex.Lock()
+	defer manager.namespace.mutex.Unlock()
+
+	families := make(map[string][]string)
+	for family, prefixes := range manager.namespace.families {
+		families[family] = prefixes
+	}
+	return families
+}
+
+/*
+* Invalidate every key of a family by bumping its version
+*/
+func (manager *CacheManager) BumpVersion(family string) (int64, error) {
+	var version int64
+	err := manager.call(func() (err error) {
+		version, err = manager.Client.Incr(versionKey(family)).Result()
+		return err
+	})
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+
+	manager.namespace.mutex.Lock()
+	manager.namespace.versions[family] = version
+	manager.namespace.bumps++
+	manager.namespace.mutex.Unlock()
+
+	return version, nil
+}
+
+/*
+* Delete keys of older versions of a family
+* progress is called after each batch with the number of keys scanned and deleted so far
+*/
+func (manager *CacheManager) PurgeStaleVersions(family string, progress func(scanned int64, deleted int64)) error {
+	current := manager.versionedPrefix(family, manager.version(family))
+	return manager.ScanDelete(namespacePrefix + family + "_v*", func(key string) bool {
+		return strings.HasPrefix(key, current) || key == versionKey(family)
+	}, progress)
+}
+
+/*
+* Delete keys matching a pattern, except the ones to keep (nil keeps nothing)
+* SCAN is used so Redis is never blocked
+*/
+func (manager *CacheManager) ScanDelete(pattern string, keep func(key string) bool, progress func(scanned int64, deleted int64)) error {
+	if !manager.IsAvailable() {
+		return ErrCacheUnavailable
+	}
+
+	var mutex sync.Mutex
+	var scanned, deleted int64
+	return manager.forEachNode(func(client redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, nextCursor, err := client.Scan(cursor, pattern, scanBatchSize).Result()
+			if err != nil {
+				logger.Error(err.Error())
+				return err
+			}
+
+			var staleKeys []string
+			for _, key := range keys {
+				if keep == nil || !keep(key) {
+					staleKeys = append(staleKeys, key)
+				}
+			}
+
+			// Keys are deleted one by one, they may belong to different cluster slots
+			var count int64
+			if len(staleKeys) > 0 {
+				cmds, err := client.Pipelined(func(pipe redis.Pipeliner) error {
+					for _, key := range staleKeys {
+						pipe.Del(key)
+					}
+					return nil
+				})
+				if err != nil {
+					logger.Error(err.Error())
+					return err
+				}
+				for _, cmd := range cmds {
+					count += cmd.(*redis.IntCmd).Val()
+				}
+			}
+
+			mutex.Lock()
+			scanned += int64(len(keys))
+			deleted += count
+			if progress != nil {
+				progress(scanned, deleted)
+			}
+			mutex.Unlock()
+
+			if nextCursor == 0 {
+				return nil
+			}
+			cursor = nextCursor
+		}
+	})
+}
+
+/*
+	Run on every node holding keys: each master of a cluster, the client itself otherwise
+ */
+func (manager *CacheManager) forEachNode(fn func(client redis.Cmdable) error) error {
+	if cluster, ok := manager.Client.(*redis.ClusterClient); ok {
+		return cluster.ForEachMaster(func(client *redis.Client) error {
+			return fn(client)
+		})
+	}
+	return fn(manager.Client)
+}
+
+/*
+	Key actually stored in Redis: versioned if it belongs to a registered family, unchanged otherwise
+ */
+func (manager *CacheManager) key(key string) string {
+	if manager.namespace == nil {
+		return key
+	}
+
+	family := manager.familyOf(key)
+	if family == "" {
+		return key
+	}
+	return manager.versionedPrefix(family, manager.version(family)) + key
+}
+
+func (manager *CacheManager) familyOf(key string) string {
+	manager.namespace.mutex.Lock()
+	defer manager.namespace.mutex.Unlock()
+
+	for family, prefixes := range manager.namespace.families {
+		for _, prefix := range prefixes {
+			if strings.HasPrefix(key, prefix) {
+				return family
+			}
+		}
+	}
+	return ""
+}
+
+func (manager *CacheManager) version(family string) int64 {
+	manager.refreshVersions()
+
+	manager.namespace.mutex.Lock()
+	defer manager.namespace.mutex.Unlock()
+
+	return manager.namespace.versions[family]
+}
+
+/*
+	Versions are bumped by any instance, they are reloaded from Redis at most once per interval
+	A single load runs at a time, concurrent callers wait for it instead of sending their own
+	Versions loaded before are kept while Redis is unavailable
+ */
+func (manager *CacheManager) refreshVersions() {
+	manager.namespace.mutex.Lock()
+	isStale := time.Since(manager.namespace.loadedAt) >= namespaceRefreshInterval && len(manager.namespace.families) > 0
+	manager.namespace.mutex.Unlock()
+	if !isStale {
+		return
+	}
+
+	load := func() error {
+		return manager.call(manager.loadVersions)
+	}
+	var err error
+	if manager.flight != nil {
+		err = manager.flight.Do(namespaceLoadFlightKey, load)
+	} else {
+		err = load()
+	}
+	if err != nil && err != ErrCacheUnavailable {
+		logger.Error(err.Error())
+	}
+}
+
+/*
+	Versions are read from Redis without holding the mutex, it is held only to swap them in
+ */
+func (manager *CacheManager) loadVersions() error {
+	manager.namespace.mutex.Lock()
+	var families, keys []string
+	for family := range manager.namespace.families {
+		families = append(families, family)
+		keys = append(keys, versionKey(family))
+	}
+	bumps := manager.namespace.bumps
+	manager.namespace.mutex.Unlock()
+
+	// Version keys may belong to different cluster slots, no MGET
+	cmds, err := manager.Client.Pipelined(func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Get(key)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return err
+	}
+
+	versions := make(map[string]int64)
+	for i, cmd := range cmds {
+		version, err := cmd.(*redis.StringCmd).Int64()
+		if err != nil {
+			version = 0
+		}
+		versions[families[i]] = version
+	}
+
+	manager.namespace.mutex.Lock()
+	defer manager.namespace.mutex.Unlock()
+
+	// A version bumped meanwhile is newer than the loaded one, next call loads again
+	if manager.namespace.bumps != bumps {
+		return nil
+	}
+	for family, version := range versions {
+		manager.namespace.versions[family] = version
+	}
+	manager.namespace.loadedAt = time.Now()
+	return nil
+}
+
+func (manager *CacheManager) versionedPrefix(family string, version int64) string {
+	return fmt.Sprintf("%s%s_v%d:", namespacePrefix, family, version)
+}
+
+func versionKey(family string) string {
+	return namespacePrefix + family + "_version"
+}
@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/util"
+	"github.com/go-redis/redis"
+	"sync"
+	"time"
+)
+
+const (
+	rebuildLockSuffix		= "_rebuild_lock"
+	rebuildWaitInterval		= 50 * time.Millisecond
+)
+
+/*
+	Release the lock only if it is still held by the caller (it may have expired and been taken by another instance)
+ */
+var unlockScript = redis.NewScript(`if redis.call("GET", KEYS[1]) == ARGV[1] then return redis.call("DEL", KEYS[1]) else return 0 end`)
+
+/*
+	Calls of the same key running in this process share one execution
+ */
+type flightGroup struct {
+	mutex	sync.Mutex
+	calls	map[string]*flightCall
+}
+
+type flightCall struct {
+	done	sync.WaitGroup
+	err		error
+}
+
+func newFlightGroup() *flightGroup {
+	return &flightGroup{calls: make(map[string]*flightCall)}
+}
+
+func (group *flightGroup) Do(key string, fn func() error) error {
+	group.mutex.Lock()
+	if call, existed := group.calls[key]; existed {
+		group.mutex.Unlock()
+		call.done.Wait()
+		return call.err
+	}
+	call := &flightCall{}
+	call.done.Add(1)
+	group.calls[key] = call
+	group.mutex.Unlock()
+
+	call.err = fn()
+	call.done.Done()
+
+	group.mutex.Lock()
+	delete(group.calls, key)
+	group.mutex.Unlock()
+
+	return call.err
+}
+
+/*
+* Rebuild a missed key, at most once at a time across instances
+* + in process: concurrent callers wait for the running rebuild
+* + across instances: a short Redis lock, callers failing to take it wait until the key is rebuilt or the lock expires
+* Callers read the key again afterward, and fall back to MySql if it is still missing
+*/
+func (manager *CacheManager) Rebuild(key string, rebuild func() error) error {
+	if manager.flight == nil {
+		return rebuild()
+	}
+	// Rebuilt cache could not be read anyway
+	if !manager.IsAvailable() {
+		return ErrCacheUnavailable
+	}
+
+	versionedKey := manager.key(key)
+	return manager.flight.Do(versionedKey, func() error {
+		lockKey := versionedKey + rebuildLockSuffix
+		token := util.NewUuid()
+
+		var acquired bool
+		err := manager.call(func() (err error) {
+			acquired, err = manager.Client.SetNX(lockKey, token, manager.Expiry.LockTimeout).Result()
+			return err
+		})
+		if err != nil {
+			logger.Error(err.Error())
+			return err
+		}
+		if !acquired {
+			manager.waitForRebuild(versionedKey, lockKey)
+			return nil
+		}
+
+		defer func() {
+			err := manager.call(func() error {
+				return unlockScript.Run(manager.Client, []string{lockKey}, token).Err()
+			})
+			if err != nil {
+				logger.Error(err.Error())
+			}
+		}()
+		return rebuild()
+	})
+}
+
+func (manager *CacheManager) waitForRebuild(key string, lockKey string) {
+	deadline := time.Now().Add(manager.Expiry.LockTimeout)
+	for time.Now().Before(deadline) && manager.IsAvailable() {
+		time.Sleep(rebuildWaitInterval)
+
+		isRebuilt, err := manager.Client.Exists(key).Result()
+		if err != nil || isRebuilt > 0 {
+			return
+		}
+		// Lock released without rebuilding
+		isLocked, err := manager.Client.Exists(lockKey).Result()
+		if err != nil || isLocked == 0 {
+			return
+		}
+	}
+}
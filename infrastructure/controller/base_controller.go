@@ -1,10 +1,15 @@
 package controller
 
 import (
+	"encoding/csv"
+	"fmt"
+	"g-tech.com/constant"
 	"g-tech.com/infrastructure/response"
 	"github.com/labstack/echo"
 	"gopkg.in/go-playground/validator.v9"
 	"net/http"
+	"strconv"
+	"strings"
 )
 
 type BaseController struct {
@@ -43,6 +48,23 @@ func (controller *BaseController) WriteSuccessEmptyContent(c echo.Context) error
 	return c.JSON(http.StatusOK, response)
 }
 
+/**
+ * Returns records as a CSV file to download
+ */
+func (controller *BaseController) WriteCSV(c echo.Context, fileName string, records [][]string) error {
+	c.Response().Header().Set(echo.HeaderContentDisposition, fmt.Sprintf("attachment; filename=%q", fileName))
+	c.Response().Header().Set(echo.HeaderContentType, "text/csv; charset=utf-8")
+	c.Response().WriteHeader(http.StatusOK)
+
+	// BOM, spreadsheet applications need it to read UTF-8
+	_, err := c.Response().Write([]byte("\xEF\xBB\xBF"))
+	if err != nil {
+		return err
+	}
+
+	return csv.NewWriter(c.Response()).WriteAll(records)
+}
+
 /**
  * Returns an error as bad request (client-side error)
  */
@@ -50,6 +72,13 @@ func (controller *BaseController) WriteBadRequest(c echo.Context, message string
 	return controller.writeError(c, http.StatusBadRequest, message, errorRes)
 }
 
+/**
+ * Returns an error as unauthorized (client-side error)
+ */
+func (controller *BaseController) WriteUnauthorized(c echo.Context, message string, errorRes response.ErrorResponse)  error {
+	return controller.writeError(c, http.StatusUnauthorized, message, errorRes)
+}
+
 /**
  * Return an error as NotFound (client-side error
  */
@@ -100,3 +129,48 @@ func (controller *BaseController) IsValid(m interface{}) (bool, error) {
 	return true, nil
 }
 
+
+/**
+ * Returns pageSize and pageIndex of query parameters
+ * Missing: pageSize is DefaultPageSize, pageIndex is 1. pageSize is at most MaximumPageSize
+ */
+func (controller *BaseController) GetPaging(c echo.Context) (int, int, error) {
+	pageSize, err := queryPositiveInt(c, "pageSize", constant.DefaultPageSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	if pageSize > constant.MaximumPageSize {
+		pageSize = constant.MaximumPageSize
+	}
+
+	pageIndex, err := queryPositiveInt(c, "pageIndex", 1)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return pageSize, pageIndex, nil
+}
+
+/**
+ * Returns id of the user authenticated by the gateway (header X-User-Id)
+ */
+func (controller *BaseController) GetAuthenticatedUserId(c echo.Context) (string, error) {
+	userId := strings.TrimSpace(c.Request().Header.Get(constant.HeaderUserId))
+	if userId == "" {
+		return "", fmt.Errorf("%s header is missing", constant.HeaderUserId)
+	}
+	return userId, nil
+}
+
+func queryPositiveInt(c echo.Context, name string, defaultValue int) (int, error) {
+	param := c.QueryParam(name)
+	if param == "" {
+		return defaultValue, nil
+	}
+
+	value, err := strconv.Atoi(param)
+	if err != nil || value < 1 {
+		return 0, fmt.Errorf("%s must be a number from 1", name)
+	}
+	return value, nil
+}
@@ -1,6 +1,7 @@
 package util
 
 import (
+	"errors"
 	"fmt"
 	"github.com/speps/go-hashids"
 	"strconv"
@@ -11,25 +12,62 @@ const (
 	Salt 					= "hit.vn"
 	InvitedCodeMinLength 	= 8
 	MobileCartMinLength 	= 20
+
+	DefaultCountryCode		= "84"		// Viet Nam
+	PhoneNumberMinDigits	= 8
+	PhoneNumberMaxDigits	= 15		// E.164
 )
 
+var ErrInvalidPhoneNumber = errors.New("Invalid phone number")
+
+/**
+ * Converts a phone number to E.164 format (+<country code><subscriber number>)
+ * Accepts:
+ * 	+<country code>..., 00<country code>...
+ * 	0... (Vietnamese local format, trunk prefix 0)
+ * 	9 digits without prefix (Vietnamese subscriber number)
+ * 	<country code>... without +
+ */
+func NormalizePhoneNumber(phoneNumber string) (string, error) {
+	replacer := strings.NewReplacer(" ", "", "-", "", ".", "", "(", "", ")", "")
+	number := replacer.Replace(strings.TrimSpace(phoneNumber))
+
+	switch {
+	case strings.HasPrefix(number, "+"):
+		number = number[1:]
+	case strings.HasPrefix(number, "00"):
+		number = number[2:]
+	case strings.HasPrefix(number, "0"):
+		number = DefaultCountryCode + number[1:]
+	case len(number) == 9:
+		number = DefaultCountryCode + number
+	}
 
-func checkPhoneNumberValid(phoneNumber string) bool {
-	if len(phoneNumber) != 12 {
-		return false
+	// +84 0912... : trunk prefix written after country code
+	if strings.HasPrefix(number, DefaultCountryCode + "0") {
+		number = DefaultCountryCode + number[len(DefaultCountryCode) + 1:]
 	}
 
-	if !strings.HasPrefix(phoneNumber, "+"){
-		return false
+	if len(number) < PhoneNumberMinDigits || len(number) > PhoneNumberMaxDigits || number[0] == '0' {
+		return "", ErrInvalidPhoneNumber
+	}
+	for _, c := range number {
+		if c < '0' || c > '9' {
+			return "", ErrInvalidPhoneNumber
+		}
 	}
 
-	return true
+	return "+" + number, nil
 }
 
+/**
+ * Encodes invited code from phone number
+ * Any format of a number gives the same code, so codes issued before stay unchanged
+ */
 func EncodeInvitedCode(phoneNumber string) (string, error){
-	ok := checkPhoneNumberValid(phoneNumber)
-	if ok == false {
-		return "", nil
+	phoneNumber, err := NormalizePhoneNumber(phoneNumber)
+	if err != nil {
+		return "", err
 	}
 
 	hd := hashids.NewData()
@@ -0,0 +1,65 @@
+package util
+
+import "testing"
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	tests := []struct {
+		phoneNumber		string
+		normalized		string
+		err				error
+	}{
+		{"+84912345678", "+84912345678", nil},
+		{"0084912345678", "+84912345678", nil},
+		{"0912345678", "+84912345678", nil},
+		{"912345678", "+84912345678", nil},
+		{"84912345678", "+84912345678", nil},
+		{"+84 0912345678", "+84912345678", nil},
+		{" +84 (91) 234-56.78 ", "+84912345678", nil},
+		{"+6591234567", "+6591234567", nil},
+		{"", "", ErrInvalidPhoneNumber},
+		{"0", "", ErrInvalidPhoneNumber},
+		{"+1234567", "", ErrInvalidPhoneNumber},
+		{"+1234567890123456", "", ErrInvalidPhoneNumber},
+		{"+0912345678", "", ErrInvalidPhoneNumber},
+		{"09123abc78", "", ErrInvalidPhoneNumber},
+	}
+
+	for _, test := range tests {
+		t.Run(test.phoneNumber, func(t *testing.T) {
+			normalized, err := NormalizePhoneNumber(test.phoneNumber)
+			if normalized != test.normalized || err != test.err {
+				t.Errorf("NormalizePhoneNumber(%q) = %q, %v, want %q, %v", test.phoneNumber, normalized, err, test.normalized, test.err)
+			}
+		})
+	}
+}
+
+/*
+	Codes issued before numbers were normalized (E.164 only) must not change
+ */
+func TestEncodeInvitedCodeIsStable(t *testing.T) {
+	tests := []struct {
+		phoneNumbers	[]string
+		code			string
+	}{
+		{[]string{"+84912345678", "0912345678", "912345678", "0084912345678", "+84 0912 345 678"}, "QpoAWao3"},
+		{[]string{"+84389000111", "0389000111", "84389000111"}, "e9BvO94v"},
+		{[]string{"+6591234567", "006591234567"}, "DZX045JY"},
+	}
+
+	for _, test := range tests {
+		for _, phoneNumber := range test.phoneNumbers {
+			t.Run(phoneNumber, func(t *testing.T) {
+				code, err := EncodeInvitedCode(phoneNumber)
+				if err != nil || code != test.code {
+					t.Errorf("EncodeInvitedCode(%q) = %q, %v, want %q", phoneNumber, code, err, test.code)
+				}
+			})
+		}
+	}
+
+	_, err := EncodeInvitedCode("not a number")
+	if err != ErrInvalidPhoneNumber {
+		t.Errorf("EncodeInvitedCode of an invalid number: err = %v, want %v", err, ErrInvalidPhoneNumber)
+	}
+}
@@ -0,0 +1,85 @@
+package util
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+)
+
+/*
+	Read first column of a CSV, empty values are skipped
+	First row is skipped when it is a header (equals to header, case insensitive)
+ */
+func ReadCSVColumn(reader io.Reader, header string) ([]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	var values []string
+	for row := 0; ; row++ {
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) == 0 {
+			continue
+		}
+
+		value := strings.TrimSpace(record[0])
+		if value == "" || (row == 0 && strings.EqualFold(value, header)) {
+			continue
+		}
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+/*
+	Read rows of a CSV by column name, the first row is the header
+	Rows are keyed by lower case column names, every column in required must be present
+ */
+func ReadCSVRecords(reader io.Reader, required []string) ([]map[string]string, error) {
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	csvReader.TrimLeadingSpace = true
+
+	header, err := csvReader.Read()
+	if err != nil {
+		return nil, err
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range required {
+		if _, existed := columns[strings.ToLower(name)]; !existed {
+			return nil, fmt.Errorf("Column %s is missing", name)
+		}
+	}
+
+	var records []map[string]string
+	for {
+		row, err := csvReader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		record := make(map[string]string)
+		for name, index := range columns {
+			if index < len(row) {
+				record[name] = strings.TrimSpace(row[index])
+			}
+		}
+		records = append(records, record)
+	}
+
+	return records, nil
+}
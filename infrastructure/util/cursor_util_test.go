@@ -0,0 +1,64 @@
+package util
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []struct {
+		name	string
+		time	time.Time
+		id		string
+	}{
+		{"uuid", time.Date(2020, 5, 17, 8, 30, 15, 0, time.UTC), "3f2504e0-4f89-11d3-9a0c-0305e82c3301"},
+		{"local time is returned in UTC", time.Date(2020, 5, 17, 15, 30, 15, 0, time.FixedZone("ICT", 7 * 3600)), "a"},
+		{"id with separator", time.Unix(1589704215, 0).UTC(), "a|b"},
+		{"before 1970", time.Date(1969, 12, 31, 23, 59, 59, 0, time.UTC), "old"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decodedTime, decodedId, err := DecodeCursor(EncodeCursor(test.time, test.id))
+			if err != nil || !decodedTime.Equal(test.time) || decodedTime.Location() != time.UTC || decodedId != test.id {
+				t.Errorf("DecodeCursor(EncodeCursor(%v, %q)) = %v, %q, %v", test.time, test.id, decodedTime, decodedId, err)
+			}
+		})
+	}
+}
+
+func TestCursorDropsSubSeconds(t *testing.T) {
+	decodedTime, _, err := DecodeCursor(EncodeCursor(time.Unix(1589704215, 999999999), "a"))
+	if err != nil || decodedTime.Unix() != 1589704215 || decodedTime.Nanosecond() != 0 {
+		t.Errorf("decoded time = %v, %v, want %v", decodedTime, err, time.Unix(1589704215, 0).UTC())
+	}
+}
+
+func TestDecodeInvalidCursor(t *testing.T) {
+	encode := func(s string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(s))
+	}
+
+	tests := []struct {
+		name	string
+		cursor	string
+	}{
+		{"empty", ""},
+		{"not base64", "%%%"},
+		{"padded base64", base64.URLEncoding.EncodeToString([]byte("1589704215|ab"))},
+		{"no separator", encode("1589704215")},
+		{"no id", encode("1589704215|")},
+		{"time is not a number", encode("yesterday|a")},
+		{"no time", encode("|a")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, _, err := DecodeCursor(test.cursor)
+			if err != ErrInvalidCursor {
+				t.Errorf("DecodeCursor(%q) err = %v, want %v", test.cursor, err, ErrInvalidCursor)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package migration
+
+/*
+	Tables the application was built on, before migrations were kept in the repository
+	+ uuid_to_bin is native since MySql 8 (required for SKIP LOCKED), uuid_from_bin is its inverse
+	+ sso_user belongs to the SSO service, it is created here for a database of its own (development, tests)
+	+ programs (user_prize) are configured from prize-management, only spending records are seeded by later migrations
+	A database created before is recorded with migrate baseline, this migration cannot be reverted
+ */
+var baseline = Migration{
+	Version:	0,
+	Name:		"baseline",
+	Up: []string{
+		`DROP FUNCTION IF EXISTS uuid_from_bin;`,
+		`CREATE FUNCTION uuid_from_bin(_bin BINARY(16)) RETURNS CHAR(36)
+			DETERMINISTIC NO SQL SQL SECURITY INVOKER
+			RETURN BIN_TO_UUID(_bin);`,
+
+		`CREATE TABLE IF NOT EXISTS sso_user (
+			Id				BINARY(16)		NOT NULL,
+			PhoneNumber		VARCHAR(32)		NULL,
+			Code			VARCHAR(32)		NULL,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			KEY IX_SsoUser_PhoneNumber (PhoneNumber)
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS user_prize (
+			Id				BINARY(16)		NOT NULL,
+			Name			VARCHAR(64)		NOT NULL,
+			Value			INT				NOT NULL DEFAULT 0,
+			Description		VARCHAR(255)	NOT NULL DEFAULT '',
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			UNIQUE KEY UX_UserPrize_Name (Name)
+		);`,
+
+		// Earning (Value > 0) and spending (Value < 0) records of users
+		`CREATE TABLE IF NOT EXISTS user_wallet (
+			Id				BINARY(16)		NOT NULL,
+			UserId			BINARY(16)		NOT NULL,
+			PrizeId			BINARY(16)		NOT NULL,
+			Value			INT				NOT NULL,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			KEY IX_UserWallet_UserId (UserId)
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS game_inviting (
+			Id				BINARY(16)		NOT NULL,
+			InvitingUser	BINARY(16)		NOT NULL,
+			InvitedUser		BINARY(16)		NOT NULL,
+			WalletId		BINARY(16)		NULL,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id)
+		);`,
+
+		// LastUpdatedAt is the time of reading
+		`CREATE TABLE IF NOT EXISTS game_read_daily (
+			Id				BINARY(16)		NOT NULL,
+			UserId			BINARY(16)		NOT NULL,
+			WalletId		BINARY(16)		NULL,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			KEY IX_GameReadDaily_UserId (UserId, LastUpdatedAt)
+		);`,
+
+		// WalletId is set once the number won
+		`CREATE TABLE IF NOT EXISTS game_lottery (
+			Id				BINARY(16)		NOT NULL,
+			UserId			BINARY(16)		NOT NULL,
+			NumberSelected	VARCHAR(8)		NOT NULL,
+			Date			DATE			NOT NULL,
+			WalletId		BINARY(16)		NULL,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			KEY IX_GameLottery_Date (Date),
+			KEY IX_GameLottery_UserId (UserId, Date)
+		);`,
+
+		`CREATE TABLE IF NOT EXISTS mobile_card_vendor (
+			Id				BINARY(16)		NOT NULL,
+			Name			VARCHAR(64)		NOT NULL,
+			VendorCode		VARCHAR(32)		NOT NULL,
+			Status			TINYINT			NOT NULL DEFAULT 0,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			UNIQUE KEY UX_MobileCardVendor_VendorCode (VendorCode)
+		);`,
+
+		// Serial and Code are encoded (util.EncodeMobileCard)
+		`CREATE TABLE IF NOT EXISTS mobile_card (
+			Id				BINARY(16)		NOT NULL,
+			VendorCode		VARCHAR(32)		NOT NULL,
+			Serial			VARCHAR(255)	NOT NULL,
+			Code			VARCHAR(255)	NOT NULL,
+			Value			INT				NOT NULL,
+			Status			TINYINT			NOT NULL DEFAULT 0,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			KEY IX_MobileCard_Serial (VendorCode, Serial),
+			KEY IX_MobileCard_Status (VendorCode, Status, Value)
+		);`,
+
+		// Cards bought by users
+		`CREATE TABLE IF NOT EXISTS game_mobile_card (
+			Id				BINARY(16)		NOT NULL,
+			UserId			BINARY(16)		NOT NULL,
+			MobileCardId	BINARY(16)		NOT NULL,
+			WalletId		BINARY(16)		NULL,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			KEY IX_GameMobileCard_UserId (UserId, CreatedAt)
+		);`,
+	},
+}
@@ -0,0 +1,38 @@
+package migration
+
+/*
+	Fraud scoring for invitations.
+	One row per accepted invitation code; suspicious ones keep their rewards on hold until reviewed.
+ */
+var invitationFraud = Migration{
+	Version:	1,
+	Name:		"invitation_fraud",
+	Up: []string{
+		`CREATE TABLE IF NOT EXISTS game_inviting_fraud (
+			Id				BINARY(16)		NOT NULL,
+			WalletId		BINARY(16)		NOT NULL,
+			InvitingUser	BINARY(16)		NOT NULL,
+			InvitedUser		BINARY(16)		NOT NULL,
+			DeviceId		VARCHAR(128)	NOT NULL DEFAULT '',
+			IpAddress		VARCHAR(64)		NOT NULL DEFAULT '',
+			Score			INT				NOT NULL DEFAULT 0,
+			Reasons			VARCHAR(255)	NOT NULL DEFAULT '',
+			Status			TINYINT			NOT NULL DEFAULT 0,
+			InvitingPrizeId	BINARY(16)		NULL,
+			InvitingValue	INT				NOT NULL DEFAULT 0,
+			InvitedPrizeId	BINARY(16)		NULL,
+			InvitedValue	INT				NOT NULL DEFAULT 0,
+			ReviewedBy		VARCHAR(64)		NOT NULL DEFAULT '',
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			KEY IX_GameInvitingFraud_DeviceId (DeviceId),
+			KEY IX_GameInvitingFraud_IpAddress (IpAddress, CreatedAt),
+			KEY IX_GameInvitingFraud_InvitingUser (InvitingUser, CreatedAt),
+			KEY IX_GameInvitingFraud_Status (Status, CreatedAt)
+		);`,
+	},
+	Down: []string{
+		`DROP TABLE IF EXISTS game_inviting_fraud;`,
+	},
+}
@@ -0,0 +1,31 @@
+package migration
+
+/*
+	Reward of inviting user is settled once invited user completes qualifying actions.
+	Existing invitations have already been paid, so they default to settled (1).
+	InvitingWalletId is the credit of the inviting user created by the settlement, WalletId stays the credit of the invited user.
+ */
+var deferredInvitingReward = Migration{
+	Version:	2,
+	Name:		"deferred_inviting_reward",
+	Up: []string{
+		`ALTER TABLE game_inviting
+			ADD COLUMN Status			TINYINT		NOT NULL DEFAULT 1,
+			ADD COLUMN InvitingPrizeId	BINARY(16)	NULL,
+			ADD COLUMN InvitingValue	INT			NOT NULL DEFAULT 0,
+			ADD COLUMN ExpiredAt		DATETIME	NULL,
+			ADD COLUMN InvitingWalletId	BINARY(16)	NULL,
+			ADD KEY IX_GameInviting_Status (Status, ExpiredAt),
+			ADD KEY IX_GameInviting_InvitingWalletId (InvitingWalletId);`,
+	},
+	Down: []string{
+		`ALTER TABLE game_inviting
+			DROP KEY IX_GameInviting_InvitingWalletId,
+			DROP KEY IX_GameInviting_Status,
+			DROP COLUMN Status,
+			DROP COLUMN InvitingPrizeId,
+			DROP COLUMN InvitingValue,
+			DROP COLUMN ExpiredAt,
+			DROP COLUMN InvitingWalletId;`,
+	},
+}
@@ -0,0 +1,23 @@
+package migration
+
+/*
+	Second-level commission paid to the inviter of the inviting user when an invitation is settled.
+ */
+var referralCommission = Migration{
+	Version:	3,
+	Name:		"referral_commission",
+	Up: []string{
+		`ALTER TABLE game_inviting
+			ADD COLUMN CommissionUser		BINARY(16)	NULL,
+			ADD COLUMN CommissionWalletId	BINARY(16)	NULL,
+			ADD KEY IX_GameInviting_InvitingUser (InvitingUser, CreatedAt),
+			ADD KEY IX_GameInviting_InvitedUser (InvitedUser);`,
+	},
+	Down: []string{
+		`ALTER TABLE game_inviting
+			DROP KEY IX_GameInviting_InvitingUser,
+			DROP KEY IX_GameInviting_InvitedUser,
+			DROP COLUMN CommissionUser,
+			DROP COLUMN CommissionWalletId;`,
+	},
+}
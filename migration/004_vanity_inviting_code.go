@@ -0,0 +1,22 @@
+package migration
+
+/*
+	Codes can be chosen by users, they must stay unique.
+	Duplicated codes are cleared first, the oldest user keeps it and the others have their code generated again on next lookup.
+ */
+var vanityInvitingCode = Migration{
+	Version:	4,
+	Name:		"vanity_inviting_code",
+	Up: []string{
+		`UPDATE sso_user SET Code = NULL WHERE Code = '';`,
+		`UPDATE sso_user AS duplicated
+			INNER JOIN sso_user AS kept ON kept.Code = duplicated.Code
+				AND (kept.CreatedAt < duplicated.CreatedAt OR (kept.CreatedAt = duplicated.CreatedAt AND kept.Id < duplicated.Id))
+			SET duplicated.Code = NULL;`,
+		`ALTER TABLE sso_user
+			ADD UNIQUE KEY UX_SsoUser_Code (Code);`,
+	},
+	Down: []string{
+		`ALTER TABLE sso_user DROP KEY UX_SsoUser_Code;`,
+	},
+}
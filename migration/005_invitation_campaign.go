@@ -0,0 +1,36 @@
+package migration
+
+/*
+	Time-boxed invitation campaigns overriding the Inviting/Invited rewards.
+ */
+var invitationCampaign = Migration{
+	Version:	5,
+	Name:		"invitation_campaign",
+	Up: []string{
+		`CREATE TABLE IF NOT EXISTS game_inviting_campaign (
+			Id							BINARY(16)		NOT NULL,
+			Name						VARCHAR(255)	NOT NULL,
+			Code						VARCHAR(32)		NOT NULL DEFAULT '',
+			StartDate					DATETIME		NOT NULL,
+			EndDate						DATETIME		NOT NULL,
+			InvitingValue				INT				NOT NULL DEFAULT 0,
+			InvitedValue				INT				NOT NULL DEFAULT 0,
+			MaxInvitationsPerInviter	INT				NOT NULL DEFAULT 0,
+			Status						TINYINT			NOT NULL DEFAULT 0,
+			CreatedAt					DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt				DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			KEY IX_GameInvitingCampaign_Code (Code, Status, StartDate, EndDate)
+		);`,
+
+		`ALTER TABLE game_inviting
+			ADD COLUMN CampaignId	BINARY(16)	NULL,
+			ADD KEY IX_GameInviting_CampaignId (CampaignId, InvitingUser);`,
+	},
+	Down: []string{
+		`ALTER TABLE game_inviting
+			DROP KEY IX_GameInviting_CampaignId,
+			DROP COLUMN CampaignId;`,
+		`DROP TABLE IF EXISTS game_inviting_campaign;`,
+	},
+}
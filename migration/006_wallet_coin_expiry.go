@@ -0,0 +1,31 @@
+package migration
+
+/*
+	Coins expire per earning program (config section "Wallet").
+	RemainingValue is what is left of an earning record once spending (FIFO) and expiry are applied.
+	Records created before this change are not tracked (RemainingValue = 0, ExpiredAt = NULL) and never expire.
+	Down keeps the seeded program, wallet records may refer to it
+ */
+var walletCoinExpiry = Migration{
+	Version:	6,
+	Name:		"wallet_coin_expiry",
+	Up: []string{
+		`ALTER TABLE user_wallet
+			ADD COLUMN RemainingValue	INT			NOT NULL DEFAULT 0,
+			ADD COLUMN ExpiredAt		DATETIME	NULL,
+			ADD KEY IX_UserWallet_ExpiredAt (ExpiredAt, RemainingValue),
+			ADD KEY IX_UserWallet_UserId_RemainingValue (UserId, RemainingValue);`,
+
+		// Spending record added by the expiry job
+		`INSERT INTO user_prize(Id, Name, Value, Description)
+			SELECT uuid_to_bin(UUID()), 'CoinExpired', 0, 'Xu hết hạn' FROM DUAL
+			WHERE NOT EXISTS (SELECT Id FROM user_prize WHERE Name = 'CoinExpired');`,
+	},
+	Down: []string{
+		`ALTER TABLE user_wallet
+			DROP KEY IX_UserWallet_ExpiredAt,
+			DROP KEY IX_UserWallet_UserId_RemainingValue,
+			DROP COLUMN RemainingValue,
+			DROP COLUMN ExpiredAt;`,
+	},
+}
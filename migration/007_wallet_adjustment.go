@@ -0,0 +1,34 @@
+package migration
+
+/*
+	Manual credits/debits made by operators for support cases, kept as audit trail.
+	Down keeps the seeded program, wallet records may refer to it
+ */
+var walletAdjustment = Migration{
+	Version:	7,
+	Name:		"wallet_adjustment",
+	Up: []string{
+		`CREATE TABLE IF NOT EXISTS user_wallet_adjustment (
+			Id					BINARY(16)		NOT NULL,
+			WalletId			BINARY(16)		NOT NULL,
+			UserId				BINARY(16)		NOT NULL,
+			Value				INT				NOT NULL,
+			Reason				VARCHAR(255)	NOT NULL,
+			TicketReference		VARCHAR(64)		NOT NULL DEFAULT '',
+			CreatedBy			VARCHAR(64)		NOT NULL,
+			BatchId				BINARY(16)		NULL,
+			CreatedAt			DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			KEY IX_UserWalletAdjustment_UserId (UserId, CreatedAt),
+			KEY IX_UserWalletAdjustment_BatchId (BatchId),
+			KEY IX_UserWalletAdjustment_CreatedAt (CreatedAt)
+		);`,
+
+		`INSERT INTO user_prize(Id, Name, Value, Description)
+			SELECT uuid_to_bin(UUID()), 'AdminAdjustment', 0, 'Điều chỉnh xu' FROM DUAL
+			WHERE NOT EXISTS (SELECT Id FROM user_prize WHERE Name = 'AdminAdjustment');`,
+	},
+	Down: []string{
+		`DROP TABLE IF EXISTS user_wallet_adjustment;`,
+	},
+}
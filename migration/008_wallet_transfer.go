@@ -0,0 +1,37 @@
+package migration
+
+/*
+	Coins gifted between users, each transfer is a pair of user_wallet records (debit of sender, credit of recipient).
+	Down keeps the seeded programs, wallet records may refer to them
+ */
+var walletTransfer = Migration{
+	Version:	8,
+	Name:		"wallet_transfer",
+	Up: []string{
+		`CREATE TABLE IF NOT EXISTS user_wallet_transfer (
+			Id					BINARY(16)		NOT NULL,
+			SenderId			BINARY(16)		NOT NULL,
+			RecipientId			BINARY(16)		NOT NULL,
+			SenderWalletId		BINARY(16)		NOT NULL,
+			RecipientWalletId	BINARY(16)		NOT NULL,
+			Value				INT				NOT NULL,
+			Message				VARCHAR(255)	NOT NULL DEFAULT '',
+			CreatedAt			DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (Id),
+			UNIQUE KEY UX_UserWalletTransfer_SenderWalletId (SenderWalletId),
+			UNIQUE KEY UX_UserWalletTransfer_RecipientWalletId (RecipientWalletId),
+			KEY IX_UserWalletTransfer_SenderId (SenderId, CreatedAt)
+		);`,
+
+		`INSERT INTO user_prize(Id, Name, Value, Description)
+			SELECT uuid_to_bin(UUID()), 'TransferSent', 0, 'Tặng xu' FROM DUAL
+			WHERE NOT EXISTS (SELECT Id FROM user_prize WHERE Name = 'TransferSent');`,
+
+		`INSERT INTO user_prize(Id, Name, Value, Description)
+			SELECT uuid_to_bin(UUID()), 'TransferReceived', 0, 'Nhận xu được tặng' FROM DUAL
+			WHERE NOT EXISTS (SELECT Id FROM user_prize WHERE Name = 'TransferReceived');`,
+	},
+	Down: []string{
+		`DROP TABLE IF EXISTS user_wallet_transfer;`,
+	},
+}
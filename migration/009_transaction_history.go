@@ -0,0 +1,39 @@
+package migration
+
+/*
+	Keyset pagination of transaction history and lookup of the record referenced by a transaction.
+ */
+var transactionHistory = Migration{
+	Version:	9,
+	Name:		"transaction_history",
+	Up: []string{
+		`ALTER TABLE user_wallet
+			ADD KEY IX_UserWallet_UserId_LastUpdatedAt (UserId, LastUpdatedAt, Id);`,
+
+		`ALTER TABLE game_mobile_card
+			ADD KEY IX_GameMobileCard_WalletId (WalletId);`,
+
+		`ALTER TABLE game_lottery
+			ADD KEY IX_GameLottery_WalletId (WalletId);`,
+
+		`ALTER TABLE game_read_daily
+			ADD KEY IX_GameReadDaily_WalletId (WalletId);`,
+
+		`ALTER TABLE game_inviting
+			ADD KEY IX_GameInviting_WalletId (WalletId),
+			ADD KEY IX_GameInviting_CommissionWalletId (CommissionWalletId);`,
+
+		`ALTER TABLE user_wallet_adjustment
+			ADD KEY IX_UserWalletAdjustment_WalletId (WalletId);`,
+	},
+	Down: []string{
+		`ALTER TABLE user_wallet DROP KEY IX_UserWallet_UserId_LastUpdatedAt;`,
+		`ALTER TABLE game_mobile_card DROP KEY IX_GameMobileCard_WalletId;`,
+		`ALTER TABLE game_lottery DROP KEY IX_GameLottery_WalletId;`,
+		`ALTER TABLE game_read_daily DROP KEY IX_GameReadDaily_WalletId;`,
+		`ALTER TABLE game_inviting
+			DROP KEY IX_GameInviting_WalletId,
+			DROP KEY IX_GameInviting_CommissionWalletId;`,
+		`ALTER TABLE user_wallet_adjustment DROP KEY IX_UserWalletAdjustment_WalletId;`,
+	},
+}
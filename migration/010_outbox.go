@@ -0,0 +1,33 @@
+package migration
+
+/*
+	Transactional outbox: written inside the wallet transaction, applied by the relay job
+	(records of user_wallet appended to the cache of the user, then domain event published to RabbitMQ).
+ */
+var outbox = Migration{
+	Version:	10,
+	Name:		"outbox",
+	Up: []string{
+		`CREATE TABLE IF NOT EXISTS outbox_message (
+			Id				BINARY(16)		NOT NULL,
+			EventType		VARCHAR(64)		NOT NULL DEFAULT '',
+			RoutingKey		VARCHAR(128)	NOT NULL DEFAULT '',
+			UserId			BINARY(16)		NULL,
+			CacheRefresh	VARCHAR(255)	NOT NULL DEFAULT '',
+			WalletIds		TEXT			NULL,
+			Payload			TEXT			NULL,
+			Status			TINYINT			NOT NULL DEFAULT 0,
+			Attempts		INT				NOT NULL DEFAULT 0,
+			LastError		VARCHAR(512)	NOT NULL DEFAULT '',
+			NextAttemptAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			ProcessedAt		DATETIME		NULL,
+			PRIMARY KEY (Id),
+			KEY IX_OutboxMessage_Status (Status, NextAttemptAt),
+			KEY IX_OutboxMessage_ProcessedAt (ProcessedAt)
+		);`,
+	},
+	Down: []string{
+		`DROP TABLE IF EXISTS outbox_message;`,
+	},
+}
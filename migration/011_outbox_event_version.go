@@ -0,0 +1,15 @@
+package migration
+
+/*
+	Version of the event payload, published with the event so consumers can handle breaking changes.
+ */
+var outboxEventVersion = Migration{
+	Version:	11,
+	Name:		"outbox_event_version",
+	Up: []string{
+		`ALTER TABLE outbox_message ADD COLUMN Version INT NOT NULL DEFAULT 0 AFTER RoutingKey;`,
+	},
+	Down: []string{
+		`ALTER TABLE outbox_message DROP COLUMN Version;`,
+	},
+}
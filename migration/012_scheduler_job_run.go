@@ -0,0 +1,31 @@
+package migration
+
+/*
+	Run history of scheduled jobs, written by the scheduler binary.
+	Manual runs are queued by the API (Status Queued) then picked up by the scheduler.
+ */
+var schedulerJobRun = Migration{
+	Version:	12,
+	Name:		"scheduler_job_run",
+	Up: []string{
+		`CREATE TABLE IF NOT EXISTS scheduler_job_run (
+			Id				BINARY(16)		NOT NULL,
+			JobName			VARCHAR(64)		NOT NULL,
+			TriggerType		VARCHAR(16)		NOT NULL,
+			TriggeredBy		VARCHAR(255)	NOT NULL DEFAULT '',
+			Status			VARCHAR(16)		NOT NULL,
+			Result			VARCHAR(512)	NOT NULL DEFAULT '',
+			Error			VARCHAR(512)	NOT NULL DEFAULT '',
+			Instance		VARCHAR(255)	NOT NULL DEFAULT '',
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			StartedAt		DATETIME		NULL,
+			FinishedAt		DATETIME		NULL,
+			PRIMARY KEY (Id),
+			KEY IX_SchedulerJobRun_JobName (JobName, CreatedAt),
+			KEY IX_SchedulerJobRun_Status (Status, CreatedAt)
+		);`,
+	},
+	Down: []string{
+		`DROP TABLE IF EXISTS scheduler_job_run;`,
+	},
+}
@@ -0,0 +1,27 @@
+package migration
+
+/*
+	Result of each settled lottery date, written in the transaction paying its winners.
+	The row is locked while a date is settled, so the consumer and a manual settlement never pay a winner twice.
+	A date is settled again with the same Special only, Winners counts every winner paid for the date.
+ */
+var lotteryResult = Migration{
+	Version:	13,
+	Name:		"lottery_result",
+	Up: []string{
+		`CREATE TABLE IF NOT EXISTS game_lottery_result (
+			Date			DATE			NOT NULL,
+			Special			VARCHAR(16)		NOT NULL,
+			Result			TEXT			NOT NULL,
+			Source			VARCHAR(16)		NOT NULL,
+			SettledBy		VARCHAR(255)	NOT NULL DEFAULT '',
+			Winners			INT				NOT NULL DEFAULT 0,
+			CreatedAt		DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			LastUpdatedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+			PRIMARY KEY (Date)
+		);`,
+	},
+	Down: []string{
+		`DROP TABLE IF EXISTS game_lottery_result;`,
+	},
+}
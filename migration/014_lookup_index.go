@@ -0,0 +1,21 @@
+package migration
+
+/*
+	Indexes of lookups by user and date, queries compare the column with uuid_to_bin(?) so that they are used.
+	Indexes of the baseline already lead with the user and the date, only the stock index gets LastUpdatedAt.
+	+ cards in stock picked oldest first: mobile_card by VendorCode, Status, Value then LastUpdatedAt
+ */
+var lookupIndex = Migration{
+	Version:	14,
+	Name:		"lookup_index",
+	Up: []string{
+		`ALTER TABLE mobile_card
+			DROP KEY IX_MobileCard_Status,
+			ADD KEY IX_MobileCard_VendorCode_Status (VendorCode, Status, Value, LastUpdatedAt);`,
+	},
+	Down: []string{
+		`ALTER TABLE mobile_card
+			DROP KEY IX_MobileCard_VendorCode_Status,
+			ADD KEY IX_MobileCard_Status (VendorCode, Status, Value);`,
+	},
+}
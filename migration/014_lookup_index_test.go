@@ -0,0 +1,235 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"g-tech.com/infrastructure/util"
+	_ "github.com/go-sql-driver/mysql"
+	"math/rand"
+	"os"
+	"strings"
+	"testing"
+)
+
+const benchmarkPrefix = "benchmark_"
+const benchmarkBatchSize = 500
+const benchmarkUsers = 2000
+
+/*
+	A lookup by user, Before filters with uuid_from_bin on the column, After compares the column with uuid_to_bin(?)
+ */
+type queryBenchmark struct {
+	Name		string
+	Before		string
+	After		string
+}
+
+var queryBenchmarks = []queryBenchmark{
+	{
+		Name:	"ReadingOfToday",
+		Before:	`SELECT Id FROM benchmark_game_read_daily WHERE uuid_from_bin(UserId) = ? AND DATE(LastUpdatedAt) = CURRENT_DATE;`,
+		After:	`SELECT Id FROM benchmark_game_read_daily WHERE UserId = uuid_to_bin(?) AND LastUpdatedAt >= CURRENT_DATE AND LastUpdatedAt < CURRENT_DATE + INTERVAL 1 DAY;`,
+	},
+	{
+		Name:	"NumbersSelectedToday",
+		Before:	`SELECT NumberSelected FROM benchmark_game_lottery WHERE uuid_from_bin(UserId) = ? AND Date = CURRENT_DATE;`,
+		After:	`SELECT NumberSelected FROM benchmark_game_lottery WHERE UserId = uuid_to_bin(?) AND Date = CURRENT_DATE;`,
+	},
+	{
+		Name:	"TransactionsOfUser",
+		Before:	`SELECT Id, Value, LastUpdatedAt FROM benchmark_user_wallet WHERE uuid_from_bin(UserId) = ? ORDER BY LastUpdatedAt DESC LIMIT 20;`,
+		After:	`SELECT Id, Value, LastUpdatedAt FROM benchmark_user_wallet WHERE UserId = uuid_to_bin(?) ORDER BY LastUpdatedAt DESC LIMIT 20;`,
+	},
+	{
+		Name:	"InviterOfUser",
+		Before:	`SELECT uuid_from_bin(InvitingUser) FROM benchmark_game_inviting WHERE uuid_from_bin(InvitedUser) = ?;`,
+		After:	`SELECT uuid_from_bin(InvitingUser) FROM benchmark_game_inviting WHERE InvitedUser = uuid_to_bin(?);`,
+	},
+}
+
+/*
+	Tables copied with their indexes (CREATE TABLE LIKE) then seeded, the tables of the application are not touched
+ */
+var benchmarkTables = []string{"user_wallet", "game_read_daily", "game_lottery", "game_inviting"}
+
+/*
+	Lookups by user with uuid_from_bin on the column (Before) and comparing the column (After),
+	against copies of the tables seeded with benchmarkUsers users. Needs a migrated MySql 8 database:
+		MINIGAME_BENCHMARK_DSN='user:password@tcp(host:3306)/database?parseTime=true' go test -run none -bench LookupByUser ./migration/
+ */
+func BenchmarkLookupByUser(b *testing.B) {
+	dsn := os.Getenv("MINIGAME_BENCHMARK_DSN")
+	if dsn == "" {
+		b.Skip("MINIGAME_BENCHMARK_DSN is not set")
+	}
+
+	dbContext, err := sql.Open("mysql", dsn)
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer dbContext.Close()
+
+	// Copies get the indexes of the latest migrations
+	err = NewMigrator(dbContext).Check()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	err = dropBenchmarkTables(dbContext)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, table := range benchmarkTables {
+		_, err = dbContext.Exec(`CREATE TABLE ` + benchmarkPrefix + table + ` LIKE ` + table + `;`)
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+	defer func() {
+		_ = dropBenchmarkTables(dbContext)
+	}()
+
+	userIds, err := seedBenchmarkTables(dbContext, benchmarkUsers)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	for _, benchmark := range queryBenchmarks {
+		for _, variant := range []struct{ Name, Query string }{{"Before", benchmark.Before}, {"After", benchmark.After}} {
+			b.Run(benchmark.Name + "/" + variant.Name, func(b *testing.B) {
+				index, err := explainQuery(dbContext, variant.Query, userIds[0])
+				if err != nil {
+					b.Fatal(err)
+				}
+				b.Logf("index: %s", index)
+
+				b.ResetTimer()
+				for i := 0; i < b.N; i++ {
+					err = runQuery(dbContext, variant.Query, userIds[rand.Intn(len(userIds))])
+					if err != nil {
+						b.Fatal(err)
+					}
+				}
+			})
+		}
+	}
+}
+
+func dropBenchmarkTables(dbContext *sql.DB) error {
+	for _, table := range benchmarkTables {
+		_, err := dbContext.Exec(`DROP TABLE IF EXISTS ` + benchmarkPrefix + table + `;`)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/*
+	Dates are spread over the last 30 days, today included, so that lookups of today match a row per user
+ */
+func seedBenchmarkTables(dbContext *sql.DB, users int) ([]string, error) {
+	userIds := make([]string, users)
+	for i := range userIds {
+		userIds[i] = util.NewUuid()
+	}
+	prizeId := util.NewUuid()
+
+	var wallets, readings, numbers, invitations []string
+	for i, userId := range userIds {
+		for hour := 0; hour < 20; hour++ {
+			wallets = append(wallets, fmt.Sprintf(`(uuid_to_bin('%s'), uuid_to_bin('%s'), uuid_to_bin('%s'), %d, NOW() - INTERVAL %d HOUR)`,
+				util.NewUuid(), userId, prizeId, 100, hour * 13))
+		}
+		for day := 0; day < 30; day++ {
+			readings = append(readings, fmt.Sprintf(`(uuid_to_bin('%s'), uuid_to_bin('%s'), CURRENT_DATE - INTERVAL %d DAY + INTERVAL 8 HOUR)`,
+				util.NewUuid(), userId, day))
+		}
+		for day := 0; day < 10; day++ {
+			for number := 0; number < 3; number++ {
+				numbers = append(numbers, fmt.Sprintf(`(uuid_to_bin('%s'), uuid_to_bin('%s'), '%02d', CURRENT_DATE - INTERVAL %d DAY)`,
+					util.NewUuid(), userId, rand.Intn(100), day))
+			}
+		}
+		invitations = append(invitations, fmt.Sprintf(`(uuid_to_bin('%s'), uuid_to_bin('%s'), uuid_to_bin('%s'))`,
+			util.NewUuid(), userIds[(i + 1) % users], userId))
+	}
+
+	seeds := []struct{ Statement string; Rows []string }{
+		{`INSERT INTO benchmark_user_wallet(Id, UserId, PrizeId, Value, LastUpdatedAt) VALUES `, wallets},
+		{`INSERT INTO benchmark_game_read_daily(Id, UserId, LastUpdatedAt) VALUES `, readings},
+		{`INSERT INTO benchmark_game_lottery(Id, UserId, NumberSelected, Date) VALUES `, numbers},
+		{`INSERT INTO benchmark_game_inviting(Id, InvitingUser, InvitedUser) VALUES `, invitations},
+	}
+	for _, seed := range seeds {
+		for start := 0; start < len(seed.Rows); start += benchmarkBatchSize {
+			end := start + benchmarkBatchSize
+			if end > len(seed.Rows) {
+				end = len(seed.Rows)
+			}
+			_, err := dbContext.Exec(seed.Statement + strings.Join(seed.Rows[start:end], ",") + `;`)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// Statistics of the copies, for the optimizer
+	for _, table := range benchmarkTables {
+		_, err := dbContext.Exec(`ANALYZE TABLE ` + benchmarkPrefix + table + `;`)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return userIds, nil
+}
+
+/*
+	Run a query for a user, rows are read
+ */
+func runQuery(dbContext *sql.DB, query string, userId string) error {
+	result, err := dbContext.Query(query, userId)
+	if err != nil {
+		return err
+	}
+	defer result.Close()
+
+	for result.Next() {
+	}
+	return result.Err()
+}
+
+/*
+	Index used according to EXPLAIN (first table of the plan)
+ */
+func explainQuery(dbContext *sql.DB, query string, userId string) (string, error) {
+	explainResult, err := dbContext.Query(`EXPLAIN ` + query, userId)
+	if err != nil {
+		return "", err
+	}
+	defer explainResult.Close()
+
+	columns, err := explainResult.Columns()
+	if err != nil {
+		return "", err
+	}
+	if !explainResult.Next() {
+		return "", explainResult.Err()
+	}
+	values := make([]sql.NullString, len(columns))
+	pointers := make([]interface{}, len(columns))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	err = explainResult.Scan(pointers...)
+	if err != nil {
+		return "", err
+	}
+
+	for i, column := range columns {
+		if column == "key" && values[i].Valid {
+			return values[i].String, nil
+		}
+	}
+	return "none (full scan)", nil
+}
@@ -0,0 +1,279 @@
+package migration
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/pkg/errors"
+	"strings"
+	"time"
+)
+
+/*
+	A versioned change of the schema
+	+ Up and Down are run statement by statement, MySql commits each DDL statement on its own
+	+ a migration without Down cannot be reverted
+ */
+type Migration struct {
+	Version		int
+	Name		string
+	Up			[]string
+	Down		[]string
+}
+
+/*
+	Migrations in version order, a new migration is appended with the next version
+ */
+var migrations = []Migration{
+	baseline,
+	invitationFraud,
+	deferredInvitingReward,
+	referralCommission,
+	vanityInvitingCode,
+	invitationCampaign,
+	walletCoinExpiry,
+	walletAdjustment,
+	walletTransfer,
+	transactionHistory,
+	outbox,
+	outboxEventVersion,
+	schedulerJobRun,
+	lotteryResult,
+	lookupIndex,
+}
+
+/*
+	Version of a migration and when it was applied, AppliedAt is nil while pending
+ */
+type Status struct {
+	Version		int
+	Name		string
+	AppliedAt	*time.Time
+}
+
+type Migrator struct {
+	DbContext	*sql.DB
+}
+
+func NewMigrator(dbContext *sql.DB) *Migrator {
+	return &Migrator{DbContext: dbContext}
+}
+
+/*
+	Version expected by this build
+ */
+func Latest() int {
+	return migrations[len(migrations) - 1].Version
+}
+
+/*
+	Status of every migration of this build
+ */
+func (migrator *Migrator) Status() ([]Status, error) {
+	applied, err := migrator.applied()
+	if err != nil {
+		return nil, err
+	}
+
+	var listStatus []Status
+	for _, migration := range migrations {
+		status := Status{Version: migration.Version, Name: migration.Name}
+		if appliedAt, existed := applied[migration.Version]; existed {
+			status.AppliedAt = &appliedAt
+		}
+		listStatus = append(listStatus, status)
+	}
+	return listStatus, nil
+}
+
+/*
+	Fails when a migration of this build is not applied yet
+	Versions applied by a newer build are accepted, migrations only add to the schema they need
+	Read only: a database not migrated yet is reported out of date
+ */
+func (migrator *Migrator) Check() error {
+	listStatus, err := migrator.Status()
+	if err != nil {
+		return err
+	}
+
+	var pending []string
+	for _, status := range listStatus {
+		if status.AppliedAt == nil {
+			pending = append(pending, fmt.Sprintf("%03d_%s", status.Version, status.Name))
+		}
+	}
+	if len(pending) > 0 {
+		return errors.Errorf("Schema is out of date, run migrate to apply %s", strings.Join(pending, ", "))
+	}
+	return nil
+}
+
+/*
+	Apply pending migrations up to version target, done is called after each of them
+ */
+func (migrator *Migrator) Up(target int, done func(Migration)) error {
+	err := migrator.prepare()
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.applied()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if migration.Version > target {
+			break
+		}
+		if _, existed := applied[migration.Version]; existed {
+			continue
+		}
+
+		err = migrator.run(migration, migration.Up)
+		if err != nil {
+			return err
+		}
+		_, err = migrator.DbContext.Exec(`INSERT INTO schema_version(Version, Name) VALUES (?, ?);`, migration.Version, migration.Name)
+		if err != nil {
+			return err
+		}
+		done(migration)
+	}
+	return nil
+}
+
+/*
+	Revert applied migrations above version target, latest first
+ */
+func (migrator *Migrator) Down(target int, done func(Migration)) error {
+	err := migrator.prepare()
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.applied()
+	if err != nil {
+		return err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		migration := migrations[i]
+		if migration.Version <= target {
+			break
+		}
+		if _, existed := applied[migration.Version]; !existed {
+			continue
+		}
+		if len(migration.Down) == 0 {
+			return errors.Errorf("Migration %03d_%s cannot be reverted", migration.Version, migration.Name)
+		}
+
+		err = migrator.run(migration, migration.Down)
+		if err != nil {
+			return err
+		}
+		_, err = migrator.DbContext.Exec(`DELETE FROM schema_version WHERE Version = ?;`, migration.Version)
+		if err != nil {
+			return err
+		}
+		done(migration)
+	}
+	return nil
+}
+
+/*
+	Record migrations up to version target as applied without running them,
+	for a database created before migrations were managed by the application
+ */
+func (migrator *Migrator) Baseline(target int, done func(Migration)) error {
+	err := migrator.prepare()
+	if err != nil {
+		return err
+	}
+
+	applied, err := migrator.applied()
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if migration.Version > target {
+			break
+		}
+		if _, existed := applied[migration.Version]; existed {
+			continue
+		}
+		_, err = migrator.DbContext.Exec(`INSERT INTO schema_version(Version, Name) VALUES (?, ?);`, migration.Version, migration.Name)
+		if err != nil {
+			return err
+		}
+		done(migration)
+	}
+	return nil
+}
+
+/*
+	A failed statement leaves the statements before it applied, they are written to be run again (IF NOT EXISTS)
+	when possible, otherwise the migration is finished by hand before running migrate again
+ */
+func (migrator *Migrator) run(migration Migration, statements []string) error {
+	for i, statement := range statements {
+		_, err := migrator.DbContext.Exec(statement)
+		if err != nil {
+			return errors.Wrapf(err, "Migration %03d_%s failed at statement %d", migration.Version, migration.Name, i + 1)
+		}
+	}
+	return nil
+}
+
+/*
+	Applied versions, none while the version table does not exist
+ */
+func (migrator *Migrator) applied() (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+
+	isExisted, err := migrator.tableExists("schema_version")
+	if err != nil || !isExisted {
+		return applied, err
+	}
+
+	appliedResult, err := migrator.DbContext.Query(`SELECT Version, AppliedAt FROM schema_version;`)
+	if err != nil {
+		return nil, err
+	}
+	defer appliedResult.Close()
+
+	for appliedResult.Next() {
+		var version int
+		var appliedAt time.Time
+		err = appliedResult.Scan(&version, &appliedAt)
+		if err != nil {
+			return nil, err
+		}
+		applied[version] = appliedAt
+	}
+	return applied, appliedResult.Err()
+}
+
+/*
+	Create the version table, run by the commands changing the schema only
+ */
+func (migrator *Migrator) prepare() error {
+	_, err := migrator.DbContext.Exec(`CREATE TABLE IF NOT EXISTS schema_version (
+		Version		INT				NOT NULL,
+		Name		VARCHAR(255)	NOT NULL,
+		AppliedAt	DATETIME		NOT NULL DEFAULT CURRENT_TIMESTAMP,
+		PRIMARY KEY (Version)
+	);`)
+	return err
+}
+
+func (migrator *Migrator) tableExists(name string) (bool, error) {
+	var table string
+	err := migrator.DbContext.QueryRow(`SELECT TABLE_NAME FROM information_schema.TABLES WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = ?;`, name).Scan(&table)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	return err == nil, err
+}
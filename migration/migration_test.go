@@ -0,0 +1,32 @@
+package migration
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMigrationsAreOrdered(t *testing.T) {
+	names := make(map[string]bool)
+	for i, migration := range migrations {
+		if migration.Version != i {
+			t.Errorf("migration %s has version %d, want %d", migration.Name, migration.Version, i)
+		}
+		if migration.Name == "" || names[migration.Name] {
+			t.Errorf("migration %03d has an empty or duplicated name %q", migration.Version, migration.Name)
+		}
+		names[migration.Name] = true
+
+		if len(migration.Up) == 0 {
+			t.Errorf("migration %03d_%s has no up statement", migration.Version, migration.Name)
+		}
+		for _, statement := range append(migration.Up, migration.Down...) {
+			if strings.TrimSpace(statement) == "" {
+				t.Errorf("migration %03d_%s has an empty statement", migration.Version, migration.Name)
+			}
+		}
+	}
+
+	if Latest() != migrations[len(migrations) - 1].Version {
+		t.Errorf("Latest() = %d, want %d", Latest(), migrations[len(migrations) - 1].Version)
+	}
+}
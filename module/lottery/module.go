@@ -11,74 +11,75 @@ import (
 	"g-tech.com/module/lottery/summary"
 	"g-tech.com/module/minigame/service"
 	"github.com/streadway/amqp"
-	"sync"
 	"time"
 )
 
-var mLotteryResultService summary.LotterySummaryService
-
-var wg sync.WaitGroup
-var mRbChannel 		   		*amqp.Channel
-var mRbResultConQueue 		amqp.Queue
-
-func Initialize(rbChannel *amqp.Channel, dbContext *sql.DB, cache cache.CacheManager, timeout time.Duration){
+var mLotteryResultService summary.ISummaryLotteryService
 
+/*
+	Service paying lottery winners, also used to settle a date manually
+ */
+func NewSummaryService(dbContext *sql.DB, cache cache.CacheManager, timeout time.Duration) summary.ISummaryLotteryService {
 	redisService := service.NewRedisService(dbContext, cache, timeout)
 	configService := service.NewConfigService(dbContext, cache, redisService, timeout)
-	mLotteryResultService = summary.NewLotterySummaryService(dbContext, cache, redisService, configService, timeout)
+	outboxService := service.NewOutboxService(dbContext, redisService, timeout)
+	walletService := service.NewWalletService(dbContext, cache, redisService, configService, outboxService, timeout)
+	return summary.NewLotterySummaryService(dbContext, cache, redisService, configService, walletService, outboxService, timeout)
+}
 
-	mRbChannel = rbChannel
-	// Creates a queue to consume to crawl post
-	mRbResultConQueue = broker.CreateQueue(rbChannel, constant.RbRouteResult)
-	err := rbChannel.QueueBind (
-		mRbResultConQueue.Name,
-		constant.RbRouteResult,
-		constant.RbSuperExchange,
-		false,
-		nil,
-	)
+/*
+	Declares the result queue and consumes it, again after each reconnect of rbClient
+ */
+func Initialize(rbClient *broker.Client, dbContext *sql.DB, cache cache.CacheManager, timeout time.Duration){
+	mLotteryResultService = NewSummaryService(dbContext, cache, timeout)
+
+	err := rbClient.Setup(func(rbChannel *amqp.Channel) error {
+		// Creates a queue to consume lottery results
+		resultQueue := broker.CreateQueue(rbChannel, constant.RbRouteResult)
+		return rbChannel.QueueBind (
+			resultQueue.Name,
+			constant.RbRouteResult,
+			rbClient.Exchange(),
+			false,
+			nil,
+		)
+	})
 	if err != nil {
 		logger.Error(err.Error())
 	}
-}
-
-func Execute()  {
-	forever := make(chan bool)
-
-	wg.Add(1)
-	go consumeLotteryResult()
-	wg.Wait()
 
-	<-forever
+	err = rbClient.Consume(constant.RbRouteResult, consumeLotteryResult)
+	if err != nil {
+		logger.Error("Failed to consume lottery result %s", err.Error())
+	}
 }
 
+/*
+	Delay before a result failing to be summarized is delivered again
+ */
+const retryDelay = 5 * time.Second
+
 /**
- * Consumes posts
+ * Consumes lottery results
+ * A result failing to be summarized (database error) is requeued, settling it again pays nobody twice
  */
-func consumeLotteryResult()  {
-	results, err := mRbChannel.Consume(
-		mRbResultConQueue.Name,
-		"",
-		false,
-		false,
-		false,
-		false,
-		nil,
-	)
+func consumeLotteryResult(item amqp.Delivery)  {
+	var lotteryResult dto.LotteryResult
+	err := json.Unmarshal(item.Body, &lotteryResult)
 	if err != nil {
-		logger.Error("Failed to consume post", err.Error())
+		// Never readable, it is dropped
+		logger.Error("Lottery result not readable: %s", err.Error())
+		_ = item.Ack(false)
+		return
 	}
 
-	for item := range results {
-		var lotteryResult dto.LotteryResult
-		err := json.Unmarshal(item.Body, &lotteryResult)
-		if err == nil {
-			// Then crawl it
-			//mPostService.CrawlPost(mRbChannel, &mRbResultPubQueue, &mRbPostETLQueue, crawlPost.Data)
-			err = mLotteryResultService.SummaryResult(lotteryResult)
-		}
-		// Return Ack
-		_ = item.Ack(false)
+	err = mLotteryResultService.SummaryResult(lotteryResult, "")
+	if err != nil {
+		logger.Error("Lottery result not summarized, requeued: %s", err.Error())
+		time.Sleep(retryDelay)
+		_ = item.Nack(false, true)
+		return
 	}
-	wg.Done()
-}
\ No newline at end of file
+	// Return Ack
+	_ = item.Ack(false)
+}
@@ -1,10 +1,12 @@
 package summary
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"g-tech.com/constant"
 	"g-tech.com/dto"
+	"g-tech.com/gerror"
 	"g-tech.com/infrastructure/cache"
 	"g-tech.com/infrastructure/logger"
 	"g-tech.com/infrastructure/repository"
@@ -15,7 +17,11 @@ import (
 )
 
 type ISummaryLotteryService interface {
-	SummaryResult(lotteryResult dto.LotteryResult) error
+	// For consumer
+	SummaryResult(lotteryResult dto.LotteryResult, date string) error
+
+	// For web and command line
+	SettleLottery(ctx context.Context, settlement dto.LotterySettlement) (dto.LotterySettlement, int, error)
 }
 
 type LotterySummaryService struct {
@@ -23,117 +29,268 @@ type LotterySummaryService struct {
 	Cache 			cache.CacheManager
 	ConfigService  	service.ConfigService
 	RedisService	service.RedisService
+	WalletService	service.IWalletService
+	OutboxService	service.IOutboxService
 	Timeout    		time.Duration
 }
 
-func NewLotterySummaryService(dbContext *sql.DB, cache cache.CacheManager, redisService service.RedisService, configService service.ConfigService, timeout time.Duration) LotterySummaryService {
+func NewLotterySummaryService(dbContext *sql.DB, cache cache.CacheManager, redisService service.RedisService, configService service.ConfigService, walletService service.IWalletService, outboxService service.IOutboxService, timeout time.Duration) ISummaryLotteryService {
 	lotteryService := LotterySummaryService{}
 	lotteryService.MySql.SetDbContext(dbContext)
 	lotteryService.Cache = cache
 	lotteryService.RedisService = redisService
 	lotteryService.ConfigService = configService
+	lotteryService.WalletService = walletService
+	lotteryService.OutboxService = outboxService
 	lotteryService.Timeout = timeout
 
-	return lotteryService
+	return &lotteryService
+}
+
+/*
+	Pay players of a date (YYYY-MM-DD, empty for today) whose number matches the result
+	A result delivered again pays nobody twice
+ */
+func (service *LotterySummaryService) SummaryResult(lotteryResult dto.LotteryResult, date string) error {
+	settlement, errorCode, err := service.SettleLottery(context.Background(), dto.LotterySettlement{
+		Date:		date,
+		Result:		lotteryResult,
+		Source:		constant.LotterySourceFeed,
+	})
+	if err != nil {
+		return err
+	}
+	if errorCode != 0 {
+		logger.Error("Lottery of %s not settled: %s", settlement.Date, gerror.T(errorCode))
+		return nil
+	}
+
+	logger.Info("Date: %s\n", settlement.Date)
+	logger.Info("Result: %s\n", util.ToJSON(lotteryResult))
+	logger.Info("Number of players won the lottery: %d\n", len(settlement.Winners))
+
+	return nil
 }
 
-func (service *LotterySummaryService) SummaryResult(lotteryResult dto.LotteryResult) error {
+/*
+	Settle a lottery date
+	+ only winners without a wallet are paid, a date can be settled again (e.g. after a failure) without paying twice
+	+ the result of the date is recorded in game_lottery_result, settling it again with another special prize is refused
+	+ the result row is locked until winners are paid, the consumer and a manual settlement run one after the other
+	+ DryRun: winners are listed, nothing is written
+ */
+func (service *LotterySummaryService) SettleLottery(ctx context.Context, settlement dto.LotterySettlement) (dto.LotterySettlement, int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	var today string
+	err := service.MySql.DbContext.QueryRow(`SELECT DATE_FORMAT(CURRENT_DATE, '%Y-%m-%d');`).Scan(&today)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return settlement, 0, err
+	}
+	if settlement.Date == "" {
+		settlement.Date = today
+	}
+	_, err = time.Parse(constant.DateLayout, settlement.Date)
+	if err != nil || settlement.Date > today {
+		return settlement, gerror.ErrorLotteryDateInvalid, nil
+	}
+	if !isLotteryNumber(settlement.Result.Special) {
+		return settlement, gerror.ErrorLotteryResultInvalid, nil
+	}
+	if settlement.Source == "" {
+		settlement.Source = constant.LotterySourceManual
+	}
+
 	/*
 		Get Prize
 	 */
 	winFirstPrize, status, err := service.ConfigService.GetPrize(constant.ProgramLotteryWinFirstPrize)
 	if err != nil {
 		logger.Error(err.Error())
-		return err
-	} else {
-		if status == false {
-			return err
+		return settlement, 0, err
+	}
+	if !status {
+		return settlement, gerror.ErrorLotteryProgramNotFound, nil
+	}
+	settlement.PrizeValue = winFirstPrize.Value
+
+	tx, err := service.MySql.DbContext.Begin()
+	if err != nil {
+		logger.Error(err.Error())
+		return settlement, 0, err
+	}
+
+	/*
+		Record the result, or lock the one recorded by an earlier settlement
+	 */
+	lockResultQuery := `SELECT Special FROM game_lottery_result WHERE Date = ?;`
+	if !settlement.DryRun {
+		createResultStatement := `INSERT INTO game_lottery_result(Date, Special, Result, Source, SettledBy) VALUES (?, ?, ?, ?, ?)
+									ON DUPLICATE KEY UPDATE Date = Date;`
+		_, err = tx.Exec(createResultStatement, settlement.Date, settlement.Result.Special, util.ToJSON(settlement.Result), settlement.Source, settlement.SettledBy)
+		if err != nil {
+			_ = tx.Rollback()
+			logger.Error(err.Error())
+			return settlement, 0, err
 		}
+		lockResultQuery = `SELECT Special FROM game_lottery_result WHERE Date = ? FOR UPDATE;`
+	}
+	var settledSpecial string
+	err = tx.QueryRow(lockResultQuery, settlement.Date).Scan(&settledSpecial)
+	if err != nil && err != sql.ErrNoRows {
+		_ = tx.Rollback()
+		service.MySql.HandleError(err)
+		return settlement, 0, err
+	}
+	if settledSpecial != "" && settledSpecial != settlement.Result.Special {
+		_ = tx.Rollback()
+		return settlement, gerror.ErrorLotteryResultMismatch, nil
 	}
 
 	/*
-		Get today players
+		Get players of the date
 	*/
-	getLotteryPlayerQuery := `SELECT uuid_from_bin(Id), uuid_from_bin(UserId), NumberSelected, Date FROM game_lottery WHERE Date = CURRENT_DATE;`
-	getLotteryPlayerResult, err := service.MySql.DbContext.Query(getLotteryPlayerQuery)
+	getLotteryPlayerQuery := `SELECT uuid_from_bin(Id), uuid_from_bin(UserId), NumberSelected, DATE_FORMAT(Date, '%Y-%m-%d'), IFNULL(uuid_from_bin(WalletId), '')
+								FROM game_lottery
+								WHERE Date = ?;`
+	if !settlement.DryRun {
+		getLotteryPlayerQuery = strings.Replace(getLotteryPlayerQuery, `;`, ` FOR UPDATE;`, 1)
+	}
+	getLotteryPlayerResult, err := tx.Query(getLotteryPlayerQuery, settlement.Date)
 	if err != nil {
+		_ = tx.Rollback()
 		service.MySql.HandleError(err)
-		return err
+		return settlement, 0, err
 	}
-	defer getLotteryPlayerResult.Close()
-
-	tx, err := service.MySql.DbContext.Begin()
 
 	var wonLotteryPlayers []dto.LotteryPlayer
 	var updateWalletIdPrepare string
 	for getLotteryPlayerResult.Next(){
 		var lotteryPlayer dto.LotteryPlayer
-		err := getLotteryPlayerResult.Scan(&lotteryPlayer.Id, &lotteryPlayer.UserId, &lotteryPlayer.NumberSelected, &lotteryPlayer.Date)
+		err := getLotteryPlayerResult.Scan(&lotteryPlayer.Id, &lotteryPlayer.UserId, &lotteryPlayer.NumberSelected, &lotteryPlayer.Date, &lotteryPlayer.WalletId)
 		if err != nil {
+			_ = getLotteryPlayerResult.Close()
+			_ = tx.Rollback()
 			logger.Error(err.Error())
-			return err
+			return settlement, 0, err
+		}
+		settlement.Players++
+		if !strings.HasSuffix(settlement.Result.Special, lotteryPlayer.NumberSelected) || len(lotteryPlayer.NumberSelected) != 2 {
+			continue
 		}
-		if strings.HasSuffix(lotteryResult.Special, lotteryPlayer.NumberSelected) && len(lotteryPlayer.NumberSelected) == 2{
-			walletId := util.NewUuid()
-			updateWalletIdPrepare += fmt.Sprintf(`UPDATE game_lottery SET WalletId = uuid_to_bin('%s') WHERE uuid_from_bin(Id) = '%s';`, walletId, lotteryPlayer.Id)
-			lotteryPlayer.WalletId = walletId
-			wonLotteryPlayers = append(wonLotteryPlayers, lotteryPlayer)
+		if lotteryPlayer.WalletId != "" {
+			settlement.AlreadyPaid++
+			continue
 		}
+		walletId := util.NewUuid()
+		updateWalletIdPrepare += fmt.Sprintf(`UPDATE game_lottery SET WalletId = uuid_to_bin('%s') WHERE Id = uuid_to_bin('%s');`, walletId, lotteryPlayer.Id)
+		lotteryPlayer.WalletId = walletId
+		wonLotteryPlayers = append(wonLotteryPlayers, lotteryPlayer)
 	}
+	_ = getLotteryPlayerResult.Close()
+	settlement.Winners = wonLotteryPlayers
 
-	/*
-		Update user wallet for users won the lottery
-	 */
-	if len(wonLotteryPlayers) > 0 {
-		//	Update wallet ID
-		_, err = tx.Exec(updateWalletIdPrepare)
+	if settlement.DryRun {
+		// Wallets are created on settlement
+		for i := range settlement.Winners {
+			settlement.Winners[i].WalletId = ""
+		}
+		_ = tx.Rollback()
+		return settlement, 0, nil
+	}
+	if len(wonLotteryPlayers) == 0 {
+		// The result is kept, the date is settled without winner to pay
+		err = tx.Commit()
 		if err != nil {
-			_ = tx.Rollback()
-			fmt.Println(updateWalletIdPrepare)
 			logger.Error(err.Error())
-			return err
 		}
+		return settlement, 0, err
+	}
 
-		// Insert User Wallet
-		createWalletQuery := `INSERT INTO user_wallet(Id, UserId, PrizeId, Value) VALUES `
-		for _, player := range wonLotteryPlayers{
-			createWalletQuery += fmt.Sprintf(` (uuid_to_bin('%s'), uuid_to_bin('%s'), uuid_to_bin('%s'), %d),`,
-				player.WalletId, player.UserId, winFirstPrize.Id, winFirstPrize.Value)
+	/*
+		Update user wallet for users won the lottery
+	 */
+	//	Update wallet ID
+	_, err = tx.Exec(updateWalletIdPrepare)
+	if err != nil {
+		_ = tx.Rollback()
+		fmt.Println(updateWalletIdPrepare)
+		logger.Error(err.Error())
+		return settlement, 0, err
+	}
+
+	// Insert User Wallet
+	expiredAt := "NULL"
+	if expiryDays := service.WalletService.ExpiryDays(constant.ProgramLotteryWinFirstPrize); expiryDays > 0 {
+		expiredAt = fmt.Sprintf("NOW() + INTERVAL %d DAY", expiryDays)
+	}
+	createWalletQuery := `INSERT INTO user_wallet(Id, UserId, PrizeId, Value, RemainingValue, ExpiredAt) VALUES `
+	for _, player := range wonLotteryPlayers{
+		createWalletQuery += fmt.Sprintf(` (uuid_to_bin('%s'), uuid_to_bin('%s'), uuid_to_bin('%s'), %d, %d, %s),`,
+			player.WalletId, player.UserId, winFirstPrize.Id, winFirstPrize.Value, winFirstPrize.Value, expiredAt)
+	}
+	createWalletQuery = createWalletQuery[:len(createWalletQuery)-1]
+	createWalletQuery += `;`
+	_, err = tx.Exec(createWalletQuery)
+	if err != nil {
+		fmt.Println(createWalletQuery)
+		logger.Error(err.Error())
+		_ = tx.Rollback()
+		return settlement, 0, err
+	}
+
+	// Outbox: cache refresh and WalletCredited event of each winner
+	for _, player := range wonLotteryPlayers{
+		event := dto.WalletCreditedEvent{
+			UserId:		player.UserId,
+			WalletId:	player.WalletId,
+			Program:	constant.ProgramLotteryWinFirstPrize,
+			Value:		winFirstPrize.Value,
 		}
-		createWalletQuery = createWalletQuery[:len(createWalletQuery)-1]
-		createWalletQuery += `;`
-		_, err := tx.Exec(createWalletQuery)
+		err = service.OutboxService.Add(tx, player.UserId, []string{constant.CacheFamilyTransaction, constant.CacheFamilyUserWallet}, []string{player.WalletId}, constant.EventWalletCredited, event)
 		if err != nil {
-			fmt.Println(createWalletQuery)
-			logger.Error(err.Error())
 			_ = tx.Rollback()
-			return err
+			return settlement, 0, err
 		}
+	}
 
-		_ = tx.Commit()
-
-		// Update Redis
-		for _, player := range wonLotteryPlayers{
-			err = service.RedisService.UpdateUserWalletRedis(player.UserId)
-			if err != nil {
-				logger.Error(err.Error())
-				return err
-			}
-			err = service.RedisService.UpdateTransactionRedis(player.UserId)
-			if err != nil {
-				logger.Error(err.Error())
-				return err
-			}
-		}
-	} else {
-		_ = tx.Commit()
+	updateResultStatement := `UPDATE game_lottery_result SET Winners = Winners + ?, Source = ?, SettledBy = ? WHERE Date = ?;`
+	_, err = tx.Exec(updateResultStatement, len(wonLotteryPlayers), settlement.Source, settlement.SettledBy, settlement.Date)
+	if err != nil {
+		_ = tx.Rollback()
+		logger.Error(err.Error())
+		return settlement, 0, err
 	}
 
-	dt := time.Now()
-	logger.Info("Date: %s\n", dt.Format("01-02-2006"))
-	logger.Info("Result: %s\n", util.ToJSON(lotteryResult))
-	logger.Info("Number of players won the lottery: %d\n", len(wonLotteryPlayers))
+	err = tx.Commit()
+	if err != nil {
+		logger.Error(err.Error())
+		return settlement, 0, err
+	}
 
-	return nil
+	// Update Redis
+	for _, player := range wonLotteryPlayers{
+		service.RedisService.RefreshUserWalletRedis(player.UserId)
+		service.RedisService.RefreshTransactionRedis(player.UserId, player.WalletId)
+	}
+
+	return settlement, 0, nil
 }
 
+/*
+	Special prize is made of digits only, the last two are matched against numbers selected
+ */
+func isLotteryNumber(number string) bool {
+	if len(number) < 2 {
+		return false
+	}
+	for _, digit := range number {
+		if digit < '0' || digit > '9' {
+			return false
+		}
+	}
+	return true
+}
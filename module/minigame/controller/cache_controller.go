@@ -0,0 +1,87 @@
+package controller
+
+import (
+	"context"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/controller"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/response"
+	"g-tech.com/infrastructure/util"
+	"g-tech.com/module/minigame/service"
+	"github.com/labstack/echo"
+)
+
+type CacheController struct {
+	controller.BaseController
+	Service     service.ICacheService
+}
+
+func NewCacheController(cacheService service.ICacheService) *CacheController{
+	return &CacheController{
+		Service: cacheService,
+	}
+}
+
+/*
+	Reset a family of cache (Family: a family or All), for every user or a single one (UserId)
+*/
+func (controller *CacheController) ResetCache(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	reset := dto.CacheReset{}
+	err := echo.Bind(&reset)
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	reset, errorCode, err := controller.Service.ResetCache(ctx, reset)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, reset)
+}
+
+/*
+	Get progress of a cache reset
+*/
+func (controller *CacheController) GetCacheReset(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	resetId := echo.Param("resetId")
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	reset, errorCode, err := controller.Service.GetCacheReset(ctx, resetId)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, reset)
+}
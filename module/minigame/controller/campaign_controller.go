@@ -0,0 +1,161 @@
+package controller
+
+import (
+	"context"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/controller"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/response"
+	"g-tech.com/infrastructure/util"
+	"g-tech.com/module/minigame/service"
+	"github.com/labstack/echo"
+)
+
+type CampaignController struct {
+	controller.BaseController
+	Service     service.ICampaignService
+}
+
+func NewCampaignController(campaignService service.ICampaignService) *CampaignController{
+	return &CampaignController{
+		Service: campaignService,
+	}
+}
+
+/*
+	Get all campaign
+*/
+func (controller *CampaignController) GetAllCampaign(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	pageSize, pageIndex, err := controller.GetPaging(echo)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	listCampaign, err := controller.Service.GetAllCampaign(ctx, pageSize, pageIndex)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, listCampaign)
+}
+
+/*
+	Create new campaign
+*/
+func (controller *CampaignController) CreateCampaign(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	campaign := dto.InvitationCampaign{}
+	err := echo.Bind(&campaign)
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+
+	// 3. validate object
+	if ok, err := controller.IsValid(&campaign); !ok && err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	errorCode, err := controller.Service.CreateCampaign(ctx, campaign)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	return controller.WriteSuccessEmptyContent(echo)
+}
+
+/*
+	Update campaign
+*/
+func (controller *CampaignController) UpdateCampaign(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	campaign := dto.InvitationCampaign{}
+	err := echo.Bind(&campaign)
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+
+	// 3. validate object
+	if ok, err := controller.IsValid(&campaign); !ok && err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	errorCode, err := controller.Service.UpdateCampaign(ctx, campaign)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode == gerror.ErrorCampaignNotFound {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusNotFound(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	return controller.WriteSuccessEmptyContent(echo)
+}
+
+/*
+	Get statistic of campaign
+*/
+func (controller *CampaignController) GetCampaignStatistic(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	campaignId := echo.Param("campaignId")
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	statistic, err := controller.Service.GetCampaignStatistic(ctx, campaignId)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, statistic)
+}
@@ -0,0 +1,123 @@
+package controller
+
+import (
+	"context"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/controller"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/response"
+	"g-tech.com/infrastructure/util"
+	"g-tech.com/module/minigame/service"
+	"github.com/labstack/echo"
+	"strconv"
+)
+
+type FraudController struct {
+	controller.BaseController
+	Service     service.IFraudService
+}
+
+func NewFraudController(fraudService service.IFraudService) *FraudController{
+	return &FraudController{
+		Service: fraudService,
+	}
+}
+
+/*
+	Get list invitation review by status (default: pending)
+*/
+func (controller *FraudController) ListInvitationReviews(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	pageSize, pageIndex, err := controller.GetPaging(echo)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+	status, _ 		:= strconv.Atoi(echo.QueryParam("status"))
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	listReview, err := controller.Service.ListInvitationReviews(ctx, status, pageSize, pageIndex)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, listReview)
+}
+
+/*
+	Approve invitation review
+*/
+func (controller *FraudController) ApproveInvitationReview(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	review := dto.InvitationReview{}
+	err := echo.Bind(&review)
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	errorCode, err := controller.Service.ApproveInvitationReview(ctx, review)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccessEmptyContent(echo)
+}
+
+/*
+	Reject invitation review
+*/
+func (controller *FraudController) RejectInvitationReview(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	review := dto.InvitationReview{}
+	err := echo.Bind(&review)
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	errorCode, err := controller.Service.RejectInvitationReview(ctx, review)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccessEmptyContent(echo)
+}
@@ -10,6 +10,7 @@ import (
 	"g-tech.com/infrastructure/util"
 	"g-tech.com/module/minigame/service"
 	"github.com/labstack/echo"
+	"strconv"
 )
 
 type InvitingController struct {
@@ -43,6 +44,7 @@ func (controller *InvitingController) CreateNewInvitation(echo echo.Context) err
 		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
 		return controller.WriteBadRequest(echo, message, errRes)
 	}
+	invitation.IpAddress = echo.RealIP()
 
 	// 4. Define Context
 	ctx := echo.Request().Context()
@@ -69,6 +71,7 @@ func (controller *InvitingController) CreateNewInvitation(echo echo.Context) err
 
 /*
 	Get invited code
+	Deprecated: phone number is exposed in the url, use GetInvitingCodeByUserId
 */
 func (controller *InvitingController) GetInvitingCode(echo echo.Context) error{
 	// 0. log ip
@@ -84,6 +87,10 @@ func (controller *InvitingController) GetInvitingCode(echo echo.Context) error{
 	}
 
 	invitingCode, err := controller.Service.GetInvitingCode(ctx, phoneNumber)
+	if err == util.ErrInvalidPhoneNumber {
+		message, errRes := response.NewErrorResponse(gerror.ErrorInvalidPhoneNumber, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
 	if err != nil {
 		message, errRes := response.NewErrorResponse(gerror.ErrorNotFound, err.Error(), util.FuncName())
 		return controller.WriteInternalServerError(echo, message, errRes)
@@ -97,3 +104,143 @@ func (controller *InvitingController) GetInvitingCode(echo echo.Context) error{
 }
 
 
+/*
+	Get list invited users
+*/
+func (controller *InvitingController) ListInvitees(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	userId 			:= echo.Param("userId")
+	pageSize, pageIndex, err := controller.GetPaging(echo)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	listInvitee, err := controller.Service.ListInvitees(ctx, userId, pageSize, pageIndex)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, listInvitee)
+}
+
+/*
+	Get weekly leaderboard of inviting users
+*/
+func (controller *InvitingController) GetReferralLeaderboard(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	userId 			:= echo.QueryParam("userId")
+	pageSize, _ 	:= strconv.Atoi(echo.QueryParam("pageSize"))
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	leaderboard, err := controller.Service.GetReferralLeaderboard(ctx, userId, pageSize)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, leaderboard)
+}
+
+/*
+	Get invited code of authenticated user with its sharing payload
+*/
+func (controller *InvitingController) GetInvitingCodeByUserId(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	userId, err := controller.GetAuthenticatedUserId(echo)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorUnauthenticated, err.Error(), util.FuncName())
+		return controller.WriteUnauthorized(echo, message, errRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	invitationLink, err := controller.Service.GetInvitingCodeByUserId(ctx, userId)
+	if err == util.ErrInvalidPhoneNumber {
+		message, errRes := response.NewErrorResponse(gerror.ErrorInvalidPhoneNumber, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorNotFound, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, invitationLink)
+}
+
+/*
+	Set a code chosen by authenticated user
+*/
+func (controller *InvitingController) UpdateVanityCode(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	userId, err := controller.GetAuthenticatedUserId(echo)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorUnauthenticated, err.Error(), util.FuncName())
+		return controller.WriteUnauthorized(echo, message, errRes)
+	}
+
+	invitation := dto.Invitation{}
+	err = echo.Bind(&invitation)
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+	// Code of another user can not be changed
+	invitation.UserId = userId
+
+	// 3. validate object
+	if ok, err := controller.IsValid(&invitation); !ok && err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	invitationLink, errorCode, err := controller.Service.UpdateVanityCode(ctx, invitation)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode == gerror.ErrorInvitingUserDoesNotExisted {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusNotFound(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, invitationLink)
+}
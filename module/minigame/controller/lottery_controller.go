@@ -2,24 +2,28 @@ package controller
 
 import (
 	"context"
+	"g-tech.com/constant"
 	"g-tech.com/dto"
 	"g-tech.com/gerror"
 	"g-tech.com/infrastructure/controller"
 	"g-tech.com/infrastructure/logger"
 	"g-tech.com/infrastructure/response"
 	"g-tech.com/infrastructure/util"
+	"g-tech.com/module/lottery/summary"
 	"g-tech.com/module/minigame/service"
 	"github.com/labstack/echo"
 )
 
 type LotteryController struct {
 	controller.BaseController
-	Service     service.ILotteryService
+	Service     	service.ILotteryService
+	SummaryService	summary.ISummaryLotteryService
 }
 
-func NewLotteryController(lotteryService service.ILotteryService) *LotteryController{
+func NewLotteryController(lotteryService service.ILotteryService, summaryService summary.ISummaryLotteryService) *LotteryController{
 	return &LotteryController{
-		Service: lotteryService,
+		Service: 		lotteryService,
+		SummaryService:	summaryService,
 	}
 }
 
@@ -89,3 +93,39 @@ func (controller *LotteryController) CreateLotteryNumber(echo echo.Context) erro
 	return controller.WriteSuccessEmptyContent(echo)
 }
 
+
+/*
+	Settle a lottery date by hand, when its result was not consumed
+	Body: Date, Result, DryRun (winners are listed, nobody is paid), SettledBy
+*/
+func (controller *LotteryController) SettleLottery(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	settlement := dto.LotterySettlement{}
+	err := echo.Bind(&settlement)
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+	settlement.Source = constant.LotterySourceManual
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	settlement, errorCode, err := controller.SummaryService.SettleLottery(ctx, settlement)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, settlement)
+}
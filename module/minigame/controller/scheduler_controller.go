@@ -0,0 +1,111 @@
+package controller
+
+import (
+	"context"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/controller"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/response"
+	"g-tech.com/infrastructure/util"
+	"g-tech.com/module/minigame/service"
+	"github.com/labstack/echo"
+	"strconv"
+)
+
+type SchedulerController struct {
+	controller.BaseController
+	Service     service.ISchedulerService
+}
+
+func NewSchedulerController(schedulerService service.ISchedulerService) *SchedulerController{
+	return &SchedulerController{
+		Service: schedulerService,
+	}
+}
+
+/*
+	Run a job now (queued, picked up by the scheduler)
+	Form: TriggeredBy
+*/
+func (controller *SchedulerController) TriggerJob(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	jobName := echo.Param("jobName")
+	triggeredBy := echo.FormValue("TriggeredBy")
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	run, errorCode, err := controller.Service.TriggerJob(ctx, jobName, triggeredBy)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, run)
+}
+
+/*
+	Get a run of a job
+*/
+func (controller *SchedulerController) GetJobRun(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	runId := echo.Param("runId")
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	run, errorCode, err := controller.Service.GetJobRun(ctx, runId)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, run)
+}
+
+/*
+	Get run history (all jobs when jobName is empty)
+*/
+func (controller *SchedulerController) ListJobRuns(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	jobName 		:= echo.QueryParam("jobName")
+	pageSize, _ 	:= strconv.Atoi(echo.QueryParam("pageSize"))
+	pageIndex, _ 	:= strconv.Atoi(echo.QueryParam("pageIndex"))
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	listRun, err := controller.Service.ListJobRuns(ctx, jobName, pageSize, pageIndex)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, listRun)
+}
@@ -2,6 +2,8 @@ package controller
 
 import (
 	"context"
+	"fmt"
+	"g-tech.com/constant"
 	"g-tech.com/dto"
 	"g-tech.com/gerror"
 	"g-tech.com/infrastructure/controller"
@@ -11,6 +13,8 @@ import (
 	"g-tech.com/module/minigame/service"
 	"github.com/labstack/echo"
 	"strconv"
+	"strings"
+	"time"
 )
 
 type UserController struct {
@@ -80,15 +84,60 @@ func (controller *UserController) ListTransactions(echo echo.Context) error{
 }
 
 /*
-	Get received transaction
-*/
-func (controller *UserController) GetReceivedTransaction(echo echo.Context) error{
+	Get transaction history
+	Query: program (comma separated), fromDate, toDate (yyyy-mm-dd, inclusive), direction (received, used), cursor, pageSize
+ */
+func (controller *UserController) ListTransactionHistory(echo echo.Context) error{
 	// 1. Log ip
 	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
 
 	userId 			:= echo.Param("userId")
 	pageSize, _ 	:= strconv.Atoi(echo.QueryParam("pageSize"))
-	pageIndex, _ 	:= strconv.Atoi(echo.QueryParam("pageIndex"))
+
+	filter := dto.TransactionFilter{
+		PageSize: pageSize,
+	}
+	for _, program := range strings.Split(echo.QueryParam("program"), ",") {
+		if program = strings.TrimSpace(program); program != "" {
+			filter.Programs = append(filter.Programs, program)
+		}
+	}
+
+	var err error
+	if fromDate := echo.QueryParam("fromDate"); fromDate != "" {
+		filter.FromDate, err = time.Parse(constant.DateLayout, fromDate)
+		if err != nil {
+			message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+			return controller.WriteBadRequest(echo, message, errRes)
+		}
+	}
+	if toDate := echo.QueryParam("toDate"); toDate != "" {
+		filter.ToDate, err = time.Parse(constant.DateLayout, toDate)
+		if err != nil {
+			message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+			return controller.WriteBadRequest(echo, message, errRes)
+		}
+		filter.ToDate = filter.ToDate.AddDate(0, 0, 1)
+	}
+
+	switch echo.QueryParam("direction") {
+	case "":
+	case "received":
+		filter.Direction = 1
+	case "used":
+		filter.Direction = -1
+	default:
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, "direction must be received or used", util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	if cursor := echo.QueryParam("cursor"); cursor != "" {
+		filter.CursorTime, filter.CursorId, err = util.DecodeCursor(cursor)
+		if err != nil {
+			message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+			return controller.WriteBadRequest(echo, message, errRes)
+		}
+	}
 
 	// 2. Defines context
 	ctx := echo.Request().Context()
@@ -96,24 +145,123 @@ func (controller *UserController) GetReceivedTransaction(echo echo.Context) erro
 		ctx = context.Background()
 	}
 
-	var listTransactions []dto.User
-	listTransactions, err := controller.Service.ListTransactions(ctx, userId, 1, pageSize, pageIndex)
+	page, err := controller.Service.ListTransactionHistory(ctx, userId, filter)
 	if err != nil {
 		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
 		return controller.WriteInternalServerError(echo, message, errRes)
 	}
 
-	return controller.WriteSuccess(echo, listTransactions)
+	return controller.WriteSuccess(echo, page)
 }
 
 /*
-	Get used transaction
-*/
-func (controller *UserController) GetUsedTransaction(echo echo.Context) error{
+	Get monthly statement of wallet
+	Query: month (yyyy-mm, default: current month)
+ */
+func (controller *UserController) GetWalletStatement(echo echo.Context) error{
+	// 1. Log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	userId := echo.Param("userId")
+	month, err := parseStatementMonth(echo.QueryParam("month"))
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	// 2. Defines context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	statement, err := controller.Service.GetWalletStatement(ctx, userId, month)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, statement)
+}
+
+/*
+	Download monthly statement of wallet as CSV
+	Query: month (yyyy-mm, default: current month)
+ */
+func (controller *UserController) ExportWalletStatement(echo echo.Context) error{
 	// 1. Log ip
 	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
 
 	userId := echo.Param("userId")
+	month, err := parseStatementMonth(echo.QueryParam("month"))
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	// 2. Defines context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	statement, err := controller.Service.GetWalletStatement(ctx, userId, month)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	fileName := fmt.Sprintf("statement_%s_%s.csv", statement.UserId, statement.Month)
+	return controller.WriteCSV(echo, fileName, statementRecords(statement))
+}
+
+/*
+	Month of statement, current month when empty
+ */
+func parseStatementMonth(month string) (time.Time, error) {
+	if month == "" {
+		return time.Now().UTC(), nil
+	}
+	return time.Parse("2006-01", month)
+}
+
+/*
+	Rows of statement CSV: summary, credits and debits by program, then entries
+ */
+func statementRecords(statement dto.WalletStatement) [][]string {
+	records := [][]string{
+		{"UserId", statement.UserId},
+		{"Month", statement.Month},
+		{"OpeningBalance", strconv.Itoa(statement.OpeningBalance)},
+		{"TotalCredit", strconv.Itoa(statement.TotalCredit)},
+		{"TotalDebit", strconv.Itoa(statement.TotalDebit)},
+		{"ClosingBalance", strconv.Itoa(statement.ClosingBalance)},
+		{},
+		{"Type", "Program", "Description", "Count", "Value"},
+	}
+	for _, line := range statement.Credits {
+		records = append(records, []string{"Credit", line.Program, line.Description, strconv.Itoa(line.Count), strconv.Itoa(line.Value)})
+	}
+	for _, line := range statement.Debits {
+		records = append(records, []string{"Debit", line.Program, line.Description, strconv.Itoa(line.Count), strconv.Itoa(line.Value)})
+	}
+
+	records = append(records, []string{}, []string{"Date", "Program", "Description", "Value", "Balance"})
+	for _, entry := range statement.Entries {
+		records = append(records, []string{entry.Date, entry.Program, entry.Description, strconv.Itoa(entry.Value), strconv.Itoa(entry.Balance)})
+	}
+
+	return records
+}
+
+/*
+	Get received transaction
+*/
+func (controller *UserController) GetReceivedTransaction(echo echo.Context) error{
+	// 1. Log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	userId 			:= echo.Param("userId")
 	pageSize, _ 	:= strconv.Atoi(echo.QueryParam("pageSize"))
 	pageIndex, _ 	:= strconv.Atoi(echo.QueryParam("pageIndex"))
 
@@ -124,7 +272,7 @@ func (controller *UserController) GetUsedTransaction(echo echo.Context) error{
 	}
 
 	var listTransactions []dto.User
-	listTransactions, err := controller.Service.ListTransactions(ctx, userId, -1, pageSize, pageIndex)
+	listTransactions, err := controller.Service.ListTransactions(ctx, userId, 1, pageSize, pageIndex)
 	if err != nil {
 		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
 		return controller.WriteInternalServerError(echo, message, errRes)
@@ -136,10 +284,14 @@ func (controller *UserController) GetUsedTransaction(echo echo.Context) error{
 /*
 	Get used transaction
 */
-func (controller *UserController) ResetRedis(echo echo.Context) error{
+func (controller *UserController) GetUsedTransaction(echo echo.Context) error{
 	// 1. Log ip
 	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
 
+	userId := echo.Param("userId")
+	pageSize, _ 	:= strconv.Atoi(echo.QueryParam("pageSize"))
+	pageIndex, _ 	:= strconv.Atoi(echo.QueryParam("pageIndex"))
+
 	// 2. Defines context
 	ctx := echo.Request().Context()
 	if ctx == nil {
@@ -147,7 +299,7 @@ func (controller *UserController) ResetRedis(echo echo.Context) error{
 	}
 
 	var listTransactions []dto.User
-	err := controller.Service.ResetRedis(ctx)
+	listTransactions, err := controller.Service.ListTransactions(ctx, userId, -1, pageSize, pageIndex)
 	if err != nil {
 		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
 		return controller.WriteInternalServerError(echo, message, errRes)
@@ -0,0 +1,181 @@
+package controller
+
+import (
+	"context"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/controller"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/response"
+	"g-tech.com/infrastructure/util"
+	"g-tech.com/module/minigame/service"
+	"github.com/labstack/echo"
+	"strconv"
+)
+
+type WalletController struct {
+	controller.BaseController
+	Service     service.IWalletService
+}
+
+func NewWalletController(walletService service.IWalletService) *WalletController{
+	return &WalletController{
+		Service: walletService,
+	}
+}
+
+/*
+	Credit or debit coins of a user
+*/
+func (controller *WalletController) CreateAdjustment(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	adjustment := dto.WalletAdjustment{}
+	err := echo.Bind(&adjustment)
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	adjustment, errorCode, err := controller.Service.CreateAdjustment(ctx, adjustment)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, adjustment)
+}
+
+/*
+	Grant coins to users listed in a CSV file (first column: UserId)
+	Form: File, Value, Reason, TicketReference, CreatedBy
+*/
+func (controller *WalletController) CreateBulkAdjustment(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	value, err := strconv.Atoi(echo.FormValue("Value"))
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+	adjustment := dto.WalletAdjustment{
+		Value:				value,
+		Reason:				echo.FormValue("Reason"),
+		TicketReference:	echo.FormValue("TicketReference"),
+		CreatedBy:			echo.FormValue("CreatedBy"),
+	}
+
+	file, err := echo.FormFile("File")
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+	src, err := file.Open()
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+	defer src.Close()
+
+	userIds, err := util.ReadCSVColumn(src, "UserId")
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	result, errorCode, err := controller.Service.CreateBulkAdjustment(ctx, adjustment, userIds)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, result)
+}
+
+/*
+	Get audit trail of adjustments (all users when userId is empty)
+*/
+func (controller *WalletController) ListAdjustments(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	userId 			:= echo.QueryParam("userId")
+	pageSize, pageIndex, err := controller.GetPaging(echo)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorValidData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	listAdjustment, err := controller.Service.ListAdjustments(ctx, userId, pageSize, pageIndex)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorRetrieveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, listAdjustment)
+}
+
+/*
+	Gift coins to another user
+*/
+func (controller *WalletController) Transfer(echo echo.Context) error{
+	// 0. log ip
+	logger.Trace("From %s call to %s", echo.RealIP(), util.FuncName())
+
+	// 1. get param
+	transfer := dto.WalletTransfer{}
+	err := echo.Bind(&transfer)
+	if err != nil {
+		message, errorRes := response.NewErrorResponse(gerror.ErrorBindData, err.Error(), util.FuncName())
+		return controller.WriteBadRequest(echo, message, errorRes)
+	}
+
+	// 4. Define Context
+	ctx := echo.Request().Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	transfer, errorCode, err := controller.Service.Transfer(ctx, transfer)
+	if err != nil {
+		message, errRes := response.NewErrorResponse(gerror.ErrorSaveData, err.Error(), util.FuncName())
+		return controller.WriteInternalServerError(echo, message, errRes)
+	}
+	if errorCode != 0 {
+		message, errRes := response.NewErrorResponse(errorCode, "", util.FuncName())
+		return controller.WriteStatusConflict(echo, message, errRes)
+	}
+
+	return controller.WriteSuccess(echo, transfer)
+}
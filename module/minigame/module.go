@@ -3,6 +3,7 @@ package minigame
 import (
 	"database/sql"
 	"g-tech.com/infrastructure/cache"
+	"g-tech.com/module/lottery/summary"
 	"g-tech.com/module/minigame/controller"
 	"g-tech.com/module/minigame/service"
 	"github.com/labstack/echo"
@@ -16,6 +17,11 @@ var mobileCardVendorController 	*controller.MobileCardVendorController
 var userController 				*controller.UserController
 var readDailyController 		*controller.ReadDailyController
 var lotteryController			*controller.LotteryController
+var fraudController				*controller.FraudController
+var campaignController			*controller.CampaignController
+var walletController			*controller.WalletController
+var cacheController				*controller.CacheController
+var schedulerController			*controller.SchedulerController
 
 func Initialize(e *echo.Echo, dbContext *sql.DB, cache cache.CacheManager, timeout time.Duration){
 	redisService 				:= service.NewRedisService(dbContext, cache, timeout)
@@ -24,25 +30,43 @@ func Initialize(e *echo.Echo, dbContext *sql.DB, cache cache.CacheManager, timeo
 	prizeService 				:= service.NewPrizeService(dbContext, cache, redisService, timeout)
 	prizeController 			= controller.NewPrizeController(prizeService)
 
-	invitingService 			:= service.NewInvitingService(dbContext, cache, redisService, configService, timeout)
+	outboxService 				:= service.NewOutboxService(dbContext, redisService, timeout)
+
+	walletService 				:= service.NewWalletService(dbContext, cache, redisService, configService, outboxService, timeout)
+	walletController 			= controller.NewWalletController(walletService)
+
+	fraudService 				:= service.NewFraudService(dbContext, cache, redisService, walletService, timeout)
+	fraudController 			= controller.NewFraudController(fraudService)
+
+	campaignService 			:= service.NewCampaignService(dbContext, cache, timeout)
+	campaignController 			= controller.NewCampaignController(campaignService)
+
+	invitingService 			:= service.NewInvitingService(dbContext, cache, redisService, configService, fraudService, campaignService, walletService, outboxService, timeout)
 	invitingController	 		= controller.NewInvitingController(invitingService)
 
 	lotteryService 				:= service.NewLotteryService(dbContext, cache, redisService, configService, timeout)
-	lotteryController	 		= controller.NewLotteryController(lotteryService)
+	lotterySummaryService 		:= summary.NewLotterySummaryService(dbContext, cache, redisService, configService, walletService, outboxService, timeout)
+	lotteryController	 		= controller.NewLotteryController(lotteryService, lotterySummaryService)
 
 
-	userService 				:= service.NewUserService(dbContext, cache, redisService, timeout)
+	userService 				:= service.NewUserService(dbContext, cache, redisService, walletService, timeout)
 	userController 				= controller.NewUserController(userService)
 
-	readDailyService 			:= service.NewReadDailyService(dbContext, cache, redisService, timeout)
+	readDailyService 			:= service.NewReadDailyService(dbContext, cache, redisService, walletService, timeout)
 	readDailyController 		= controller.NewReadDailyController(readDailyService)
 
-	mobileCardService 			:= service.NewMobileCardService(dbContext, cache, redisService, configService, timeout)
+	mobileCardService 			:= service.NewMobileCardService(dbContext, cache, redisService, configService, walletService, outboxService, timeout)
 	mobileCardController 		= controller.NewMobileCardController(mobileCardService)
 
 	mobileCardVendorService 		:= service.NewMobileCardVendorService(dbContext, cache, redisService, timeout)
 	mobileCardVendorController 		= controller.NewMobileCardVendorController(mobileCardVendorService)
 
+	cacheService 				:= service.NewCacheService(cache, timeout)
+	cacheController 			= controller.NewCacheController(cacheService)
+
+	schedulerService 			:= service.NewSchedulerService(dbContext, timeout)
+	schedulerController 		= controller.NewSchedulerController(schedulerService)
+
 	initRouter(e)
 }
 
@@ -56,7 +80,30 @@ func initRouter(e *echo.Echo){
 
 	// Invitation
 	e.POST("/game/api/v1.0/mini-game/invitation", invitingController.CreateNewInvitation)
-	e.GET("/game/api/v1.0/mini-game/invitation/code/:phoneNumber", invitingController.GetInvitingCode)
+	e.GET("/game/api/v1.0/mini-game/invitation/code/:phoneNumber", invitingController.GetInvitingCode)		// Deprecated
+	e.GET("/game/api/v1.0/mini-game/invitation/code", invitingController.GetInvitingCodeByUserId)
+	e.PUT("/game/api/v1.0/mini-game/invitation/code", invitingController.UpdateVanityCode)
+	e.GET("/game/api/v1.0/mini-game/invitation/list/:userId", invitingController.ListInvitees)
+	e.GET("/game/api/v1.0/mini-game/invitation/leaderboard", invitingController.GetReferralLeaderboard)
+
+	// Wallet
+	e.POST("/game/api/v1.0/mini-game/wallet/transfer", walletController.Transfer)
+
+	// Wallet Management
+	e.POST("/game/api/v1.0/wallet-management/adjustment/add", walletController.CreateAdjustment)
+	e.POST("/game/api/v1.0/wallet-management/adjustment/bulk", walletController.CreateBulkAdjustment)
+	e.GET("/game/api/v1.0/wallet-management/adjustment/list", walletController.ListAdjustments)
+
+	// Invitation Campaign Management
+	e.GET("/game/api/v1.0/campaign-management/list", campaignController.GetAllCampaign)
+	e.POST("/game/api/v1.0/campaign-management/add", campaignController.CreateCampaign)
+	e.PUT("/game/api/v1.0/campaign-management/update", campaignController.UpdateCampaign)
+	e.GET("/game/api/v1.0/campaign-management/statistic/:campaignId", campaignController.GetCampaignStatistic)
+
+	// Invitation Fraud Management
+	e.GET("/game/api/v1.0/fraud-management/invitation/list", fraudController.ListInvitationReviews)
+	e.PUT("/game/api/v1.0/fraud-management/invitation/approve", fraudController.ApproveInvitationReview)
+	e.PUT("/game/api/v1.0/fraud-management/invitation/reject", fraudController.RejectInvitationReview)
 
 	// MobileCard
 	e.POST("/game/api/v1.0/mini-game/exchange-mobile-card/exchange", mobileCardController.ExchangeMobileCard)
@@ -96,11 +143,23 @@ func initRouter(e *echo.Echo){
 	e.GET("/game/api/v1.0/lottery/selected-numbers/:userId", lotteryController.GetSelectedNumbers)
 	e.POST("/game/api/v1.0/lottery/add", lotteryController.CreateLotteryNumber)
 
+	// Lottery Management
+	e.POST("/game/api/v1.0/lottery-management/settle", lotteryController.SettleLottery)
+
 	// Transaction
 	e.GET("/game/api/v1.0/mini-game/statistic/transaction/list/:userId", userController.ListTransactions)
 	e.GET("/game/api/v1.0/mini-game/statistic/transaction/received/:userId", userController.GetReceivedTransaction)
 	e.GET("/game/api/v1.0/mini-game/statistic/transaction/used/:userId", userController.GetUsedTransaction)
-
-	//  Redis
-	//e.DELETE("/game/api/v1.0/reset-redis", userController.ResetRedis)
+	e.GET("/game/api/v1.0/mini-game/statistic/transaction/history/:userId", userController.ListTransactionHistory)
+	e.GET("/game/api/v1.0/mini-game/statistic/statement/:userId", userController.GetWalletStatement)
+	e.GET("/game/api/v1.0/mini-game/statistic/statement/:userId/csv", userController.ExportWalletStatement)
+
+	// Cache Management
+	e.POST("/game/api/v1.0/cache-management/reset", cacheController.ResetCache)
+	e.GET("/game/api/v1.0/cache-management/reset/:resetId", cacheController.GetCacheReset)
+
+	// Scheduler Management
+	e.POST("/game/api/v1.0/scheduler-management/job/:jobName/run", schedulerController.TriggerJob)
+	e.GET("/game/api/v1.0/scheduler-management/run/list", schedulerController.ListJobRuns)
+	e.GET("/game/api/v1.0/scheduler-management/run/:runId", schedulerController.GetJobRun)
 }
\ No newline at end of file
@@ -0,0 +1,233 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"g-tech.com/constant"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/cache"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/util"
+	"github.com/go-redis/redis"
+	"time"
+)
+
+/*
+	Families holding keys per user, they can be reset for a single user
+ */
+var userCacheFamilies = []string{constant.CacheFamilyTransaction, constant.CacheFamilyBoughtMobileCard, constant.CacheFamilyUserWallet}
+
+/*
+	Keys written before families were versioned
+ */
+var legacyCachePrefixes = []string{constant.RedisPrefixKeyLegacyAllTransaction, constant.RedisPrefixKeyLegacyBoughtCards}
+
+type ICacheService interface {
+	// For web
+	ResetCache(ctx context.Context, reset dto.CacheReset) (dto.CacheReset, int, error)
+	GetCacheReset(ctx context.Context, resetId string) (dto.CacheReset, int, error)
+}
+
+type CacheService struct {
+	Cache 			cache.CacheManager
+	Timeout    		time.Duration
+}
+
+/*
+	Families are registered as by NewRedisService, so resets work without it (cache reset command)
+ */
+func NewCacheService(cache cache.CacheManager, timeout time.Duration) ICacheService {
+	service := CacheService{}
+	service.Cache = cache
+	service.Timeout = timeout
+	registerCacheFamilies(cache)
+	return &service
+}
+
+/*
+	Reset a family of cache (or All)
+	+ single user: keys of user are deleted right away
+	+ every user: version of families is bumped, then keys of older versions are purged in background
+	Progress is kept in Redis, it can be read by Id from any instance
+ */
+func (service *CacheService) ResetCache(ctx context.Context, reset dto.CacheReset) (dto.CacheReset, int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	families, errorCode := service.resolveFamilies(reset)
+	if errorCode != 0 {
+		return reset, errorCode, nil
+	}
+
+	reset.Id = util.NewUuid()
+	reset.Status = constant.StatusCacheResetRunning
+	reset.Scanned = 0
+	reset.Deleted = 0
+	reset.Error = ""
+	reset.StartedAt = time.Now().Format(constant.DateTimeLayout)
+	reset.FinishedAt = ""
+
+	if reset.UserId != "" {
+		for _, prefixes := range families {
+			for _, prefix := range prefixes {
+				reset.Deleted += service.Cache.DeleteItem(prefix + reset.UserId)
+			}
+		}
+		reset = service.finishCacheReset(reset, nil)
+		return reset, 0, nil
+	}
+
+	for family := range families {
+		_, err := service.Cache.BumpVersion(family)
+		if err != nil {
+			return reset, 0, err
+		}
+	}
+
+	err := service.saveCacheReset(reset)
+	if err != nil {
+		return reset, 0, err
+	}
+
+	go service.purgeCache(reset, families)
+
+	return reset, 0, nil
+}
+
+/*
+	Get progress of a cache reset
+ */
+func (service *CacheService) GetCacheReset(ctx context.Context, resetId string) (dto.CacheReset, int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	var reset dto.CacheReset
+
+	result, err := service.Cache.GetWithError(constant.RedisPrefixKeyCacheReset + resetId)
+	if err == redis.Nil {
+		return reset, gerror.ErrorCacheResetNotFound, nil
+	} else if err != nil {
+		return reset, 0, err
+	}
+
+	err = json.Unmarshal([]byte(result), &reset)
+	if err != nil {
+		logger.Error(err.Error())
+		return reset, 0, err
+	}
+
+	return reset, 0, nil
+}
+
+/*
+	Families targeted by a reset with their key prefixes
+ */
+func (service *CacheService) resolveFamilies(reset dto.CacheReset) (map[string][]string, int) {
+	registered := service.Cache.Families()
+
+	var names []string
+	if reset.Family == constant.CacheFamilyAll {
+		if reset.UserId != "" {
+			names = userCacheFamilies
+		} else {
+			for family := range registered {
+				names = append(names, family)
+			}
+		}
+	} else {
+		if _, existed := registered[reset.Family]; !existed {
+			return nil, gerror.ErrorCacheFamilyNotFound
+		}
+		if reset.UserId != "" && !isUserCacheFamily(reset.Family) {
+			return nil, gerror.ErrorCacheFamilyNotPerUser
+		}
+		names = []string{reset.Family}
+	}
+
+	families := make(map[string][]string)
+	for _, family := range names {
+		families[family] = registered[family]
+	}
+	return families, 0
+}
+
+/*
+	Purge keys of older versions, keys written before versioning and legacy keys (All)
+ */
+func (service *CacheService) purgeCache(reset dto.CacheReset, families map[string][]string) {
+	var scanned, deleted int64
+	progress := func(batchScanned int64, batchDeleted int64) {
+		reset.Scanned = scanned + batchScanned
+		reset.Deleted = deleted + batchDeleted
+		err := service.saveCacheReset(reset)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+	}
+	// Counters of a scan restart from 0, add them up
+	commit := func() {
+		scanned = reset.Scanned
+		deleted = reset.Deleted
+	}
+
+	var patterns []string
+	for family, prefixes := range families {
+		err := service.Cache.PurgeStaleVersions(family, progress)
+		if err != nil {
+			service.finishCacheReset(reset, err)
+			return
+		}
+		commit()
+
+		for _, prefix := range prefixes {
+			patterns = append(patterns, prefix + "*")
+		}
+	}
+	if reset.Family == constant.CacheFamilyAll {
+		for _, prefix := range legacyCachePrefixes {
+			patterns = append(patterns, prefix + "*")
+		}
+	}
+
+	for _, pattern := range patterns {
+		err := service.Cache.ScanDelete(pattern, nil, progress)
+		if err != nil {
+			service.finishCacheReset(reset, err)
+			return
+		}
+		commit()
+	}
+
+	service.finishCacheReset(reset, nil)
+}
+
+func isUserCacheFamily(family string) bool {
+	for _, userFamily := range userCacheFamilies {
+		if userFamily == family {
+			return true
+		}
+	}
+	return false
+}
+
+func (service *CacheService) finishCacheReset(reset dto.CacheReset, err error) dto.CacheReset {
+	reset.Status = constant.StatusCacheResetDone
+	if err != nil {
+		reset.Status = constant.StatusCacheResetFailed
+		reset.Error = err.Error()
+	}
+	reset.FinishedAt = time.Now().Format(constant.DateTimeLayout)
+
+	err = service.saveCacheReset(reset)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+	return reset
+}
+
+func (service *CacheService) saveCacheReset(reset dto.CacheReset) error {
+	return service.Cache.SetWithError(constant.RedisPrefixKeyCacheReset + reset.Id, reset, constant.CacheResetRetention)
+}
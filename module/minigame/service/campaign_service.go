@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"g-tech.com/constant"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/cache"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/repository"
+	"g-tech.com/infrastructure/util"
+	"github.com/pkg/errors"
+	"time"
+)
+
+type ICampaignService interface {
+	// For inviting service
+	ResolveCampaign(tx *sql.Tx, campaignCode string, invitingUserId string) (dto.InvitationCampaign, bool, error)
+
+	// For web
+	GetAllCampaign(ctx context.Context, pageSize int, pageIndex int) ([]dto.InvitationCampaign, error)
+	CreateCampaign(ctx context.Context, campaign dto.InvitationCampaign) (int, error)
+	UpdateCampaign(ctx context.Context, campaign dto.InvitationCampaign) (int, error)
+	GetCampaignStatistic(ctx context.Context, campaignId string) (dto.CampaignStatistic, error)
+}
+
+type CampaignService struct {
+	MySql 			repository.MySqlRepository
+	Cache 			cache.CacheManager
+	Timeout    		time.Duration
+}
+
+func NewCampaignService(dbContext *sql.DB, cache cache.CacheManager, timeout time.Duration) ICampaignService {
+	service := CampaignService{}
+	service.MySql.SetDbContext(dbContext)
+	service.Cache = cache
+	service.Timeout = timeout
+	return &service
+}
+
+/*
+	Find the campaign applied to an invitation at claim time, within the transaction inserting the invitation
+	+ campaign code given: the running campaign with this code
+	+ no campaign code: the latest running campaign open to every invitation
+	Campaign row is locked until the transaction ends, so concurrent claims of an inviting user are counted one by one
+	Campaign is not applied (false) when inviting user has reached its cap
+ */
+func (service *CampaignService) ResolveCampaign(tx *sql.Tx, campaignCode string, invitingUserId string) (dto.InvitationCampaign, bool, error) {
+	var campaign dto.InvitationCampaign
+
+	campaignQuery := `SELECT uuid_from_bin(Id), Name, Code, InvitingValue, InvitedValue, MaxInvitationsPerInviter
+						FROM game_inviting_campaign
+						WHERE Code = ? AND Status = ? AND StartDate <= NOW() AND EndDate > NOW()
+						ORDER BY StartDate DESC
+						LIMIT 1
+						FOR UPDATE;`
+	campaignResult, err := tx.Query(campaignQuery, campaignCode, constant.StatusInvitationCampaignActive)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return campaign, false, err
+	}
+
+	if !campaignResult.Next(){
+		_ = campaignResult.Close()
+		return campaign, false, nil
+	}
+	err = campaignResult.Scan(&campaign.Id, &campaign.Name, &campaign.Code, &campaign.InvitingValue, &campaign.InvitedValue, &campaign.MaxInvitationsPerInviter)
+	_ = campaignResult.Close()
+	if err != nil {
+		logger.Error(err.Error())
+		return campaign, false, err
+	}
+
+	if campaign.MaxInvitationsPerInviter == 0 {
+		return campaign, true, nil
+	}
+
+	// Check cap of inviting user
+	countQuery := `SELECT COUNT(Id) FROM game_inviting WHERE CampaignId = uuid_to_bin(?) AND InvitingUser = uuid_to_bin(?) AND Status <> ?;`
+	countResult, err := tx.Query(countQuery, campaign.Id, invitingUserId, constant.StatusInvitingRejected)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return campaign, false, err
+	}
+	defer countResult.Close()
+
+	var count int
+	if countResult.Next(){
+		err = countResult.Scan(&count)
+		if err != nil {
+			logger.Error(err.Error())
+			return campaign, false, err
+		}
+	}
+
+	return campaign, count < campaign.MaxInvitationsPerInviter, nil
+}
+
+/*
+	Get all campaign
+ */
+func (service *CampaignService) GetAllCampaign(ctx context.Context, pageSize int, pageIndex int) ([]dto.InvitationCampaign, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	limit := pageSize
+	offset := (pageIndex - 1) * pageSize
+
+	getAllCampaignQuery := `SELECT uuid_from_bin(Id), Name, Code, StartDate, EndDate, InvitingValue, InvitedValue, MaxInvitationsPerInviter, Status, CreatedAt, LastUpdatedAt
+							FROM game_inviting_campaign
+							ORDER BY StartDate DESC
+							LIMIT ? OFFSET ?;`
+	getAllCampaignResult, err := service.MySql.DbContext.Query(getAllCampaignQuery, limit, offset)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return nil, err
+	}
+	defer getAllCampaignResult.Close()
+
+	var listCampaign []dto.InvitationCampaign
+	for getAllCampaignResult.Next() {
+		var campaign dto.InvitationCampaign
+		err = getAllCampaignResult.Scan(&campaign.Id, &campaign.Name, &campaign.Code, &campaign.StartDate, &campaign.EndDate, &campaign.InvitingValue, &campaign.InvitedValue,
+			&campaign.MaxInvitationsPerInviter, &campaign.Status, &campaign.CreatedAt, &campaign.LastUpdatedAt)
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
+		listCampaign = append(listCampaign, campaign)
+	}
+
+	return listCampaign, nil
+}
+
+/*
+	Create campaign
+ */
+func (service *CampaignService) CreateCampaign(ctx context.Context, campaign dto.InvitationCampaign) (int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	if !isValidCampaign(campaign) {
+		return gerror.ErrorCampaignInvalid, nil
+	}
+
+	createCampaignStatement, err := service.MySql.DbContext.Prepare(`INSERT INTO game_inviting_campaign(Id, Name, Code, StartDate, EndDate, InvitingValue, InvitedValue, MaxInvitationsPerInviter, Status)
+																		VALUES (uuid_to_bin(?), ?, ?, ?, ?, ?, ?, ?, ?);`)
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+	defer createCampaignStatement.Close()
+
+	createCampaignResult, err := createCampaignStatement.Exec(util.NewUuid(), campaign.Name, campaign.Code, campaign.StartDate, campaign.EndDate,
+		campaign.InvitingValue, campaign.InvitedValue, campaign.MaxInvitationsPerInviter, campaign.Status)
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+
+	rowsAffected, err := createCampaignResult.RowsAffected()
+	if err != nil {
+		return 0, errors.New("Cannot get row affected")
+	}
+	if rowsAffected == 0 {
+		return 0, errors.New("No row affected")
+	}
+
+	return 0, nil
+}
+
+/*
+	Update campaign
+ */
+func (service *CampaignService) UpdateCampaign(ctx context.Context, campaign dto.InvitationCampaign) (int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	if !isValidCampaign(campaign) {
+		return gerror.ErrorCampaignInvalid, nil
+	}
+
+	updateCampaignStatement, err := service.MySql.DbContext.Prepare(`UPDATE game_inviting_campaign
+																		SET Name = ?, Code = ?, StartDate = ?, EndDate = ?, InvitingValue = ?, InvitedValue = ?,
+																			MaxInvitationsPerInviter = ?, Status = ?
+																		WHERE Id = uuid_to_bin(?);`)
+	if err != nil {
+		return 0, err
+	}
+	defer updateCampaignStatement.Close()
+
+	updateCampaignResult, err := updateCampaignStatement.Exec(campaign.Name, campaign.Code, campaign.StartDate, campaign.EndDate, campaign.InvitingValue, campaign.InvitedValue,
+		campaign.MaxInvitationsPerInviter, campaign.Status, campaign.Id)
+	if err != nil {
+		return 0, err
+	}
+
+	rowsAffected, err := updateCampaignResult.RowsAffected()
+	if err != nil {
+		return 0, errors.New("Cannot get row affected")
+	}
+	if rowsAffected == 0 {
+		// Nothing changed: either the campaign does not exist or is saved with the same values
+		existed, err := service.isExistedCampaign(campaign.Id)
+		if err != nil {
+			return 0, err
+		}
+		if !existed {
+			return gerror.ErrorCampaignNotFound, nil
+		}
+	}
+
+	return 0, nil
+}
+
+/*
+	Check campaign exists
+ */
+func (service *CampaignService) isExistedCampaign(campaignId string) (bool, error) {
+	existedQuery := `SELECT uuid_from_bin(Id) FROM game_inviting_campaign WHERE Id = uuid_to_bin(?);`
+	existedResult, err := service.MySql.DbContext.Query(existedQuery, campaignId)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return false, err
+	}
+	defer existedResult.Close()
+
+	return existedResult.Next(), nil
+}
+
+/*
+	Campaign starts before it ends and never takes coins
+ */
+func isValidCampaign(campaign dto.InvitationCampaign) bool {
+	startDate, err := time.Parse(constant.CampaignDateLayout, campaign.StartDate)
+	if err != nil {
+		return false
+	}
+	endDate, err := time.Parse(constant.CampaignDateLayout, campaign.EndDate)
+	if err != nil {
+		return false
+	}
+
+	return startDate.Before(endDate) && campaign.InvitingValue >= 0 && campaign.InvitedValue >= 0 && campaign.MaxInvitationsPerInviter >= 0
+}
+
+/*
+	Get statistic of campaign
+	Coins of inviting users are counted once settled
+ */
+func (service *CampaignService) GetCampaignStatistic(ctx context.Context, campaignId string) (dto.CampaignStatistic, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	statistic := dto.CampaignStatistic{
+		CampaignId: campaignId,
+	}
+
+	statisticQuery := `SELECT COUNT(game_inviting.Id), COUNT(DISTINCT game_inviting.InvitingUser),
+							IFNULL(SUM(game_inviting.Status = ?), 0), IFNULL(SUM(game_inviting.Status = ?), 0), IFNULL(SUM(game_inviting.Status = ?), 0),
+							IFNULL(SUM(IF(game_inviting.Status = ?, game_inviting.InvitingValue, 0)), 0), IFNULL(SUM(user_wallet.Value), 0)
+						FROM game_inviting
+						LEFT JOIN user_wallet ON user_wallet.Id = game_inviting.WalletId AND user_wallet.UserId = game_inviting.InvitedUser
+						WHERE game_inviting.CampaignId = uuid_to_bin(?);`
+	statisticResult, err := service.MySql.DbContext.Query(statisticQuery, constant.StatusInvitingPending, constant.StatusInvitingSettled, constant.StatusInvitingExpired,
+		constant.StatusInvitingSettled, campaignId)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return statistic, err
+	}
+	defer statisticResult.Close()
+
+	if statisticResult.Next(){
+		err = statisticResult.Scan(&statistic.Invitations, &statistic.Inviters, &statistic.Pending, &statistic.Settled, &statistic.Expired,
+			&statistic.InvitingCoins, &statistic.InvitedCoins)
+		if err != nil {
+			logger.Error(err.Error())
+			return statistic, err
+		}
+	}
+
+	return statistic, nil
+}
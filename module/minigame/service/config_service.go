@@ -39,7 +39,7 @@ func (service *ConfigService) GetPrize(prizeName string) (dto.Prize, bool, error
 	prize := dto.Prize{}
 	prize, status, err := service.GetPrizeRedis(prizeName)
 	if err == redis.Nil {
-		err = service.RedisService.UpdateAllPrizeRedis()
+		err = service.Cache.Rebuild(constant.RedisPrefixKeyAllPrize, service.RedisService.UpdateAllPrizeRedis)
 		if err == nil {
 			prize, status, err = service.GetPrizeRedis(prizeName)
 		}
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"g-tech.com/constant"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/cache"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/repository"
+	"g-tech.com/infrastructure/util"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+type IFraudService interface {
+	// For inviting service
+	ScoreInvitation(ctx context.Context, invitingUserId string, invitation dto.Invitation) (dto.FraudAssessment, error)
+	CreateInvitationReview(tx *sql.Tx, walletId string, invitingUserId string, invitation dto.Invitation, assessment dto.FraudAssessment, invitingPrize dto.Prize, invitedPrize dto.Prize) error
+
+	// For web
+	ListInvitationReviews(ctx context.Context, status int, pageSize int, pageIndex int) ([]dto.InvitationReview, error)
+	ApproveInvitationReview(ctx context.Context, review dto.InvitationReview) (int, error)
+	RejectInvitationReview(ctx context.Context, review dto.InvitationReview) (int, error)
+}
+
+/*
+	Weights and thresholds of the invitation scoring, loaded from section "Fraud" of config
+ */
+type FraudConfig struct {
+	PendingScore				int
+	DeviceReuseScore			int
+	SharedIpScore				int
+	SharedIpWindowHours			int
+	SharedIpThreshold			int
+	NewAccountScore				int
+	NewAccountMinutes			int
+	InviterVelocityScore		int
+	InviterVelocityWindowHours	int
+	InviterVelocityThreshold	int
+}
+
+type FraudService struct {
+	MySql 			repository.MySqlRepository
+	Cache 			cache.CacheManager
+	RedisService	RedisService
+	WalletService	IWalletService
+	Config			FraudConfig
+	Timeout    		time.Duration
+}
+
+func NewFraudService(dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, walletService IWalletService, timeout time.Duration) IFraudService {
+	service := FraudService{}
+	service.MySql.SetDbContext(dbContext)
+	service.Cache = cache
+	service.RedisService = redisService
+	service.WalletService = walletService
+	service.Config = loadFraudConfig()
+	service.Timeout = timeout
+	return &service
+}
+
+func loadFraudConfig() FraudConfig {
+	viper.SetDefault("Fraud.PendingScore", 60)
+	viper.SetDefault("Fraud.DeviceReuseScore", 60)
+	viper.SetDefault("Fraud.SharedIpScore", 30)
+	viper.SetDefault("Fraud.SharedIpWindowHours", 24)
+	viper.SetDefault("Fraud.SharedIpThreshold", 3)
+	viper.SetDefault("Fraud.NewAccountScore", 20)
+	viper.SetDefault("Fraud.NewAccountMinutes", 2)
+	viper.SetDefault("Fraud.InviterVelocityScore", 30)
+	viper.SetDefault("Fraud.InviterVelocityWindowHours", 1)
+	viper.SetDefault("Fraud.InviterVelocityThreshold", 5)
+
+	return FraudConfig{
+		PendingScore:				viper.GetInt("Fraud.PendingScore"),
+		DeviceReuseScore:			viper.GetInt("Fraud.DeviceReuseScore"),
+		SharedIpScore:				viper.GetInt("Fraud.SharedIpScore"),
+		SharedIpWindowHours:		viper.GetInt("Fraud.SharedIpWindowHours"),
+		SharedIpThreshold:			viper.GetInt("Fraud.SharedIpThreshold"),
+		NewAccountScore:			viper.GetInt("Fraud.NewAccountScore"),
+		NewAccountMinutes:			viper.GetInt("Fraud.NewAccountMinutes"),
+		InviterVelocityScore:		viper.GetInt("Fraud.InviterVelocityScore"),
+		InviterVelocityWindowHours:	viper.GetInt("Fraud.InviterVelocityWindowHours"),
+		InviterVelocityThreshold:	viper.GetInt("Fraud.InviterVelocityThreshold"),
+	}
+}
+
+/*
+	Score an invitation before its rewards are paid
+	+ device has already been used to enter a code
+	+ many codes entered from the same IP recently
+	+ invited account has just been created
+	+ inviting user receives too many invitations in a short time
+ */
+func (service *FraudService) ScoreInvitation(ctx context.Context, invitingUserId string, invitation dto.Invitation) (dto.FraudAssessment, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	var assessment dto.FraudAssessment
+
+	// Device reuse
+	if invitation.DeviceId != "" {
+		deviceCount, err := service.count(`SELECT COUNT(Id) FROM game_inviting_fraud WHERE DeviceId = ?;`, invitation.DeviceId)
+		if err != nil {
+			return assessment, err
+		}
+		if deviceCount > 0 {
+			assessment.Score += service.Config.DeviceReuseScore
+			assessment.Reasons = append(assessment.Reasons, "DeviceReused")
+		}
+	}
+
+	// Shared IP
+	if invitation.IpAddress != "" {
+		ipCount, err := service.count(`SELECT COUNT(Id) FROM game_inviting_fraud WHERE IpAddress = ? AND CreatedAt >= NOW() - INTERVAL ? HOUR;`,
+			invitation.IpAddress, service.Config.SharedIpWindowHours)
+		if err != nil {
+			return assessment, err
+		}
+		if ipCount >= service.Config.SharedIpThreshold {
+			assessment.Score += service.Config.SharedIpScore
+			assessment.Reasons = append(assessment.Reasons, "SharedIp")
+		}
+	}
+
+	// Account age of invited user
+	accountAgeQuery := `SELECT TIMESTAMPDIFF(MINUTE, CreatedAt, NOW()) FROM sso_user WHERE Id = uuid_to_bin(?);`
+	accountAgeResult, err := service.MySql.DbContext.Query(accountAgeQuery, invitation.UserId)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return assessment, err
+	}
+	defer accountAgeResult.Close()
+
+	if accountAgeResult.Next(){
+		var accountAge int
+		err = accountAgeResult.Scan(&accountAge)
+		if err != nil {
+			logger.Error(err.Error())
+			return assessment, err
+		}
+		if accountAge < service.Config.NewAccountMinutes {
+			assessment.Score += service.Config.NewAccountScore
+			assessment.Reasons = append(assessment.Reasons, "NewAccount")
+		}
+	}
+
+	// Inviter velocity
+	velocity, err := service.count(`SELECT COUNT(Id) FROM game_inviting_fraud WHERE InvitingUser = uuid_to_bin(?) AND CreatedAt >= NOW() - INTERVAL ? HOUR;`,
+		invitingUserId, service.Config.InviterVelocityWindowHours)
+	if err != nil {
+		return assessment, err
+	}
+	if velocity >= service.Config.InviterVelocityThreshold {
+		assessment.Score += service.Config.InviterVelocityScore
+		assessment.Reasons = append(assessment.Reasons, "InviterVelocity")
+	}
+
+	assessment.IsSuspicious = assessment.Score >= service.Config.PendingScore
+
+	return assessment, nil
+}
+
+/*
+	Run a COUNT query
+ */
+func (service *FraudService) count(query string, args ...interface{}) (int, error) {
+	countResult, err := service.MySql.DbContext.Query(query, args...)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return 0, err
+	}
+	defer countResult.Close()
+
+	var count int
+	if countResult.Next(){
+		err = countResult.Scan(&count)
+		if err != nil {
+			logger.Error(err.Error())
+			return 0, err
+		}
+	}
+
+	return count, nil
+}
+
+/*
+	Insert review record (table: game_inviting_fraud)
+	Suspicious invitation is kept as pending and its rewards are stored to be paid on approval
+ */
+func (service *FraudService) CreateInvitationReview(tx *sql.Tx, walletId string, invitingUserId string, invitation dto.Invitation, assessment dto.FraudAssessment, invitingPrize dto.Prize, invitedPrize dto.Prize) error {
+	status := constant.StatusInvitationReviewAccepted
+	if assessment.IsSuspicious {
+		status = constant.StatusInvitationReviewPending
+	}
+
+	createReviewStatement := `INSERT INTO game_inviting_fraud(Id, WalletId, InvitingUser, InvitedUser, DeviceId, IpAddress, Score, Reasons, Status, InvitingPrizeId, InvitingValue, InvitedPrizeId, InvitedValue)
+								VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?, ?, ?, ?, ?, uuid_to_bin(?), ?, uuid_to_bin(?), ?);`
+	_, err := tx.Exec(createReviewStatement, util.NewUuid(), walletId, invitingUserId, invitation.UserId, invitation.DeviceId, invitation.IpAddress,
+		assessment.Score, strings.Join(assessment.Reasons, ","), status, invitingPrize.Id, invitingPrize.Value, invitedPrize.Id, invitedPrize.Value)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+/*
+	List reviews by status
+ */
+func (service *FraudService) ListInvitationReviews(ctx context.Context, status int, pageSize int, pageIndex int) ([]dto.InvitationReview, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	limit := pageSize
+	offset := (pageIndex - 1) * pageSize
+
+	listReviewQuery := `SELECT uuid_from_bin(Id), uuid_from_bin(WalletId), uuid_from_bin(InvitingUser), uuid_from_bin(InvitedUser), DeviceId, IpAddress, Score, Reasons, Status,
+							IFNULL(uuid_from_bin(InvitingPrizeId), ""), InvitingValue, IFNULL(uuid_from_bin(InvitedPrizeId), ""), InvitedValue, ReviewedBy, CreatedAt, LastUpdatedAt
+						FROM game_inviting_fraud
+						WHERE Status = ?
+						ORDER BY CreatedAt DESC
+						LIMIT ? OFFSET ?;`
+	listReviewResult, err := service.MySql.DbContext.Query(listReviewQuery, status, limit, offset)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return nil, err
+	}
+	defer listReviewResult.Close()
+
+	var listReview []dto.InvitationReview
+	for listReviewResult.Next(){
+		var review dto.InvitationReview
+		err = listReviewResult.Scan(&review.Id, &review.WalletId, &review.InvitingUser, &review.InvitedUser, &review.DeviceId, &review.IpAddress, &review.Score, &review.Reasons, &review.Status,
+			&review.InvitingPrizeId, &review.InvitingValue, &review.InvitedPrizeId, &review.InvitedValue, &review.ReviewedBy, &review.CreatedAt, &review.LastUpdatedAt)
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
+		listReview = append(listReview, review)
+	}
+
+	return listReview, nil
+}
+
+/*
+	Get pending review by Id
+ */
+func (service *FraudService) getPendingReview(reviewId string) (dto.InvitationReview, bool, error) {
+	var review dto.InvitationReview
+
+	reviewQuery := `SELECT uuid_from_bin(Id), uuid_from_bin(WalletId), uuid_from_bin(InvitingUser), uuid_from_bin(InvitedUser),
+						IFNULL(uuid_from_bin(InvitingPrizeId), ""), InvitingValue, IFNULL(uuid_from_bin(InvitedPrizeId), ""), InvitedValue
+					FROM game_inviting_fraud
+					WHERE Id = uuid_to_bin(?) AND Status = ?;`
+	reviewResult, err := service.MySql.DbContext.Query(reviewQuery, reviewId, constant.StatusInvitationReviewPending)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return review, false, err
+	}
+	defer reviewResult.Close()
+
+	if reviewResult.Next(){
+		err = reviewResult.Scan(&review.Id, &review.WalletId, &review.InvitingUser, &review.InvitedUser,
+			&review.InvitingPrizeId, &review.InvitingValue, &review.InvitedPrizeId, &review.InvitedValue)
+		if err != nil {
+			logger.Error(err.Error())
+			return review, false, err
+		}
+		return review, true, nil
+	}
+
+	return review, false, nil
+}
+
+/*
+	Change status of a pending review
+ */
+func (service *FraudService) updateReviewStatus(tx *sql.Tx, reviewId string, status int, reviewedBy string) error {
+	updateReviewStatement := `UPDATE game_inviting_fraud SET Status = ?, ReviewedBy = ? WHERE Id = uuid_to_bin(?) AND Status = ?;`
+	updateReviewResult, err := tx.Exec(updateReviewStatement, status, reviewedBy, reviewId, constant.StatusInvitationReviewPending)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	rowsAffected, err := updateReviewResult.RowsAffected()
+	if err != nil {
+		return errors.New("Cannot get row affected")
+	}
+	if rowsAffected == 0 {
+		return errors.New("Review has been processed")
+	}
+
+	return nil
+}
+
+/*
+	Change status of the invitation on hold (table: game_inviting)
+ */
+func (service *FraudService) updateInvitingStatus(tx *sql.Tx, walletId string, status int) error {
+	// Qualifying period of inviting reward restarts from the review
+	updateInvitingStatement := `UPDATE game_inviting 
+								SET Status = ?, ExpiredAt = NOW() + INTERVAL TIMESTAMPDIFF(SECOND, CreatedAt, ExpiredAt) SECOND 
+								WHERE WalletId = uuid_to_bin(?) AND Status = ?;`
+	_, err := tx.Exec(updateInvitingStatement, status, walletId, constant.StatusInvitingOnHold)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+/*
+	Approve a pending review, then pay the held reward of invited user
+	Reward of inviting user goes on to the normal settlement
+ */
+func (service *FraudService) ApproveInvitationReview(ctx context.Context, reviewInput dto.InvitationReview) (int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	review, status, err := service.getPendingReview(reviewInput.Id)
+	if err != nil {
+		return 0, err
+	}
+	if status == false {
+		return gerror.ErrorInvitationReviewNotFound, nil
+	}
+
+	// Start transaction
+	tx, err := service.MySql.DbContext.Begin()
+	if err != nil {
+		service.MySql.HandleError(err)
+		return 0, err
+	}
+
+	err = service.updateReviewStatus(tx, review.Id, constant.StatusInvitationReviewApproved, reviewInput.ReviewedBy)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	err = service.updateInvitingStatus(tx, review.WalletId, constant.StatusInvitingPending)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	//	Insert History for invited user (table: user_wallet)
+	err = service.WalletService.CreateCredit(tx, review.WalletId, review.InvitedUser, review.InvitedPrizeId, review.InvitedValue)
+	if err != nil {
+		logger.Error(err.Error())
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+
+	/*
+		Update Redis
+	 */
+	service.RedisService.RefreshTransactionRedis(review.InvitedUser, review.WalletId)
+
+	service.RedisService.RefreshUserWalletRedis(review.InvitedUser)
+
+	return 0, nil
+}
+
+/*
+	Reject a pending review, held rewards are never paid
+ */
+func (service *FraudService) RejectInvitationReview(ctx context.Context, reviewInput dto.InvitationReview) (int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	review, status, err := service.getPendingReview(reviewInput.Id)
+	if err != nil {
+		return 0, err
+	}
+	if status == false {
+		return gerror.ErrorInvitationReviewNotFound, nil
+	}
+
+	// Start transaction
+	tx, err := service.MySql.DbContext.Begin()
+	if err != nil {
+		service.MySql.HandleError(err)
+		return 0, err
+	}
+
+	err = service.updateReviewStatus(tx, review.Id, constant.StatusInvitationReviewRejected, reviewInput.ReviewedBy)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	err = service.updateInvitingStatus(tx, review.WalletId, constant.StatusInvitingRejected)
+	if err != nil {
+		_ = tx.Rollback()
+		return 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+
+	return 0, nil
+}
@@ -12,13 +12,26 @@ import (
 	"g-tech.com/infrastructure/repository"
 	"g-tech.com/infrastructure/util"
 	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"regexp"
+	"strings"
 	"time"
 )
 
+var vanityCodePattern = regexp.MustCompile(`^[A-Z0-9]+$`)
+
 type IInvitingService interface {
 	CreateNewInvitation(ctx context.Context, invitation dto.Invitation) (int, int, error)
 	GetInvitingCode(ctx context.Context, phoneNumber string) (string, error)
 	GenerateInvitingCode(ctx context.Context, phoneNumber string) (string, error)
+	GetInvitingCodeByUserId(ctx context.Context, userId string) (dto.InvitationLink, error)
+	UpdateVanityCode(ctx context.Context, invitation dto.Invitation) (dto.InvitationLink, int, error)
+
+	ListInvitees(ctx context.Context, userId string, pageSize int, pageIndex int) ([]dto.Invitee, error)
+	GetReferralLeaderboard(ctx context.Context, userId string, pageSize int) (dto.ReferralLeaderboard, error)
+
+	// For scheduled job
+	SettleReferralRewards(ctx context.Context) (int, int, error)
 }
 
 type InvitingService struct {
@@ -26,30 +39,110 @@ type InvitingService struct {
 	Cache 			cache.CacheManager
 	RedisService	RedisService
 	ConfigService 	ConfigService
+	FraudService	IFraudService
+	CampaignService	ICampaignService
+	WalletService	IWalletService
+	OutboxService	IOutboxService
+	Config			ReferralConfig
+	InvitationConfig	InvitationConfig
 	Timeout    		time.Duration
 }
 
-func NewInvitingService (dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, configService ConfigService, timeout time.Duration) IInvitingService {
+/*
+	Requirement for inviting user to receive reward, loaded from section "Referral" of config
+ */
+type ReferralConfig struct {
+	RequiredReadDaily		int		// Number of read-daily claims of invited user
+	QualifyingDays			int		// Within number of days after the code is entered
+	SettlementBatchSize		int
+	SecondLevelPercent		int		// Commission for inviter of inviting user, 0 to disable
+}
+
+/*
+	Sharing and vanity code settings, loaded from section "Invitation" of config
+ */
+type InvitationConfig struct {
+	DeepLink				string		// Format with the code, e.g. https://hit.vn/invite?code=%s
+	ShareMessage			string		// Format with the code and the deep link
+	VanityCodeMinLength		int
+	VanityCodeMaxLength		int			// Below util.InvitedCodeMinLength, a vanity code is never a generated one
+	BlockedWords			[]string
+}
+
+func NewInvitingService (dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, configService ConfigService, fraudService IFraudService, campaignService ICampaignService, walletService IWalletService, outboxService IOutboxService, timeout time.Duration) IInvitingService {
 	service := InvitingService{}
 	service.Cache = cache
 	service.RedisService = redisService
 	service.ConfigService = configService
+	service.FraudService = fraudService
+	service.CampaignService = campaignService
+	service.WalletService = walletService
+	service.OutboxService = outboxService
+	service.Config = loadReferralConfig()
+	service.InvitationConfig = loadInvitationConfig()
 	service.MySql.SetDbContext(dbContext)
 	service.Timeout = timeout
 	return &service
 }
 
+func loadReferralConfig() ReferralConfig {
+	viper.SetDefault("Referral.RequiredReadDaily", 3)
+	viper.SetDefault("Referral.QualifyingDays", 7)
+	viper.SetDefault("Referral.SettlementBatchSize", 500)
+	viper.SetDefault("Referral.SecondLevelPercent", 0)
+
+	return ReferralConfig{
+		RequiredReadDaily:		viper.GetInt("Referral.RequiredReadDaily"),
+		QualifyingDays:			viper.GetInt("Referral.QualifyingDays"),
+		SettlementBatchSize:	viper.GetInt("Referral.SettlementBatchSize"),
+		SecondLevelPercent:		viper.GetInt("Referral.SecondLevelPercent"),
+	}
+}
+
+func loadInvitationConfig() InvitationConfig {
+	viper.SetDefault("Invitation.DeepLink", "https://hit.vn/invite?code=%s")
+	viper.SetDefault("Invitation.ShareMessage", "Nhập mã giới thiệu %s để nhận xu trên Hit: %s")
+	viper.SetDefault("Invitation.VanityCodeMinLength", 4)
+	viper.SetDefault("Invitation.VanityCodeMaxLength", util.InvitedCodeMinLength - 1)
+
+	var blockedWords []string
+	for _, word := range viper.GetStringSlice("Invitation.BlockedWords") {
+		blockedWords = append(blockedWords, strings.ToUpper(word))
+	}
+
+	// Generated codes have at least InvitedCodeMinLength characters, shorter vanity codes never take the code of another number
+	vanityCodeMaxLength := viper.GetInt("Invitation.VanityCodeMaxLength")
+	if vanityCodeMaxLength >= util.InvitedCodeMinLength {
+		vanityCodeMaxLength = util.InvitedCodeMinLength - 1
+	}
+
+	return InvitationConfig{
+		DeepLink:				viper.GetString("Invitation.DeepLink"),
+		ShareMessage:			viper.GetString("Invitation.ShareMessage"),
+		VanityCodeMinLength:	viper.GetInt("Invitation.VanityCodeMinLength"),
+		VanityCodeMaxLength:	vanityCodeMaxLength,
+		BlockedWords:			blockedWords,
+	}
+}
+
 /*
 	Get invited code
 	If it is not exited, generate it from phone number
+	Deprecated: phone number is exposed in the url, use GetInvitingCodeByUserId
  */
 func (service *InvitingService) GetInvitingCode(ctx context.Context, phoneNumber string) (string, error) {
 	// 	Setting up timeout
 	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
 	defer cancel()
 
-	getCodeQuery := `SELECT IFNULL(Code, "") FROM sso_user WHERE PhoneNumber = ?;`
-	getCodeResult, err := service.MySql.DbContext.Query(getCodeQuery, phoneNumber)
+	// Number may be stored in another format than the requested one
+	normalizedPhoneNumber, err := util.NormalizePhoneNumber(phoneNumber)
+	if err != nil {
+		return "", err
+	}
+
+	getCodeQuery := `SELECT IFNULL(Code, ""), PhoneNumber FROM sso_user WHERE PhoneNumber = ? OR PhoneNumber = ? LIMIT 1;`
+	getCodeResult, err := service.MySql.DbContext.Query(getCodeQuery, phoneNumber, normalizedPhoneNumber)
 	if err != nil {
 		service.MySql.HandleError(err)
 		return "", err
@@ -58,7 +151,7 @@ func (service *InvitingService) GetInvitingCode(ctx context.Context, phoneNumber
 
 	var code string
 	if getCodeResult.Next(){
-		err := getCodeResult.Scan(&code)
+		err := getCodeResult.Scan(&code, &phoneNumber)
 		if err != nil {
 			logger.Error(err.Error())
 			return "", err
@@ -81,8 +174,137 @@ func (service *InvitingService) GetInvitingCode(ctx context.Context, phoneNumber
 	return code, nil
 }
 
+/*
+	Get invited code of user, with the payload to share it
+	If it is not exited, generate it from phone number of user
+ */
+func (service *InvitingService) GetInvitingCodeByUserId(ctx context.Context, userId string) (dto.InvitationLink, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	var link dto.InvitationLink
+
+	getCodeQuery := `SELECT IFNULL(Code, ""), IFNULL(PhoneNumber, "") FROM sso_user WHERE Id = uuid_to_bin(?);`
+	getCodeResult, err := service.MySql.DbContext.Query(getCodeQuery, userId)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return link, err
+	}
+	defer getCodeResult.Close()
+
+	var code string
+	var phoneNumber string
+	if getCodeResult.Next(){
+		err := getCodeResult.Scan(&code, &phoneNumber)
+		if err != nil {
+			logger.Error(err.Error())
+			return link, err
+		}
+	} else {
+		return link, errors.New("Cannot find user")
+	}
+
+	//	Code is not existed
+	if code == "" {
+		code, err = service.GenerateInvitingCode(ctx, phoneNumber)
+		if err != nil {
+			return link, err
+		}
+	}
+
+	return service.newInvitationLink(code), nil
+}
+
+/*
+	Build the payload to share a code
+ */
+func (service *InvitingService) newInvitationLink(code string) dto.InvitationLink {
+	link := fmt.Sprintf(service.InvitationConfig.DeepLink, code)
+
+	return dto.InvitationLink{
+		Code:		code,
+		Link:		link,
+		Message:	fmt.Sprintf(service.InvitationConfig.ShareMessage, code, link),
+	}
+}
+
+/*
+	Replace invited code of user by a code chosen by user
+	The previous code stops working
+ */
+func (service *InvitingService) UpdateVanityCode(ctx context.Context, invitation dto.Invitation) (dto.InvitationLink, int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	var link dto.InvitationLink
+
+	/*
+		Check requirement
+	 */
+	code := strings.ToUpper(strings.TrimSpace(invitation.Code))
+	if len(code) < service.InvitationConfig.VanityCodeMinLength || len(code) > service.InvitationConfig.VanityCodeMaxLength || !vanityCodePattern.MatchString(code) {
+		return link, gerror.ErrorInvitingCodeInvalid, nil
+	}
+
+	for _, word := range service.InvitationConfig.BlockedWords {
+		if word != "" && strings.Contains(code, word) {
+			return link, gerror.ErrorInvitingCodeNotAllowed, nil
+		}
+	}
+
+	existedCodeQuery := `SELECT uuid_from_bin(Id) FROM sso_user WHERE Code = ? AND Id <> uuid_to_bin(?);`
+	existedCodeResult, err := service.MySql.DbContext.Query(existedCodeQuery, code, invitation.UserId)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return link, 0, err
+	}
+	defer existedCodeResult.Close()
+
+	if existedCodeResult.Next(){
+		return link, gerror.ErrorInvitingCodeExisted, nil
+	}
+
+	/*
+		Update code
+	 */
+	updateCodeStatement := `UPDATE sso_user SET Code = ? WHERE Id = uuid_to_bin(?);`
+	updateCodeResult, err := service.MySql.DbContext.Exec(updateCodeStatement, code, invitation.UserId)
+	if err != nil {
+		// Unique key on Code, another user has taken it meanwhile
+		if strings.Contains(err.Error(), "Duplicate entry") {
+			return link, gerror.ErrorInvitingCodeExisted, nil
+		}
+		service.MySql.HandleError(err)
+		return link, 0, err
+	}
+
+	rowsAffected, err := updateCodeResult.RowsAffected()
+	if err != nil {
+		return link, 0, err
+	}
+	if rowsAffected == 0 {
+		// Nothing changed: either the user does not exist or already owns this code
+		currentCodeQuery := `SELECT Code FROM sso_user WHERE Id = uuid_to_bin(?);`
+		currentCodeResult, err := service.MySql.DbContext.Query(currentCodeQuery, invitation.UserId)
+		if err != nil {
+			service.MySql.HandleError(err)
+			return link, 0, err
+		}
+		defer currentCodeResult.Close()
+
+		if !currentCodeResult.Next() {
+			return link, gerror.ErrorInvitingUserDoesNotExisted, nil
+		}
+	}
+
+	return service.newInvitationLink(code), 0, nil
+}
+
 /*
 	Generate minigame code
+	Returns util.ErrInvalidPhoneNumber when phone number cannot be parsed
  */
 func (service *InvitingService) GenerateInvitingCode(ctx context.Context, phoneNumber string) (string, error){
 	// 	Setting up timeout
@@ -120,7 +342,7 @@ func (service *InvitingService) GenerateInvitingCode(ctx context.Context, phoneN
 	Check InvitedCode inserted (already inserted)
  */
 func (service *InvitingService) checkStatusCodeInserted(invitedUserId string) (bool, error){
-	invitingUserQuery := `SELECT uuid_from_bin(InvitingUser) FROM game_inviting WHERE uuid_from_bin(InvitedUser) = ?;`
+	invitingUserQuery := `SELECT uuid_from_bin(InvitingUser) FROM game_inviting WHERE InvitedUser = uuid_to_bin(?);`
 	invitingUserResult, err := service.MySql.DbContext.Query(invitingUserQuery, invitedUserId)
 	if err != nil {
 		service.MySql.HandleError(err)
@@ -303,69 +525,110 @@ func (service *InvitingService) CreateNewInvitation(ctx context.Context, invitat
 		}
 	}
 
+	// Score invitation
+	assessment, err := service.FraudService.ScoreInvitation(ctx, invitingUserId, invitation)
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, 0, err
+	}
+
 	/*
 		Apply invited code
 	 */
+	// Inviting user is paid later by the settlement job, once invited user is active enough
+	invitingStatus := constant.StatusInvitingPending
+	if assessment.IsSuspicious {
+		invitingStatus = constant.StatusInvitingOnHold
+	}
+
 	// Start transaction
 	tx, err := service.MySql.DbContext.Begin()
-	
+	if err != nil {
+		service.MySql.HandleError(err)
+		return 0, 0, err
+	}
+
+	// Apply running campaign, its cap is counted under the lock of the campaign row
+	campaign, hasCampaign, err := service.CampaignService.ResolveCampaign(tx, invitation.CampaignCode, invitingUserId)
+	if err != nil {
+		logger.Error(err.Error())
+		_ = tx.Rollback()
+		return 0, 0, err
+	}
+	campaignId := ""
+	if hasCampaign {
+		campaignId = campaign.Id
+		invitingPrize.Value = campaign.InvitingValue
+		invitedPrize.Value = campaign.InvitedValue
+	} else if invitation.CampaignCode != "" && campaign.Id == "" {
+		_ = tx.Rollback()
+		return 0, gerror.ErrorCampaignNotFound, nil
+	}
+
 	//	Insert statistic (game_inviting)
-	walletId, status, err := service.CreateInvitingRecord(tx, invitingUserId, invitation.UserId)
+	walletId, status, err := service.CreateInvitingRecord(tx, invitingUserId, invitation.UserId, invitingStatus, invitingPrize, campaignId)
 	if err != nil{
 		logger.Error(err.Error())
 		_ = tx.Rollback()
 		return 0, 0, err
 	}
 
-
-	//	Insert History for minigame user(table: User_wallet)
-	status, err = service.CreateNewHistory(tx, invitingUserId, walletId, invitingPrize.Id, invitingPrize.Value)
+	//	Insert review (game_inviting_fraud)
+	err = service.FraudService.CreateInvitationReview(tx, walletId, invitingUserId, invitation, assessment, invitingPrize, invitedPrize)
 	if err != nil{
 		logger.Error(err.Error())
 		_ = tx.Rollback()
 		return 0, 0, err
 	}
 
-	//	Insert History for minigame user(table: User_wallet)
-	status, err = service.CreateNewHistory(tx, invitation.UserId, walletId, invitedPrize.Id, invitedPrize.Value)
+	event := dto.InvitationCreatedEvent{
+		InvitingUserId:		invitingUserId,
+		InvitedUserId:		invitation.UserId,
+		CampaignId:			campaignId,
+		IsOnHold:			assessment.IsSuspicious,
+	}
+	err = service.OutboxService.Add(tx, invitation.UserId, nil, nil, constant.EventInvitationCreated, event)
 	if err != nil{
-		logger.Error(err.Error())
 		_ = tx.Rollback()
 		return 0, 0, err
 	}
 
-	_ = tx.Commit()
+	//	Suspicious invitation: rewards are held until an admin reviews it
+	if assessment.IsSuspicious {
+		err = tx.Commit()
+		if err != nil {
+			logger.Error(err.Error())
+			return 0, 0, err
+		}
+		logger.Warn("Invitation of %s is pending review, score %d", invitation.UserId, assessment.Score)
 
-	/*
-	 	Update Redis
-	 */
-	// For minigame user
-	err = service.RedisService.UpdateTransactionRedis(invitingUserId)
-	if err != nil {
-		logger.Error(err.Error())
-		return 0, 0, err
+		service.RedisService.RefreshUserWalletRedis(invitation.UserId)
+
+		return 0, gerror.ErrorInvitationPendingReview, nil
 	}
 
-	// For invited user
-	err = service.RedisService.UpdateTransactionRedis(invitation.UserId)
-	if err != nil {
+	//	Insert History for invited user(table: User_wallet)
+	status, err = service.CreateNewHistory(tx, invitation.UserId, walletId, invitedPrize.Id, invitedPrize.Value)
+	if err != nil{
 		logger.Error(err.Error())
+		_ = tx.Rollback()
 		return 0, 0, err
 	}
 
-	//	Update inviting user wallet
-	err = service.RedisService.UpdateUserWalletRedis(invitingUserId)
+	err = tx.Commit()
 	if err != nil {
 		logger.Error(err.Error())
 		return 0, 0, err
 	}
 
+	/*
+	 	Update Redis
+	 */
+	// For invited user
+	service.RedisService.RefreshTransactionRedis(invitation.UserId, walletId)
+
 	//	Update invited user wallet
-	err = service.RedisService.UpdateUserWalletRedis(invitation.UserId)
-	if err != nil {
-		logger.Error(err.Error())
-		return 0, 0, err
-	}
+	service.RedisService.RefreshUserWalletRedis(invitation.UserId)
 
 	return invitedPrize.Value, 0, nil
 }
@@ -373,12 +636,14 @@ func (service *InvitingService) CreateNewInvitation(ctx context.Context, invitat
 
 /*
 	Insert Inviting Record (table: game_inviting)
+	Reward of inviting user is kept on the record until it is settled
  */
-func (service *InvitingService) CreateInvitingRecord(tx *sql.Tx, invitingUserId string, invitedUserId string) (string, bool, error){
-	insertInvitingRecordStatement := `INSERT INTO game_inviting(Id, InvitingUser, InvitedUser, WalletId) VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?));`
+func (service *InvitingService) CreateInvitingRecord(tx *sql.Tx, invitingUserId string, invitedUserId string, status int, invitingPrize dto.Prize, campaignId string) (string, bool, error){
+	insertInvitingRecordStatement := `INSERT INTO game_inviting(Id, InvitingUser, InvitedUser, WalletId, Status, InvitingPrizeId, InvitingValue, ExpiredAt, CampaignId) 
+										VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?, uuid_to_bin(?), ?, NOW() + INTERVAL ? DAY, uuid_to_bin(NULLIF(?, '')));`
 
 	walletId := util.NewUuid()
-	_, err := tx.Exec(insertInvitingRecordStatement, util.NewUuid(), invitingUserId, invitedUserId, walletId)
+	_, err := tx.Exec(insertInvitingRecordStatement, util.NewUuid(), invitingUserId, invitedUserId, walletId, status, invitingPrize.Id, invitingPrize.Value, service.Config.QualifyingDays, campaignId)
 	if err != nil {
 		fmt.Println(err.Error())
 		return walletId, false, err
@@ -393,9 +658,7 @@ func (service *InvitingService) CreateInvitingRecord(tx *sql.Tx, invitingUserId
  */
 func (service *InvitingService) CreateNewHistory(tx *sql.Tx, userId string, walletId string, prizeId string, value int) (bool, error) {
 
-	postInvitingRecordStatement := `INSERT INTO user_wallet(Id, UserId, PrizeId, Value) VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?);`
-
-	_, err := tx.Exec(postInvitingRecordStatement, walletId, userId, prizeId, value)
+	err := service.WalletService.CreateCredit(tx, walletId, userId, prizeId, value)
 	if err != nil {
 		fmt.Println(err.Error())
 		return false, err
@@ -403,3 +666,291 @@ func (service *InvitingService) CreateNewHistory(tx *sql.Tx, userId string, wall
 
 	return true, nil
 }
+
+
+/*
+	Settle pending referral rewards
+	+ pay inviting user when invited user has enough read-daily claims within the qualifying days
+	+ expire the others when their qualifying days are over
+	Returns number of settled and expired invitations
+ */
+func (service *InvitingService) SettleReferralRewards(ctx context.Context) (int, int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	qualifiedQuery := `SELECT uuid_from_bin(game_inviting.Id), uuid_from_bin(game_inviting.InvitingUser), 
+							uuid_from_bin(game_inviting.InvitingPrizeId), game_inviting.InvitingValue
+						FROM game_inviting
+						WHERE game_inviting.Status = ? AND game_inviting.InvitingWalletId IS NULL AND game_inviting.ExpiredAt >= NOW() AND (
+							SELECT COUNT(game_read_daily.Id) FROM game_read_daily 
+							WHERE game_read_daily.UserId = game_inviting.InvitedUser 
+								AND game_read_daily.LastUpdatedAt >= game_inviting.CreatedAt AND game_read_daily.LastUpdatedAt <= game_inviting.ExpiredAt
+						) >= ?
+						LIMIT ?;`
+	qualifiedResult, err := service.MySql.DbContext.Query(qualifiedQuery, constant.StatusInvitingPending, service.Config.RequiredReadDaily, service.Config.SettlementBatchSize)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return 0, 0, err
+	}
+	defer qualifiedResult.Close()
+
+	type qualifiedInvitation struct {
+		Id				string
+		InvitingUser	string
+		PrizeId			string
+		Value			int
+	}
+
+	var qualifiedInvitations []qualifiedInvitation
+	for qualifiedResult.Next(){
+		var invitation qualifiedInvitation
+		err = qualifiedResult.Scan(&invitation.Id, &invitation.InvitingUser, &invitation.PrizeId, &invitation.Value)
+		if err != nil {
+			logger.Error(err.Error())
+			return 0, 0, err
+		}
+		qualifiedInvitations = append(qualifiedInvitations, invitation)
+	}
+
+	// Get prize for second-level commission, it is disabled when the program is not found
+	var secondLevelPrize dto.Prize
+	hasSecondLevel := false
+	if service.Config.SecondLevelPercent > 0 {
+		secondLevelPrize, hasSecondLevel, err = service.ConfigService.GetPrize(constant.ProgramInvitingSecondLevelName)
+		if err != nil {
+			logger.Error(err.Error())
+			return 0, 0, err
+		}
+	}
+
+	settled := 0
+	for _, invitation := range qualifiedInvitations {
+		// Start transaction
+		tx, err := service.MySql.DbContext.Begin()
+		if err != nil {
+			service.MySql.HandleError(err)
+			return settled, 0, err
+		}
+
+		// Guard against another runner settling the same invitation, WalletId is the credit of the invited user
+		invitingWalletId := util.NewUuid()
+		settleStatement := `UPDATE game_inviting SET Status = ?, InvitingWalletId = uuid_to_bin(?) WHERE Id = uuid_to_bin(?) AND Status = ? AND InvitingWalletId IS NULL;`
+		settleResult, err := tx.Exec(settleStatement, constant.StatusInvitingSettled, invitingWalletId, invitation.Id, constant.StatusInvitingPending)
+		if err != nil {
+			logger.Error(err.Error())
+			_ = tx.Rollback()
+			return settled, 0, err
+		}
+		rowsAffected, err := settleResult.RowsAffected()
+		if err != nil || rowsAffected == 0 {
+			_ = tx.Rollback()
+			continue
+		}
+
+		_, err = service.CreateNewHistory(tx, invitation.InvitingUser, invitingWalletId, invitation.PrizeId, invitation.Value)
+		if err != nil {
+			logger.Error(err.Error())
+			_ = tx.Rollback()
+			return settled, 0, err
+		}
+
+		commissionUserId, commissionWalletId := "", ""
+		if hasSecondLevel {
+			commissionUserId, commissionWalletId, err = service.createSecondLevelCommission(tx, invitation.Id, invitation.InvitingUser, invitation.Value, secondLevelPrize)
+			if err != nil {
+				logger.Error(err.Error())
+				_ = tx.Rollback()
+				return settled, 0, err
+			}
+		}
+
+		err = tx.Commit()
+		if err != nil {
+			logger.Error(err.Error())
+			return settled, 0, err
+		}
+		settled++
+
+		// Count on weekly leaderboard
+		err = service.increaseLeaderboard(invitation.InvitingUser)
+		if err != nil {
+			logger.Error(err.Error())
+		}
+
+		/*
+			Update Redis
+		 */
+		for userId, walletId := range map[string]string{invitation.InvitingUser: invitingWalletId, commissionUserId: commissionWalletId} {
+			if userId == "" {
+				continue
+			}
+
+			service.RedisService.RefreshTransactionRedis(userId, walletId)
+
+			service.RedisService.RefreshUserWalletRedis(userId)
+		}
+	}
+
+	// Expire the rest
+	expireStatement := `UPDATE game_inviting SET Status = ? WHERE Status = ? AND ExpiredAt < NOW();`
+	expireResult, err := service.MySql.DbContext.Exec(expireStatement, constant.StatusInvitingExpired, constant.StatusInvitingPending)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return settled, 0, err
+	}
+
+	expired, err := expireResult.RowsAffected()
+	if err != nil {
+		return settled, 0, err
+	}
+
+	return settled, int(expired), nil
+}
+
+/*
+	Pay commission to the user who invited the inviting user (second level)
+	Only when that invitation has been settled. Returns the paid user and its wallet record, empty if none
+ */
+func (service *InvitingService) createSecondLevelCommission(tx *sql.Tx, invitingId string, invitingUserId string, invitingValue int, secondLevelPrize dto.Prize) (string, string, error) {
+	parentQuery := `SELECT uuid_from_bin(InvitingUser) FROM game_inviting WHERE InvitedUser = uuid_to_bin(?) AND Status = ?;`
+	parentResult, err := tx.Query(parentQuery, invitingUserId, constant.StatusInvitingSettled)
+	if err != nil {
+		return "", "", err
+	}
+
+	parentUserId := ""
+	if parentResult.Next(){
+		err = parentResult.Scan(&parentUserId)
+	}
+	_ = parentResult.Close()
+	if err != nil || parentUserId == "" {
+		return "", "", err
+	}
+
+	commission := invitingValue * service.Config.SecondLevelPercent / 100
+	if commission <= 0 {
+		return "", "", nil
+	}
+
+	walletId := util.NewUuid()
+	_, err = service.CreateNewHistory(tx, parentUserId, walletId, secondLevelPrize.Id, commission)
+	if err != nil {
+		return "", "", err
+	}
+
+	updateCommissionStatement := `UPDATE game_inviting SET CommissionUser = uuid_to_bin(?), CommissionWalletId = uuid_to_bin(?) WHERE Id = uuid_to_bin(?);`
+	_, err = tx.Exec(updateCommissionStatement, parentUserId, walletId, invitingId)
+	if err != nil {
+		return "", "", err
+	}
+
+	return parentUserId, walletId, nil
+}
+
+/*
+	Key of the leaderboard of the week containing t
+ */
+func referralLeaderboardKey(t time.Time) (string, string) {
+	year, week := t.ISOWeek()
+	weekName := fmt.Sprintf("%d-W%02d", year, week)
+	return constant.RedisPrefixKeyReferralLeaderboard + weekName, weekName
+}
+
+/*
+	Count a settled invitation for inviting user on the weekly leaderboard (Redis sorted set)
+ */
+func (service *InvitingService) increaseLeaderboard(invitingUserId string) error {
+	key, _ := referralLeaderboardKey(time.Now())
+
+	err := service.Cache.ZIncrBy(key, 1, invitingUserId)
+	if err != nil {
+		return err
+	}
+
+	// Keep some past weeks only
+	return service.Cache.Expire(key, constant.ReferralLeaderboardRetention)
+}
+
+/*
+	Get top inviting users of the current week, with rank of the given user
+ */
+func (service *InvitingService) GetReferralLeaderboard(ctx context.Context, userId string, pageSize int) (dto.ReferralLeaderboard, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	key, weekName := referralLeaderboardKey(time.Now())
+	leaderboard := dto.ReferralLeaderboard{
+		Week: weekName,
+	}
+
+	topInviters, err := service.Cache.ZRevRangeByScore(key, -1, int64(pageSize))
+	if err != nil {
+		logger.Error(err.Error())
+		return leaderboard, err
+	}
+
+	for i, inviter := range topInviters {
+		leaderboard.Items = append(leaderboard.Items, dto.ReferralRank{
+			UserId:			fmt.Sprint(inviter.Member),
+			Invitations:	int(inviter.Score),
+			Rank:			i + 1,
+		})
+	}
+
+	if userId != "" {
+		score := service.Cache.ZGetScore(key, userId)
+		if score > 0 {
+			leaderboard.Me = &dto.ReferralRank{
+				UserId:			userId,
+				Invitations:	int(score),
+				Rank:			int(service.Cache.ZGetCountByMember(key, userId)),
+			}
+		}
+	}
+
+	return leaderboard, nil
+}
+
+/*
+	List users invited by the given user, with reward status and their own number of invitees
+ */
+func (service *InvitingService) ListInvitees(ctx context.Context, userId string, pageSize int, pageIndex int) ([]dto.Invitee, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	limit := pageSize
+	offset := (pageIndex - 1) * pageSize
+
+	listInviteeQuery := `SELECT uuid_from_bin(game_inviting.InvitedUser), game_inviting.Status, game_inviting.InvitingValue, game_inviting.CreatedAt,
+							(SELECT COUNT(second_level.Id) FROM game_inviting AS second_level WHERE second_level.InvitingUser = game_inviting.InvitedUser) AS InviteeCount
+						FROM game_inviting
+						WHERE game_inviting.InvitingUser = uuid_to_bin(?)
+						ORDER BY game_inviting.CreatedAt DESC
+						LIMIT ? OFFSET ?;`
+	listInviteeResult, err := service.MySql.DbContext.Query(listInviteeQuery, userId, limit, offset)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return nil, err
+	}
+	defer listInviteeResult.Close()
+
+	var listInvitee []dto.Invitee
+	for listInviteeResult.Next(){
+		var invitee dto.Invitee
+		err = listInviteeResult.Scan(&invitee.UserId, &invitee.Status, &invitee.Value, &invitee.CreatedAt, &invitee.InviteeCount)
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
+		// Format CreatedAt
+		dt,_ := time.Parse(time.RFC3339, invitee.CreatedAt)
+		invitee.CreatedAt = dt.Format(constant.DateTimeLayout)
+
+		listInvitee = append(listInvitee, invitee)
+	}
+
+	return listInvitee, nil
+}
@@ -53,7 +53,7 @@ func (service *LotteryService) CreateLotteryNumber(ctx context.Context, lotteryP
 	 */
 	getNumberOfSelectedQuery := `SELECT NumberSelected
 									FROM game_lottery
-									WHERE uuid_from_bin(UserId) = ? AND Date = CURRENT_DATE ;`
+									WHERE UserId = uuid_to_bin(?) AND Date = CURRENT_DATE ;`
 	getNumberOfSelectedResult, err := service.MySql.DbContext.Query(getNumberOfSelectedQuery, lotteryPlayer.UserId)
 	if err != nil {
 		logger.Error(err.Error())
@@ -120,7 +120,7 @@ func (service *LotteryService) GetSelectedNumbers(ctx context.Context, userId st
 
 	getSelectedNumbersQuery := `SELECT uuid_from_bin(Id), uuid_from_bin(UserId), NumberSelected, Date
 									FROM game_lottery
-									WHERE uuid_from_bin(UserId) = ? AND Date = CURRENT_DATE 
+									WHERE UserId = uuid_to_bin(?) AND Date = CURRENT_DATE 
 									ORDER BY CreatedAt ASC;`
 	getSelectedNumbersResult, err := service.MySql.DbContext.Query(getSelectedNumbersQuery, userId)
 	if err != nil {
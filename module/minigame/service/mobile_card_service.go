@@ -3,7 +3,6 @@ package service
 import (
 	"context"
 	"database/sql"
-	"encoding/json"
 	"fmt"
 	"g-tech.com/constant"
 	"g-tech.com/dto"
@@ -27,6 +26,9 @@ type IMobileCardService interface {
 	GetMobileCard(ctx context.Context, mobileCardFilter dto.MobileCardFilter, pageSize int, pageIndex int) ([]dto.MobileCard, error)
 	UpdateMobileCard(ctx context.Context, mobileCard dto.MobileCard) error
 	DeleteMobileCard(ctx context.Context, prizeId string) error
+
+	// For command line
+	ImportMobileCards(ctx context.Context, mobileCards []dto.MobileCard) (dto.MobileCardImport, error)
 }
 
 type MobileCardService struct {
@@ -34,15 +36,19 @@ type MobileCardService struct {
 	Cache 			cache.CacheManager
 	RedisService 	RedisService
 	ConfigService	ConfigService
+	WalletService	IWalletService
+	OutboxService	IOutboxService
 	Timeout    		time.Duration
 }
 
-func NewMobileCardService(dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, configService ConfigService, timeout time.Duration) IMobileCardService {
+func NewMobileCardService(dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, configService ConfigService, walletService IWalletService, outboxService IOutboxService, timeout time.Duration) IMobileCardService {
 	service := MobileCardService{}
 	service.MySql.SetDbContext(dbContext)
 	service.Cache = cache
 	service.RedisService = redisService
 	service.ConfigService = configService
+	service.WalletService = walletService
+	service.OutboxService = outboxService
 	service.Timeout = timeout
 	return &service
 }
@@ -56,7 +62,9 @@ func (service *MobileCardService) GetListBoughtMobileCard(ctx context.Context, u
 	var listBoughtMobileCard []dto.MobileCard
 	listBoughtMobileCard, err := service.GetListBoughtMobileCardRedis(ctx, userId, pageSize, pageIndex)
 	if err == redis.Nil {
-		err = service.RedisService.UpdateBoughtMobileCardRedis(userId)
+		err = service.Cache.Rebuild(constant.RedisPrefixKeyBoughtMobileCards + userId, func() error {
+			return service.RedisService.RebuildBoughtMobileCardRedis(userId)
+		})
 		if err == nil {
 			listBoughtMobileCard, err = service.GetListBoughtMobileCardRedis(ctx, userId, pageSize, pageIndex)
 		}
@@ -82,18 +90,8 @@ func (service *MobileCardService) GetListBoughtMobileCardRedis(ctx context.Conte
 	limit := pageSize
 	offset := (pageIndex - 1) * pageSize
 
-	result, err := service.Cache.GetWithError(constant.RedisPrefixKeyBoughtMobileCards + userId)
-	if err == redis.Nil {
-		return nil, err
-	} else if err != nil {
-		logger.Error(err.Error())
-		return nil, err
-	}
-
-	var mobileCards []dto.MobileCard
-	err = json.Unmarshal([]byte(result), &mobileCards)
+	mobileCards, err := service.RedisService.GetBoughtMobileCardPageRedis(userId, offset, limit)
 	if err != nil {
-		logger.Error(err.Error())
 		return nil, err
 	}
 
@@ -110,13 +108,7 @@ func (service *MobileCardService) GetListBoughtMobileCardRedis(ctx context.Conte
 		}
 	}
 
-	if len(mobileCards) >= limit + offset {
-		return mobileCards[offset:offset+limit], nil
-	} else if len(mobileCards) > offset &&  len(mobileCards) < limit + offset{
-		return mobileCards[offset:], nil
-	}else {
-		return mobileCards[0:0], nil
-	}
+	return mobileCards, nil
 }
 
 /*
@@ -135,7 +127,7 @@ func (service *MobileCardService) GetListBoughtMobileCardSQL(ctx context.Context
 
 	getAllBoughtMobileCardQuery := `SELECT uuid_from_bin(mobile_card.Id), mobile_card_vendor.Name, mobile_card.VendorCode, mobile_card.Serial, mobile_card.Code, mobile_card.Value, game_mobile_card.CreatedAt 
 									FROM game_mobile_card, mobile_card, mobile_card_vendor 
-									WHERE mobile_card_vendor.VendorCode = mobile_card.VendorCode AND game_mobile_card.MobileCardId = mobile_card.Id AND uuid_from_bin(game_mobile_card.UserId) = ?
+									WHERE mobile_card_vendor.VendorCode = mobile_card.VendorCode AND game_mobile_card.MobileCardId = mobile_card.Id AND game_mobile_card.UserId = uuid_to_bin(?)
 									ORDER BY game_mobile_card.CreatedAt DESC 
 									LIMIT ? OFFSET ?;`
 	getAllBoughtMobileCardResult, err := service.MySql.DbContext.Query(getAllBoughtMobileCardQuery, userId, limit, offset)
@@ -199,29 +191,6 @@ func (service *MobileCardService) ExchangeMobileCard(ctx context.Context, userEx
 		}
 	}
 
-	// Get user's wallet
-	getUserWalletQuery := `SELECT IFNULL(SUM(Value), 0) AS Wallet  FROM user_wallet WHERE UserId = uuid_to_bin(?);`
-	getUserWalletResult, err := service.MySql.DbContext.Query(getUserWalletQuery, userExchange.UserId)
-	if err != nil {
-		service.MySql.HandleError(err)
-		return mobileCardFailed, 0, err
-	}
-	defer getUserWalletResult.Close()
-
-	var wallet int
-	if getUserWalletResult.Next(){
-		err = getUserWalletResult.Scan(&wallet)
-		if err != nil {
-			return mobileCardFailed, 0, err
-		}
-	}
-
-
-	// Check wallet enough or not?
-	if wallet < - mobileCardPrize.Value * userExchange.Quantity {
-		return mobileCardFailed, gerror.ErrorNotEnoughCoin, nil
-	}
-
 	getMobileCardQuery := `SELECT uuid_from_bin(mobile_card.Id) AS Id, mobile_card_vendor.Name, mobile_card.VendorCode, mobile_card.Serial, mobile_card.Code, mobile_card.Value, mobile_card.Status 
 							FROM mobile_card, mobile_card_vendor
 							WHERE mobile_card.VendorCode = mobile_card_vendor.VendorCode AND mobile_card_vendor.Name= ? AND mobile_card.Status = ? AND mobile_card_vendor.Status = ? AND mobile_card.Value = ?
@@ -271,15 +240,27 @@ func (service *MobileCardService) ExchangeMobileCard(ctx context.Context, userEx
 	// Start transaction
 	tx, err := service.MySql.DbContext.Begin()
 
+	// Consume oldest coins first, wallet is checked under the lock of user's records
+	err = service.WalletService.ConsumeCredits(tx, userExchange.UserId, - mobileCardPrize.Value * len(mobileCardSuccessfully))
+	if err == ErrNotEnoughCoin {
+		_ = tx.Rollback()
+		return mobileCardFailed, gerror.ErrorNotEnoughCoin, nil
+	}
+	if err != nil {
+		_ = tx.Rollback()
+		logger.Error(err.Error())
+		return mobileCardFailed, 0, err
+	}
+
+	var walletIds []string
 	for _, mobileCard := range mobileCardSuccessfully {
 
 		walletId := util.NewUuid()
+		walletIds = append(walletIds, walletId)
 		// 	Add record to table user_wallet
-		createMobileCardWalletStatement := `INSERT INTO user_wallet(Id, UserId, PrizeId, Value) VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?);`
-		_, err = tx.Exec(createMobileCardWalletStatement, walletId, userExchange.UserId, mobileCardPrize.Id, mobileCardPrize.Value)
+		err = service.WalletService.CreateDebit(tx, walletId, userExchange.UserId, mobileCardPrize.Id, mobileCardPrize.Value)
 		if err != nil {
 			_ = tx.Rollback()
-			logger.Error(err.Error())
 			return mobileCardFailed, 0, err
 		}
 
@@ -302,31 +283,34 @@ func (service *MobileCardService) ExchangeMobileCard(ctx context.Context, userEx
 		}
 	}
 
+	event := dto.CardRedeemedEvent{
+		UserId:			userExchange.UserId,
+		VendorName:		userExchange.VendorName,
+		Value:			userExchange.Value,
+		Quantity:		len(mobileCardSuccessfully),
+	}
+	for _, mobileCard := range mobileCardSuccessfully {
+		event.MobileCardIds = append(event.MobileCardIds, mobileCard.Id)
+	}
+	err = service.OutboxService.Add(tx, userExchange.UserId, []string{constant.CacheFamilyBoughtMobileCard}, walletIds, constant.EventCardRedeemed, event)
+	if err != nil {
+		_ = tx.Rollback()
+		return mobileCardFailed, 0, err
+	}
+
 	_ = tx.Commit()
 
 	/*
 		Update Redis
 	 */
 	// Update Transaction
-	err = service.RedisService.UpdateTransactionRedis(userExchange.UserId)
-	if err != nil {
-		logger.Error(err.Error())
-		return mobileCardFailed, 0, err
-	}
+	service.RedisService.RefreshTransactionRedis(userExchange.UserId, walletIds...)
 
 	// Update Bought Mobile Card
-	err = service.RedisService.UpdateBoughtMobileCardRedis(userExchange.UserId)
-	if err != nil {
-		logger.Error(err.Error())
-		return mobileCardFailed, 0, err
-	}
+	service.RedisService.RefreshBoughtMobileCardRedis(userExchange.UserId, walletIds...)
 
 	// Update user wallet
-	err = service.RedisService.UpdateUserWalletRedis(userExchange.UserId)
-	if err != nil {
-		logger.Error(err.Error())
-		return mobileCardFailed, 0, err
-	}
+	service.RedisService.RefreshUserWalletRedis(userExchange.UserId)
 
 
 	return mobileCardSuccessfully, 0, nil
@@ -430,6 +414,92 @@ func (service *MobileCardService) CreateMobileCard(ctx context.Context, mobileCa
 	return nil
 }
 
+/*
+	Import cards of a vendor stock in one transaction
+	+ rows with an unknown vendor, a non-numeric serial or code, or no value are rejected, nothing is imported then
+	+ cards already in stock (same vendor and serial) are skipped
+ */
+func (service *MobileCardService) ImportMobileCards(ctx context.Context, mobileCards []dto.MobileCard) (dto.MobileCardImport, error) {
+	result := dto.MobileCardImport{
+		Total: len(mobileCards),
+	}
+
+	vendorResult, err := service.MySql.DbContext.Query(`SELECT VendorCode FROM mobile_card_vendor;`)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return result, err
+	}
+	vendors := make(map[string]bool)
+	for vendorResult.Next(){
+		var vendorCode string
+		err = vendorResult.Scan(&vendorCode)
+		if err != nil {
+			_ = vendorResult.Close()
+			logger.Error(err.Error())
+			return result, err
+		}
+		vendors[vendorCode] = true
+	}
+	_ = vendorResult.Close()
+
+	for i := range mobileCards {
+		row := i + 2	// After the header
+		mobileCard := &mobileCards[i]
+		if !vendors[mobileCard.VendorCode] {
+			result.Errors = append(result.Errors, fmt.Sprintf("Row %d: vendor %s not found", row, mobileCard.VendorCode))
+			continue
+		}
+		if mobileCard.Value <= 0 {
+			result.Errors = append(result.Errors, fmt.Sprintf("Row %d: invalid value", row))
+			continue
+		}
+		mobileCard.Serial, err = util.EncodeMobileCard(mobileCard.Serial)
+		if err == nil {
+			mobileCard.Code, err = util.EncodeMobileCard(mobileCard.Code)
+		}
+		if err != nil || mobileCard.Serial == "" || mobileCard.Code == "" {
+			result.Errors = append(result.Errors, fmt.Sprintf("Row %d: serial and code must be numeric", row))
+		}
+	}
+	if len(result.Errors) > 0 {
+		return result, nil
+	}
+
+	tx, err := service.MySql.DbContext.Begin()
+	if err != nil {
+		logger.Error(err.Error())
+		return result, err
+	}
+
+	existedQuery := `SELECT COUNT(Id) FROM mobile_card WHERE VendorCode = ? AND Serial = ?;`
+	createMobileCardStatement := `INSERT INTO mobile_card(Id, VendorCode, Serial, Code, Value, Status) VALUES (uuid_to_bin(?), ?, ?, ?, ?, ?);`
+	for _, mobileCard := range mobileCards {
+		var existed int
+		err = tx.QueryRow(existedQuery, mobileCard.VendorCode, mobileCard.Serial).Scan(&existed)
+		if err == nil && existed > 0 {
+			result.Duplicated++
+			continue
+		}
+		if err == nil {
+			_, err = tx.Exec(createMobileCardStatement, util.NewUuid(), mobileCard.VendorCode, mobileCard.Serial, mobileCard.Code, mobileCard.Value, constant.StatusMobileCardReady)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			logger.Error(err.Error())
+			return result, err
+		}
+		result.Imported++
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error(err.Error())
+		return result, err
+	}
+
+	return result, nil
+}
+
 /*
 	Update Mobile Card
 */
@@ -443,7 +513,7 @@ func (service *MobileCardService) UpdateMobileCard(ctx context.Context, mobileCa
 	updateMobileCardStatement, err := service.MySql.DbContext.Prepare(`UPDATE mobile_card 
 																			SET VendorCode = ?, Serial = ?,
 																				Code = ?, Value = ?, Status = ?
-																			WHERE uuid_from_bin(Id) = ?;`)
+																			WHERE Id = uuid_to_bin(?);`)
 	if err != nil {
 		return err
 	}
@@ -466,7 +536,7 @@ func (service *MobileCardService) DeleteMobileCard(ctx context.Context, mobileCa
 	defer cancel()
 
 	deleteMobileCardStatement, err := service.MySql.DbContext.Prepare(`DELETE FROM mobile_card 
-																		WHERE uuid_from_bin(Id) = ?;`)
+																		WHERE Id = uuid_to_bin(?);`)
 	if err != nil {
 		return err
 	}
@@ -23,6 +23,8 @@ type IMobileCardVendorService interface {
 	UpdateMobileCardVendor(ctx context.Context, vendor dto.MobileCardVendor) error
 	DeleteMobileCardVendor(ctx context.Context, vendorId string) error
 
+	// For scheduler
+	ListLowStockMobileCard(ctx context.Context, threshold int) ([]dto.MobileCardVendor, error)
 }
 
 type MobileCardVendorService struct {
@@ -75,6 +77,40 @@ func (service *MobileCardVendorService) GetListQuantityActiveMobileCard(ctx cont
 	return listMobileCard, nil
 }
 
+/*
+	Get values of active vendors with less than threshold ready cards (out of stock included)
+ */
+func (service *MobileCardVendorService) ListLowStockMobileCard(ctx context.Context, threshold int) ([]dto.MobileCardVendor, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	var listMobileCard []dto.MobileCardVendor
+	lowStockQuery := `SELECT mobile_card_vendor.Name, mobile_card_vendor.VendorCode, mobile_card.Value, SUM(mobile_card.Status = ?) AS Quantity
+						FROM mobile_card, mobile_card_vendor
+						WHERE mobile_card.VendorCode = mobile_card_vendor.VendorCode AND mobile_card_vendor.Status = ?
+						GROUP BY mobile_card_vendor.Name, mobile_card_vendor.VendorCode, mobile_card.Value
+						HAVING Quantity < ?;`
+	lowStockResult, err := service.MySql.DbContext.Query(lowStockQuery, constant.StatusMobileCardReady, constant.StatusMobileCardVendorActive, threshold)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return listMobileCard, err
+	}
+	defer lowStockResult.Close()
+
+	for lowStockResult.Next(){
+		var mobileCard dto.MobileCardVendor
+		err = lowStockResult.Scan(&mobileCard.Name, &mobileCard.VendorCode, &mobileCard.Value, &mobileCard.Quantity)
+		if err != nil {
+			logger.Error(err.Error())
+			return listMobileCard, err
+		}
+		listMobileCard = append(listMobileCard, mobileCard)
+	}
+
+	return listMobileCard, nil
+}
+
 /*
 	Get list vendor
 */
@@ -87,7 +123,7 @@ func (service *MobileCardVendorService) GetListAllVendor(ctx context.Context) ([
 	var listVendor []dto.MobileCardVendor
 	listVendor, err := service.GetListVendorRedis(ctx)
 	if err == redis.Nil {
-		err = service.RedisService.UpdateAllVendorRedis()
+		err = service.Cache.Rebuild(constant.RedisPrefixKeyAllVendor, service.RedisService.UpdateAllVendorRedis)
 		if err == nil {
 			listVendor, err = service.GetListVendorRedis(ctx)
 		}
@@ -209,11 +245,7 @@ func (service *MobileCardVendorService) CreateMobileCardVendor(ctx context.Conte
 		return errors.New("No row affected")
 	}
 
-	err = service.RedisService.UpdateAllVendorRedis()
-	if err != nil {
-		logger.Error(err.Error())
-		return err
-	}
+	service.RedisService.RefreshAllVendorRedis()
 
 	return nil
 }
@@ -231,7 +263,7 @@ func (service *MobileCardVendorService) UpdateMobileCardVendor(ctx context.Conte
 														SET Name = ?, 
 															VendorCode = ?,
 															Status = ?
-														WHERE uuid_from_bin(Id) = ?;`)
+														WHERE Id = uuid_to_bin(?);`)
 	if err != nil {
 		return err
 	}
@@ -242,11 +274,7 @@ func (service *MobileCardVendorService) UpdateMobileCardVendor(ctx context.Conte
 	}
 	defer updateMobileCardStatement.Close()
 
-	err = service.RedisService.UpdateAllVendorRedis()
-	if err != nil {
-		logger.Error(err.Error())
-		return err
-	}
+	service.RedisService.RefreshAllVendorRedis()
 
 	return nil
 }
@@ -260,7 +288,7 @@ func (service *MobileCardVendorService) DeleteMobileCardVendor(ctx context.Conte
 	defer cancel()
 
 	deleteVendorStatement, err := service.MySql.DbContext.Prepare(`DELETE FROM mobile_card_vendor
-																		WHERE uuid_from_bin(Id) = ?;`)
+																		WHERE Id = uuid_to_bin(?);`)
 	if err != nil {
 		return err
 	}
@@ -279,11 +307,7 @@ func (service *MobileCardVendorService) DeleteMobileCardVendor(ctx context.Conte
 		return errors.New("No row deleted")
 	}
 
-	err = service.RedisService.UpdateAllVendorRedis()
-	if err != nil {
-		logger.Error(err.Error())
-		return err
-	}
+	service.RedisService.RefreshAllVendorRedis()
 
 	return nil
 }
\ No newline at end of file
@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"g-tech.com/constant"
+	"g-tech.com/dto"
+	"g-tech.com/infrastructure/broker"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/repository"
+	"g-tech.com/infrastructure/util"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+/*
+	Routing key and version of each domain event
+ */
+var eventRoutingKeys = map[string]string{
+	constant.EventWalletCredited:		constant.RbRouteWalletCredited,
+	constant.EventCardRedeemed:			constant.RbRouteCardRedeemed,
+	constant.EventInvitationCreated:	constant.RbRouteInvitationCreated,
+}
+
+var eventVersions = map[string]int{
+	constant.EventWalletCredited:		constant.EventWalletCreditedVersion,
+	constant.EventCardRedeemed:			constant.EventCardRedeemedVersion,
+	constant.EventInvitationCreated:	constant.EventInvitationCreatedVersion,
+}
+
+type IOutboxService interface {
+	// For other services (inside their transaction)
+	Add(tx *sql.Tx, userId string, cacheRefresh []string, walletIds []string, eventType string, payload interface{}) error
+
+	// For scheduler
+	Relay(ctx context.Context, rbClient *broker.Client) (int, error)
+	PurgeProcessed(ctx context.Context) (int, error)
+}
+
+type OutboxService struct {
+	MySql 			repository.MySqlRepository
+	RedisService	RedisService
+	Config			OutboxConfig
+	Timeout    		time.Duration
+}
+
+/*
+	Outbox configuration
+	+ BatchSize: messages relayed per transaction
+	+ MaxAttempts: a message failing to be published is retried with backoff, then kept as failed
+	+ RetentionDays: processed messages are purged after some days
+ */
+type OutboxConfig struct {
+	BatchSize		int
+	MaxAttempts		int
+	RetentionDays	int
+}
+
+func NewOutboxService(dbContext *sql.DB, redisService RedisService, timeout time.Duration) IOutboxService {
+	service := OutboxService{}
+	service.MySql.SetDbContext(dbContext)
+	service.RedisService = redisService
+	service.Config = loadOutboxConfig()
+	service.Timeout = timeout
+	return &service
+}
+
+func loadOutboxConfig() OutboxConfig {
+	viper.SetDefault("Outbox.BatchSize", 100)
+	viper.SetDefault("Outbox.MaxAttempts", 10)
+	viper.SetDefault("Outbox.RetentionDays", 7)
+
+	return OutboxConfig{
+		BatchSize:		viper.GetInt("Outbox.BatchSize"),
+		MaxAttempts:	viper.GetInt("Outbox.MaxAttempts"),
+		RetentionDays:	viper.GetInt("Outbox.RetentionDays"),
+	}
+}
+
+/*
+	Add a message to the outbox, committed together with the transaction
+	+ cacheRefresh: cache families of user to refresh
+	+ walletIds: records of user_wallet written by the transaction, appended to the cached histories
+	+ eventType: domain event to publish (empty: nothing is published)
+ */
+func (service *OutboxService) Add(tx *sql.Tx, userId string, cacheRefresh []string, walletIds []string, eventType string, payload interface{}) error {
+	var body []byte
+	if eventType != "" {
+		var err error
+		body, err = json.Marshal(payload)
+		if err != nil {
+			logger.Error(err.Error())
+			return err
+		}
+	}
+
+	addMessageStatement := `INSERT INTO outbox_message(Id, EventType, RoutingKey, Version, UserId, CacheRefresh, WalletIds, Payload)
+							VALUES (uuid_to_bin(?), ?, ?, ?, uuid_to_bin(NULLIF(?, '')), ?, ?, ?);`
+	_, err := tx.Exec(addMessageStatement, util.NewUuid(), eventType, eventRoutingKeys[eventType], eventVersions[eventType], userId, strings.Join(cacheRefresh, ","), strings.Join(walletIds, ","), string(body))
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+/*
+	Relay pending messages until there is none left
+	Messages are locked while relayed, relays of several instances do not overlap
+ */
+func (service *OutboxService) Relay(ctx context.Context, rbClient *broker.Client) (int, error) {
+	// Broker is reconnecting, messages stay pending
+	if !rbClient.IsConnected() {
+		return 0, nil
+	}
+
+	relayed := 0
+	for {
+		count, err := service.relayBatch(rbClient)
+		relayed += count
+		if err != nil || count < service.Config.BatchSize {
+			return relayed, err
+		}
+	}
+}
+
+func (service *OutboxService) relayBatch(rbClient *broker.Client) (int, error) {
+	tx, err := service.MySql.DbContext.Begin()
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+
+	messageQuery := `SELECT uuid_from_bin(Id), EventType, RoutingKey, Version, IFNULL(uuid_from_bin(UserId), ''), CacheRefresh, IFNULL(WalletIds, ''), IFNULL(Payload, ''), Attempts, CreatedAt
+					FROM outbox_message
+					WHERE Status = ? AND NextAttemptAt <= NOW()
+					ORDER BY CreatedAt ASC
+					LIMIT ?
+					FOR UPDATE SKIP LOCKED;`
+	messageResult, err := tx.Query(messageQuery, constant.StatusOutboxPending, service.Config.BatchSize)
+	if err != nil {
+		_ = tx.Rollback()
+		service.MySql.HandleError(err)
+		return 0, err
+	}
+
+	var messages []dto.OutboxMessage
+	for messageResult.Next(){
+		var message dto.OutboxMessage
+		var cacheRefresh, walletIds string
+		err = messageResult.Scan(&message.Id, &message.EventType, &message.RoutingKey, &message.Version, &message.UserId, &cacheRefresh, &walletIds, &message.Payload,
+			&message.Attempts, &message.CreatedAt)
+		if err != nil {
+			_ = messageResult.Close()
+			_ = tx.Rollback()
+			logger.Error(err.Error())
+			return 0, err
+		}
+		if cacheRefresh != "" {
+			message.CacheRefresh = strings.Split(cacheRefresh, ",")
+		}
+		if walletIds != "" {
+			message.WalletIds = strings.Split(walletIds, ",")
+		}
+		messages = append(messages, message)
+	}
+	_ = messageResult.Close()
+
+	processedStatement := `UPDATE outbox_message SET Status = ?, Attempts = Attempts + 1, ProcessedAt = NOW() WHERE Id = uuid_to_bin(?);`
+	retryStatement := `UPDATE outbox_message SET Status = ?, Attempts = Attempts + 1, LastError = LEFT(?, 512), NextAttemptAt = NOW() + INTERVAL ? SECOND WHERE Id = uuid_to_bin(?);`
+	for _, message := range messages {
+		// Cache is refreshed once, publishing is retried
+		if message.Attempts == 0 {
+			service.refreshCache(message)
+		}
+
+		err = service.publish(rbClient, message)
+		if err == nil {
+			_, err = tx.Exec(processedStatement, constant.StatusOutboxProcessed, message.Id)
+		} else {
+			logger.Error("Outbox message %s not published: %s", message.Id, err.Error())
+			status := constant.StatusOutboxPending
+			if message.Attempts + 1 >= service.Config.MaxAttempts {
+				status = constant.StatusOutboxFailed
+			}
+			_, err = tx.Exec(retryStatement, status, err.Error(), retryDelaySeconds(message.Attempts + 1), message.Id)
+		}
+		if err != nil {
+			_ = tx.Rollback()
+			logger.Error(err.Error())
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+
+	return len(messages), nil
+}
+
+func (service *OutboxService) refreshCache(message dto.OutboxMessage) {
+	for _, family := range message.CacheRefresh {
+		switch family {
+		case constant.CacheFamilyTransaction:
+			service.RedisService.RefreshTransactionRedis(message.UserId, message.WalletIds...)
+		case constant.CacheFamilyBoughtMobileCard:
+			service.RedisService.RefreshBoughtMobileCardRedis(message.UserId, message.WalletIds...)
+		case constant.CacheFamilyUserWallet:
+			service.RedisService.RefreshUserWalletRedis(message.UserId)
+		}
+	}
+}
+
+/*
+	Publish event of message, the message Id is the event Id
+	Returns once RabbitMQ has confirmed the event, an unconfirmed event is an error and retried
+	An event no queue is bound for (no consumer deployed yet) is dropped by RabbitMQ, it is not retried
+ */
+func (service *OutboxService) publish(rbClient *broker.Client, message dto.OutboxMessage) error {
+	if message.EventType == "" {
+		return nil
+	}
+
+	event := dto.Task{
+		MessageType:	message.EventType,
+		Version:		message.Version,
+		EventId:		message.Id,
+		OccurredAt:		&message.CreatedAt,
+		Data:			json.RawMessage(message.Payload),
+	}
+	err := rbClient.Publish(message.RoutingKey, event)
+	if err == broker.ErrUnroutable {
+		logger.Warn("Outbox message %s not routed: no queue is bound for %s", message.Id, message.RoutingKey)
+		return nil
+	}
+	return err
+}
+
+/*
+	Exponential backoff, 5 minutes at most
+ */
+func retryDelaySeconds(attempts int) int {
+	delay := 1 << uint(attempts)
+	if delay > 300 || delay <= 0 {
+		return 300
+	}
+	return delay
+}
+
+/*
+	Delete processed messages older than RetentionDays
+ */
+func (service *OutboxService) PurgeProcessed(ctx context.Context) (int, error) {
+	purgeStatement := `DELETE FROM outbox_message WHERE Status = ? AND ProcessedAt < NOW() - INTERVAL ? DAY LIMIT ?;`
+
+	purged := 0
+	for {
+		purgeResult, err := service.MySql.DbContext.Exec(purgeStatement, constant.StatusOutboxProcessed, service.Config.RetentionDays, service.Config.BatchSize)
+		if err != nil {
+			logger.Error(err.Error())
+			return purged, err
+		}
+
+		rowsAffected, err := purgeResult.RowsAffected()
+		if err != nil {
+			return purged, err
+		}
+		purged += int(rowsAffected)
+		if int(rowsAffected) < service.Config.BatchSize {
+			return purged, nil
+		}
+	}
+}
@@ -65,11 +65,7 @@ func (service *PrizeService) CreatePrize(ctx context.Context, prize dto.Prize) e
 	}
 
 	//	Update Redis
-	err = service.RedisService.UpdateAllPrizeRedis()
-	if err != nil {
-		logger.Error(err.Error())
-		return err
-	}
+	service.RedisService.RefreshAllPrizeRedis()
 
 	return nil
 }
@@ -176,7 +172,7 @@ func (service *PrizeService) GetAllPrize(ctx context.Context, pageSize int, page
 	var listPrize []dto.Prize
 	listPrize, err := service.GetAllPrizeRedis(ctx, pageSize, pageIndex)
 	if err == redis.Nil {
-		err = service.RedisService.UpdateAllPrizeRedis()
+		err = service.Cache.Rebuild(constant.RedisPrefixKeyAllPrize, service.RedisService.UpdateAllPrizeRedis)
 		if err == nil {
 			listPrize, err = service.GetAllPrizeRedis(ctx, pageSize, pageIndex)
 		}
@@ -202,7 +198,7 @@ func (service *PrizeService) UpdatePrize(ctx context.Context, prize dto.Prize) e
 
 	updatePrizeStatement, err := service.MySql.DbContext.Prepare(`UPDATE user_prize 
 																		SET Value = ?, Description = ?
-																		WHERE uuid_from_bin(Id) = ?;`)
+																		WHERE Id = uuid_to_bin(?);`)
 	if err != nil {
 		return err
 	}
@@ -214,11 +210,7 @@ func (service *PrizeService) UpdatePrize(ctx context.Context, prize dto.Prize) e
 	defer updatePrizeStatement.Close()
 
 	//	Update Redis
-	err = service.RedisService.UpdateAllPrizeRedis()
-	if err != nil {
-		logger.Error(err.Error())
-		return err
-	}
+	service.RedisService.RefreshAllPrizeRedis()
 
 	return nil
 }
@@ -232,7 +224,7 @@ func (service *PrizeService) DeletePrize(ctx context.Context, prizeId string) er
 	defer cancel()
 
 	deletePrizeStatement, err := service.MySql.DbContext.Prepare(`DELETE FROM user_prize 
-																		WHERE uuid_from_bin(Id) = ?;`)
+																		WHERE Id = uuid_to_bin(?);`)
 	if err != nil {
 		return err
 	}
@@ -244,11 +236,7 @@ func (service *PrizeService) DeletePrize(ctx context.Context, prizeId string) er
 	defer deletePrizeStatement.Close()
 
 	//	Update Redis
-	err = service.RedisService.UpdateAllPrizeRedis()
-	if err != nil {
-		logger.Error(err.Error())
-		return err
-	}
+	service.RedisService.RefreshAllPrizeRedis()
 
 	return nil
 }
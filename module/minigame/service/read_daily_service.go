@@ -22,14 +22,16 @@ type ReadDailyService struct {
 	MySql 			repository.MySqlRepository
 	RedisService 	RedisService
 	Cache 			cache.CacheManager
+	WalletService	IWalletService
 	Timeout    		time.Duration
 }
 
-func NewReadDailyService (dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, timeout time.Duration) IReadDailyService {
+func NewReadDailyService (dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, walletService IWalletService, timeout time.Duration) IReadDailyService {
 	service := ReadDailyService{}
 	service.MySql.SetDbContext(dbContext)
 	service.Cache = cache
 	service.RedisService = redisService
+	service.WalletService = walletService
 	service.Timeout = timeout
 	return &service
 }
@@ -38,7 +40,7 @@ func (service *ReadDailyService) CreateNewReadDaily (ctx context.Context, user d
 	/*
 		Check user has received coin today or not?
 	*/
-	checkReceivedQuery := `SELECT Id FROM game_read_daily WHERE uuid_from_bin(UserId) = ? AND DATE(LastUpdatedAt) = CURRENT_DATE;`
+	checkReceivedQuery := `SELECT Id FROM game_read_daily WHERE UserId = uuid_to_bin(?) AND LastUpdatedAt >= CURRENT_DATE AND LastUpdatedAt < CURRENT_DATE + INTERVAL 1 DAY;`
 	checkReceivedResult, err := service.MySql.DbContext.Query(checkReceivedQuery, user.UserId)
 	if err != nil {
 		service.MySql.HandleError(err)
@@ -78,9 +80,7 @@ func (service *ReadDailyService) CreateNewReadDaily (ctx context.Context, user d
 	tx, err := service.MySql.DbContext.Begin()
 
 	walletId := util.NewUuid()
-	createReadDailyStatement := `INSERT INTO user_wallet(Id, UserId, PrizeId, Value) VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?);`
-
-	_, err = tx.Exec(createReadDailyStatement, walletId, user.UserId, prizeId, user.Value)
+	err = service.WalletService.CreateCredit(tx, walletId, user.UserId, prizeId, user.Value)
 	if err != nil {
 		fmt.Println(err.Error())
 		_ = tx.Rollback()
@@ -102,18 +102,10 @@ func (service *ReadDailyService) CreateNewReadDaily (ctx context.Context, user d
 		Update Redis
 	*/
 	// Update transaction
-	err = service.RedisService.UpdateTransactionRedis(user.UserId)
-	if err != nil {
-		logger.Error(err.Error())
-		return 0, err
-	}
+	service.RedisService.RefreshTransactionRedis(user.UserId, walletId)
 
 	// Update user wallet
-	err = service.RedisService.UpdateUserWalletRedis(user.UserId)
-	if err != nil {
-		logger.Error(err.Error())
-		return 0, err
-	}
+	service.RedisService.RefreshUserWalletRedis(user.UserId)
 
 	return 0, nil
 }
@@ -123,7 +115,7 @@ func (service *ReadDailyService) CreateNewReadDailyV2(ctx context.Context, user
 	/*
 		Check user has received coin today or not?
 	*/
-	checkReceivedQuery := `SELECT Id FROM game_read_daily WHERE uuid_from_bin(UserId) = ? AND DATE(LastUpdatedAt) = CURRENT_DATE;`
+	checkReceivedQuery := `SELECT Id FROM game_read_daily WHERE UserId = uuid_to_bin(?) AND LastUpdatedAt >= CURRENT_DATE AND LastUpdatedAt < CURRENT_DATE + INTERVAL 1 DAY;`
 	checkReceivedResult, err := service.MySql.DbContext.Query(checkReceivedQuery, user.UserId)
 	if err != nil {
 		service.MySql.HandleError(err)
@@ -163,9 +155,7 @@ func (service *ReadDailyService) CreateNewReadDailyV2(ctx context.Context, user
 	tx, err := service.MySql.DbContext.Begin()
 
 	walletId := util.NewUuid()
-	createReadDailyStatement := `INSERT INTO user_wallet(Id, UserId, PrizeId, Value) VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?);`
-
-	_, err = tx.Exec(createReadDailyStatement, walletId, user.UserId, prizeId, user.Value)
+	err = service.WalletService.CreateCredit(tx, walletId, user.UserId, prizeId, user.Value)
 	if err != nil {
 		fmt.Println(err.Error())
 		_ = tx.Rollback()
@@ -187,18 +177,10 @@ func (service *ReadDailyService) CreateNewReadDailyV2(ctx context.Context, user
 		Update Redis
 	*/
 	// Update transaction
-	err = service.RedisService.UpdateTransactionRedis(user.UserId)
-	if err != nil {
-		logger.Error(err.Error())
-		return 0, err
-	}
+	service.RedisService.RefreshTransactionRedis(user.UserId, walletId)
 
 	// Update user wallet
-	err = service.RedisService.UpdateUserWalletRedis(user.UserId)
-	if err != nil {
-		logger.Error(err.Error())
-		return 0, err
-	}
+	service.RedisService.RefreshUserWalletRedis(user.UserId)
 
 	return 0, nil
 }
@@ -2,61 +2,259 @@ package service
 
 import (
 	"database/sql"
-	"fmt"
+	"encoding/json"
 	"g-tech.com/constant"
 	"g-tech.com/dto"
 	"g-tech.com/infrastructure/cache"
 	"g-tech.com/infrastructure/logger"
 	"g-tech.com/infrastructure/repository"
+	"g-tech.com/infrastructure/util"
+	"github.com/go-redis/redis"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"strings"
 	"time"
 )
 
+/*
+	Returned when a page is not fully cached, it has to be read from MySql
+ */
+var ErrBeyondCachedHistory = errors.New("Page is beyond cached history")
+
 type RedisService struct {
 	MySql 			repository.MySqlRepository
 	Cache 			cache.CacheManager
+	HistoryLength	int64		// Maximum number of items cached per history (transactions, bought mobile cards)
 	Timeout    		time.Duration
 }
 
 func NewRedisService (dbContext *sql.DB, cache cache.CacheManager, timeout time.Duration) RedisService {
+	viper.SetDefault("Cache.HistoryLength", 500)
+
 	service := RedisService{}
 	service.Cache = cache
 	service.MySql.SetDbContext(dbContext)
+	service.HistoryLength = viper.GetInt64("Cache.HistoryLength")
 	service.Timeout = timeout
+	registerCacheFamilies(cache)
 	return service
 }
 
 /*
-	Update transaction
+	Keys of a family are invalidated together by a cache reset
+	Shared by every service using families, registering twice is harmless
+ */
+func registerCacheFamilies(cache cache.CacheManager) {
+	cache.RegisterFamily(constant.CacheFamilyTransaction, constant.RedisPrefixKeyAllTransaction, constant.RedisPrefixKeyReceivedTransaction, constant.RedisPrefixKeyUsedTransaction)
+	cache.RegisterFamily(constant.CacheFamilyBoughtMobileCard, constant.RedisPrefixKeyBoughtMobileCards)
+	cache.RegisterFamily(constant.CacheFamilyUserWallet, constant.RedisPrefixKeyUserWallet)
+	cache.RegisterFamily(constant.CacheFamilyPrize, constant.RedisPrefixKeyAllPrize)
+	cache.RegisterFamily(constant.CacheFamilyVendor, constant.RedisPrefixKeyAllVendor)
+}
+
+/*
+	Refresh caches after a commit, a cache failing to refresh is invalidated (once Redis is back if it is unavailable)
+	Commits never fail because of cache
+	+ walletIds: records of user_wallet written by the commit, appended to the cached histories
+ */
+func (service *RedisService) RefreshTransactionRedis(userId string, walletIds ...string) {
+	service.refresh(func() error {
+		return service.AppendTransactionRedis(userId, walletIds)
+	}, constant.RedisPrefixKeyAllTransaction + userId, constant.RedisPrefixKeyReceivedTransaction + userId, constant.RedisPrefixKeyUsedTransaction + userId)
+}
+
+func (service *RedisService) RefreshBoughtMobileCardRedis(userId string, walletIds ...string) {
+	service.refresh(func() error {
+		return service.AppendBoughtMobileCardRedis(userId, walletIds)
+	}, constant.RedisPrefixKeyBoughtMobileCards + userId)
+}
+
+func (service *RedisService) RefreshUserWalletRedis(userId string) {
+	service.refresh(func() error {
+		return service.UpdateUserWalletRedis(userId)
+	}, constant.RedisPrefixKeyUserWallet + userId)
+}
+
+func (service *RedisService) RefreshAllPrizeRedis() {
+	service.refresh(service.UpdateAllPrizeRedis, constant.RedisPrefixKeyAllPrize)
+}
+
+func (service *RedisService) RefreshAllVendorRedis() {
+	service.refresh(service.UpdateAllVendorRedis, constant.RedisPrefixKeyAllVendor)
+}
+
+func (service *RedisService) refresh(update func() error, keys ...string) {
+	if service.Cache.IsAvailable() {
+		err := update()
+		if err == nil {
+			return
+		}
+		logger.Error("Error update redis", err.Error())
+	}
+	service.Cache.Invalidate(keys...)
+}
+
+/*
+	Transactions of user with their counterparty, newest first
+ */
+const transactionHistoryQuery = `SELECT uuid_from_bin(user_wallet.Id), uuid_from_bin(user_wallet.UserId) AS UserId, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt, 
+									IFNULL(uuid_from_bin(COALESCE(sent.RecipientId, received.SenderId)), "") AS Counterparty 
+								FROM user_wallet 
+								INNER JOIN user_prize ON user_wallet.PrizeId = user_prize.Id 
+								LEFT JOIN user_wallet_transfer AS sent ON sent.SenderWalletId = user_wallet.Id 
+								LEFT JOIN user_wallet_transfer AS received ON received.RecipientWalletId = user_wallet.Id 
+								WHERE user_wallet.UserId = uuid_to_bin(?) `
+
+/*
+	Rebuild transactions of user, on a cache miss
+	Sorted sets of user (all, received, used) are written from the latest HistoryLength transactions, even if there is none
+*/
+func (service *RedisService) RebuildTransactionRedis(userId string) error {
+	listTransactionQuery := transactionHistoryQuery + `ORDER BY user_wallet.LastUpdatedAt DESC LIMIT ?;`
+	allTransaction, receivedTransaction, usedTransaction, err := service.queryTransactions(listTransactionQuery, userId, service.HistoryLength)
+	if err != nil {
+		return err
+	}
+
+	// Sets of a user expire together
+	expireIn := service.Cache.ExpiryOf(constant.CacheFamilyTransaction)
+	for key, members := range map[string][]redis.Z{
+		constant.RedisPrefixKeyAllTransaction + userId: 		allTransaction,
+		constant.RedisPrefixKeyReceivedTransaction + userId: 	receivedTransaction,
+		constant.RedisPrefixKeyUsedTransaction + userId: 		usedTransaction,
+	} {
+		err = service.Cache.ZReplace(key, expireIn, members...)
+		if err != nil {
+			logger.Error("Error update redis", err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Append transactions (walletIds) to the sorted sets of user, the oldest ones beyond HistoryLength are dropped
+	Sets not cached are left to be rebuilt on next read
 */
-func (service *RedisService) UpdateTransactionRedis(userId string) error{
+func (service *RedisService) AppendTransactionRedis(userId string, walletIds []string) error {
+	if len(walletIds) == 0 {
+		return nil
+	}
 
-	listTransactionQuery 	:= `SELECT uuid_from_bin(user_wallet.UserId) AS UserId, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt 
-								FROM user_wallet, user_prize 
-								WHERE user_wallet.PrizeId = user_prize.Id AND uuid_from_bin(user_wallet.UserId) = ? 
-								ORDER BY user_wallet.LastUpdatedAt DESC;`
-	listTransactionResult, err := service.MySql.DbContext.Query(listTransactionQuery, userId)
+	listTransactionQuery := transactionHistoryQuery + `AND user_wallet.Id IN (uuid_to_bin(?)` + strings.Repeat(`, uuid_to_bin(?)`, len(walletIds) - 1) + `);`
+	args := []interface{}{userId}
+	for _, walletId := range walletIds {
+		args = append(args, walletId)
+	}
+	allTransaction, receivedTransaction, usedTransaction, err := service.queryTransactions(listTransactionQuery, args...)
 	if err != nil {
-		service.MySql.HandleError(err)
 		return err
 	}
+
+	for key, members := range map[string][]redis.Z{
+		constant.RedisPrefixKeyAllTransaction + userId: 		allTransaction,
+		constant.RedisPrefixKeyReceivedTransaction + userId: 	receivedTransaction,
+		constant.RedisPrefixKeyUsedTransaction + userId: 		usedTransaction,
+	} {
+		if len(members) == 0 {
+			continue
+		}
+		_, err = service.Cache.ZAddBounded(key, service.HistoryLength, members...)
+		if err != nil {
+			logger.Error("Error update redis", err.Error())
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+	Transactions as members of the sorted sets (all, received, used), scored by date
+ */
+func (service *RedisService) queryTransactions(query string, args ...interface{}) ([]redis.Z, []redis.Z, []redis.Z, error) {
+	listTransactionResult, err := service.MySql.DbContext.Query(query, args...)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return nil, nil, nil, err
+	}
 	defer listTransactionResult.Close()
 
-	var listUserTransaction []dto.User
+	var allTransaction, receivedTransaction, usedTransaction []redis.Z
 
 	for listTransactionResult.Next(){
 		var userTransaction dto.User
-		err = listTransactionResult.Scan(&userTransaction.UserId, &userTransaction.Description, &userTransaction.Value, &userTransaction.LastUpdatedAt)
+		var lastUpdatedAt time.Time
+		err = listTransactionResult.Scan(&userTransaction.Id, &userTransaction.UserId, &userTransaction.Description, &userTransaction.Value, &lastUpdatedAt, &userTransaction.Counterparty)
 		if err != nil {
-			return err
+			return nil, nil, nil, err
 		}
 		// Format CreatedAt
-		dt,_ := time.Parse(time.RFC3339, userTransaction.LastUpdatedAt)
-		userTransaction.LastUpdatedAt = dt.Format(constant.DateTimeLayout)
+		userTransaction.LastUpdatedAt = lastUpdatedAt.Format(constant.DateTimeLayout)
+
+		member := redis.Z{Score: float64(lastUpdatedAt.Unix()), Member: util.ToJSON(userTransaction)}
+		allTransaction = append(allTransaction, member)
+		if userTransaction.Value >= 0 {
+			receivedTransaction = append(receivedTransaction, member)
+		} else {
+			usedTransaction = append(usedTransaction, member)
+		}
+	}
+
+	return allTransaction, receivedTransaction, usedTransaction, nil
+}
+
+/*
+	Get a page of transactions from Redis (option: 0 all, 1 received, -1 used)
+*/
+func (service *RedisService) GetTransactionPageRedis(userId string, option int, offset int, limit int) ([]dto.User, error) {
+	key := constant.RedisPrefixKeyAllTransaction + userId
+	if option == 1 {
+		key = constant.RedisPrefixKeyReceivedTransaction + userId
+	} else if option == -1 {
+		key = constant.RedisPrefixKeyUsedTransaction + userId
+	}
+
+	members, err := service.getHistoryPage(constant.RedisPrefixKeyAllTransaction + userId, key, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	listTransaction := []dto.User{}
+	for _, member := range members {
+		var transaction dto.User
+		err = json.Unmarshal([]byte(member), &transaction)
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
+		listTransaction = append(listTransaction, transaction)
+	}
+
+	return listTransaction, nil
+}
 
-		listUserTransaction = append(listUserTransaction, userTransaction)
+/*
+	Cards bought by user, newest first
+ */
+const boughtMobileCardQuery = `SELECT uuid_from_bin(mobile_card.Id), mobile_card_vendor.Name, mobile_card.VendorCode, mobile_card.Serial, mobile_card.Code, mobile_card.Value, game_mobile_card.CreatedAt 
+								FROM game_mobile_card, mobile_card, mobile_card_vendor 
+								WHERE mobile_card_vendor.VendorCode = mobile_card.VendorCode AND game_mobile_card.MobileCardId = mobile_card.Id 
+									AND game_mobile_card.UserId = uuid_to_bin(?) `
+
+/*
+	Rebuild bought mobile cards of user, on a cache miss
+	Sorted set of user is written from the latest HistoryLength cards bought, even if there is none
+ */
+func (service *RedisService) RebuildBoughtMobileCardRedis(userId string) error {
+	getAllBoughtMobileCardQuery := boughtMobileCardQuery + `ORDER BY game_mobile_card.CreatedAt DESC LIMIT ?;`
+	boughtMobileCards, err := service.queryBoughtMobileCards(getAllBoughtMobileCardQuery, userId, service.HistoryLength)
+	if err != nil {
+		return err
 	}
 
-	err = service.Cache.SetWithError(constant.RedisPrefixKeyAllTransaction + userId, listUserTransaction, 0)
+	err = service.Cache.ZReplace(constant.RedisPrefixKeyBoughtMobileCards + userId, service.Cache.ExpiryOf(constant.CacheFamilyBoughtMobileCard), boughtMobileCards...)
 	if err != nil {
 		logger.Error("Error update redis", err.Error())
 		return err
@@ -66,44 +264,126 @@ func (service *RedisService) UpdateTransactionRedis(userId string) error{
 }
 
 /*
-	Update bought mobile card
+	Append cards bought (by the spending records walletIds) to the sorted set of user, the oldest ones beyond HistoryLength are dropped
+	Set not cached is left to be rebuilt on next read
  */
-func (service *RedisService) UpdateBoughtMobileCardRedis(userId string) error {
-	// 2. Update bought mobile cards
-	var mobileCardSuccessfully 	[]dto.MobileCard
-
-	getAllBoughtMobileCardQuery := `SELECT uuid_from_bin(mobile_card.Id), mobile_card_vendor.Name, mobile_card.VendorCode, mobile_card.Serial, mobile_card.Code, mobile_card.Value, game_mobile_card.CreatedAt 
-									FROM game_mobile_card, mobile_card, mobile_card_vendor 
-									WHERE mobile_card_vendor.VendorCode = mobile_card.VendorCode AND game_mobile_card.MobileCardId = mobile_card.Id AND uuid_from_bin(game_mobile_card.UserId) = ?
-									ORDER BY game_mobile_card.CreatedAt DESC;`
-	getAllBoughtMobileCardResult, err := service.MySql.DbContext.Query(getAllBoughtMobileCardQuery, userId)
+func (service *RedisService) AppendBoughtMobileCardRedis(userId string, walletIds []string) error {
+	if len(walletIds) == 0 {
+		return nil
+	}
+
+	getBoughtMobileCardQuery := boughtMobileCardQuery + `AND game_mobile_card.WalletId IN (uuid_to_bin(?)` + strings.Repeat(`, uuid_to_bin(?)`, len(walletIds) - 1) + `);`
+	args := []interface{}{userId}
+	for _, walletId := range walletIds {
+		args = append(args, walletId)
+	}
+	boughtMobileCards, err := service.queryBoughtMobileCards(getBoughtMobileCardQuery, args...)
+	if err != nil || len(boughtMobileCards) == 0 {
+		return err
+	}
+
+	_, err = service.Cache.ZAddBounded(constant.RedisPrefixKeyBoughtMobileCards + userId, service.HistoryLength, boughtMobileCards...)
 	if err != nil {
-		logger.Error(err.Error())
+		logger.Error("Error update redis", err.Error())
 		return err
 	}
+
+	return nil
+}
+
+/*
+	Cards bought as members of the sorted set, scored by date
+ */
+func (service *RedisService) queryBoughtMobileCards(query string, args ...interface{}) ([]redis.Z, error) {
+	getAllBoughtMobileCardResult, err := service.MySql.DbContext.Query(query, args...)
+	if err != nil {
+		logger.Error(err.Error())
+		return nil, err
+	}
 	defer getAllBoughtMobileCardResult.Close()
 
+	var boughtMobileCards []redis.Z
 	for getAllBoughtMobileCardResult.Next(){
 		var mobileCard dto.MobileCard
-		err = getAllBoughtMobileCardResult.Scan(&mobileCard.Id, &mobileCard.Name, &mobileCard.VendorCode, &mobileCard.Serial, &mobileCard.Code, &mobileCard.Value, &mobileCard.CreatedAt)
+		var createdAt time.Time
+		err = getAllBoughtMobileCardResult.Scan(&mobileCard.Id, &mobileCard.Name, &mobileCard.VendorCode, &mobileCard.Serial, &mobileCard.Code, &mobileCard.Value, &createdAt)
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
 		// Format CreatedAt
-		dt,_ := time.Parse(time.RFC3339, mobileCard.CreatedAt)
-		mobileCard.CreatedAt = dt.Format(constant.DateTimeLayout)
+		mobileCard.CreatedAt = createdAt.Format(constant.DateTimeLayout)
+
+		boughtMobileCards = append(boughtMobileCards, redis.Z{Score: float64(createdAt.Unix()), Member: util.ToJSON(mobileCard)})
+	}
+
+	return boughtMobileCards, nil
+}
+
+/*
+	Get a page of bought mobile cards from Redis (Serial and Code are encoded)
+ */
+func (service *RedisService) GetBoughtMobileCardPageRedis(userId string, offset int, limit int) ([]dto.MobileCard, error) {
+	key := constant.RedisPrefixKeyBoughtMobileCards + userId
+
+	members, err := service.getHistoryPage(key, key, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	mobileCards := []dto.MobileCard{}
+	for _, member := range members {
+		var mobileCard dto.MobileCard
+		err = json.Unmarshal([]byte(member), &mobileCard)
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
+		mobileCards = append(mobileCards, mobileCard)
+	}
 
+	return mobileCards, nil
+}
+
+/*
+	Get a page of a history sorted set, newest first
+	+ redis.Nil: history of user is not cached (existKey or key does not exist)
+	+ ErrBeyondCachedHistory: older items may have been dropped from cache
+ */
+func (service *RedisService) getHistoryPage(existKey string, key string, offset int, limit int) ([]string, error) {
+	for _, k := range []string{existKey, key} {
+		isExisted, err := service.Cache.Exists(k)
 		if err != nil {
 			logger.Error(err.Error())
-			return err
+			return nil, err
 		}
-		mobileCardSuccessfully = append(mobileCardSuccessfully, mobileCard)
+		if !isExisted {
+			return nil, redis.Nil
+		}
+	}
+
+	if limit <= 0 || offset < 0 {
+		return []string{}, nil
 	}
 
-	err = service.Cache.SetWithError(constant.RedisPrefixKeyBoughtMobileCards + userId, mobileCardSuccessfully, 0)
+	members, err := service.Cache.ZRevRange(key, int64(offset), int64(offset + limit - 1))
 	if err != nil {
-		logger.Error("Error update redis", err.Error())
-		return err
+		logger.Error(err.Error())
+		return nil, err
 	}
 
-	return nil
+	if len(members) < limit {
+		count, err := service.Cache.ZCard(existKey)
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
+		if count >= service.HistoryLength {
+			return nil, ErrBeyondCachedHistory
+		}
+	}
+
+	return members, nil
 }
 
 /*
@@ -134,7 +414,7 @@ func (service *RedisService) UpdateAllPrizeRedis() error{
 		listPrize = append(listPrize, prize)
 	}
 
-	err = service.Cache.SetWithError(constant.RedisPrefixKeyAllPrize, listPrize, 0)
+	err = service.Cache.SetWithError(constant.RedisPrefixKeyAllPrize, listPrize, service.Cache.ExpiryOf(constant.CacheFamilyPrize))
 	if err != nil {
 		logger.Error("Error update redis", err.Error())
 		return err
@@ -172,7 +452,7 @@ func (service *RedisService) UpdateAllVendorRedis() error{
 		listVendor = append(listVendor, vendor)
 	}
 
-	err = service.Cache.SetWithError(constant.RedisPrefixKeyAllVendor, listVendor, 0)
+	err = service.Cache.SetWithError(constant.RedisPrefixKeyAllVendor, listVendor, service.Cache.ExpiryOf(constant.CacheFamilyVendor))
 	if err != nil {
 		logger.Error("Error update redis", err.Error())
 		return err
@@ -190,7 +470,7 @@ func (service *RedisService) UpdateUserWalletRedis(userId string) error{
 	// Get inviting status
 	userInvitingQuery := `SELECT uuid_from_bin(Id)
 				FROM game_inviting
-				WHERE uuid_from_bin(InvitedUser) = ?;`
+				WHERE InvitedUser = uuid_to_bin(?);`
 	userInvitingResult, err := service.MySql.DbContext.Query(userInvitingQuery, userId)
 	if err != nil {
 		service.MySql.HandleError(err)
@@ -208,7 +488,7 @@ func (service *RedisService) UpdateUserWalletRedis(userId string) error{
 	// Get Wallet
 	userWalletQuery := `SELECT IFNULL(SUM(user_wallet.Value), 0) AS Wallet  
 				FROM user_wallet 
-				WHERE uuid_from_bin(UserId) = ?;`
+				WHERE UserId = uuid_to_bin(?);`
 	userWalletResult, err := service.MySql.DbContext.Query(userWalletQuery, userId)
 	if err != nil {
 		service.MySql.HandleError(err)
@@ -231,44 +511,10 @@ func (service *RedisService) UpdateUserWalletRedis(userId string) error{
 		IsInvited: isInvited,
 	}
 
-	err = service.Cache.SetWithError(constant.RedisPrefixKeyUserWallet + userId, user, 0)
+	err = service.Cache.SetWithError(constant.RedisPrefixKeyUserWallet + userId, user, service.Cache.ExpiryOf(constant.CacheFamilyUserWallet))
 	if err != nil {
 		logger.Error("Error update redis", err.Error())
 		return err
 	}
 	return nil
 }
-
-/*
-	Update wallet by Id
-*/
-func (service *RedisService) ResetRedis() error{
-
-	usersQuery := `SELECT uuid_from_bin(Id) FROM sso_user`
-	usersResult, err := service.MySql.DbContext.Query(usersQuery)
-	if err != nil {
-		service.MySql.HandleError(err)
-		return err
-	}
-	defer usersResult.Close()
-
-	for usersResult.Next(){
-		var userId string
-		err = usersResult.Scan(&userId)
-		if err != nil {
-			return  err
-		}
-		affectedWallet := service.Cache.DeleteItem(constant.RedisPrefixKeyUserWallet + userId)
-		fmt.Printf("Wallet %s: %d\n", userId, affectedWallet)
-		affectedBoughtCards := service.Cache.DeleteItem(constant.RedisPrefixKeyBoughtMobileCards + userId)
-		fmt.Printf("BoughtCard %s: %d\n", userId, affectedBoughtCards)
-		affectedTransaction := service.Cache.DeleteItem(constant.RedisPrefixKeyAllTransaction + userId)
-		fmt.Printf("Transaction %s: %d\n", userId, affectedTransaction)
-	}
-	affectedMobileCardVendor := service.Cache.DeleteItem(constant.RedisPrefixKeyAllVendor)
-	fmt.Printf("MobileCardVendors: %d\n", affectedMobileCardVendor)
-	affectedPrize := service.Cache.DeleteItem(constant.RedisPrefixKeyAllPrize)
-	fmt.Printf("Prizes: %d\n", affectedPrize)
-
-	return nil
-}
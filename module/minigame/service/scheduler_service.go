@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"g-tech.com/constant"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/repository"
+	"g-tech.com/infrastructure/util"
+	"time"
+)
+
+/*
+	Jobs run by the scheduler binary
+ */
+var scheduledJobs = []string{
+	constant.JobSettleReferralRewards,
+	constant.JobExpireCredits,
+	constant.JobRelayOutbox,
+	constant.JobPurgeOutbox,
+	constant.JobWarmUpCache,
+	constant.JobAlertMobileCardStock,
+	constant.JobPurgeJobRuns,
+}
+
+const jobRunColumns = `uuid_from_bin(Id), JobName, TriggerType, TriggeredBy, Status, Result, Error, Instance, CreatedAt, StartedAt, FinishedAt`
+
+type ISchedulerService interface {
+	// For web
+	TriggerJob(ctx context.Context, jobName string, triggeredBy string) (dto.JobRun, int, error)
+	GetJobRun(ctx context.Context, runId string) (dto.JobRun, int, error)
+	ListJobRuns(ctx context.Context, jobName string, pageSize int, pageIndex int) ([]dto.JobRun, error)
+
+	// For scheduler
+	StartJobRun(run dto.JobRun) (dto.JobRun, error)
+	ClaimQueuedJobRun(instance string) (dto.JobRun, bool, error)
+	FinishJobRun(run dto.JobRun) error
+	PurgeJobRuns(ctx context.Context, retentionDays int) (int, error)
+}
+
+type SchedulerService struct {
+	MySql 			repository.MySqlRepository
+	Timeout    		time.Duration
+}
+
+func NewSchedulerService(dbContext *sql.DB, timeout time.Duration) ISchedulerService {
+	service := SchedulerService{}
+	service.MySql.SetDbContext(dbContext)
+	service.Timeout = timeout
+	return &service
+}
+
+/*
+	Queue a manual run of a job, the scheduler picks it up within seconds
+ */
+func (service *SchedulerService) TriggerJob(ctx context.Context, jobName string, triggeredBy string) (dto.JobRun, int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	run := dto.JobRun{
+		Id:				util.NewUuid(),
+		JobName:		jobName,
+		TriggerType:	constant.JobTriggerManual,
+		TriggeredBy:	triggeredBy,
+		Status:			constant.StatusJobRunQueued,
+	}
+	if !isScheduledJob(jobName) {
+		return run, gerror.ErrorJobNotFound, nil
+	}
+
+	createRunStatement := `INSERT INTO scheduler_job_run(Id, JobName, TriggerType, TriggeredBy, Status) VALUES (uuid_to_bin(?), ?, ?, ?, ?);`
+	_, err := service.MySql.DbContext.Exec(createRunStatement, run.Id, run.JobName, run.TriggerType, run.TriggeredBy, run.Status)
+	if err != nil {
+		logger.Error(err.Error())
+		return run, 0, err
+	}
+
+	return service.GetJobRun(ctx, run.Id)
+}
+
+func (service *SchedulerService) GetJobRun(ctx context.Context, runId string) (dto.JobRun, int, error) {
+	var run dto.JobRun
+
+	runQuery := `SELECT ` + jobRunColumns + ` FROM scheduler_job_run WHERE Id = uuid_to_bin(?);`
+	runResult, err := service.MySql.DbContext.Query(runQuery, runId)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return run, 0, err
+	}
+	defer runResult.Close()
+
+	if !runResult.Next(){
+		return run, gerror.ErrorJobRunNotFound, nil
+	}
+	run, err = scanJobRun(runResult)
+	if err != nil {
+		logger.Error(err.Error())
+		return run, 0, err
+	}
+
+	return run, 0, nil
+}
+
+/*
+	Get run history, of every job when jobName is empty
+ */
+func (service *SchedulerService) ListJobRuns(ctx context.Context, jobName string, pageSize int, pageIndex int) ([]dto.JobRun, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	limit := pageSize
+	offset := (pageIndex - 1) * pageSize
+
+	listRunQuery := `SELECT ` + jobRunColumns + `
+					FROM scheduler_job_run
+					WHERE ? = '' OR JobName = ?
+					ORDER BY CreatedAt DESC
+					LIMIT ? OFFSET ?;`
+	listRunResult, err := service.MySql.DbContext.Query(listRunQuery, jobName, jobName, limit, offset)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return nil, err
+	}
+	defer listRunResult.Close()
+
+	var listRun []dto.JobRun
+	for listRunResult.Next(){
+		run, err := scanJobRun(listRunResult)
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
+		listRun = append(listRun, run)
+	}
+
+	return listRun, nil
+}
+
+/*
+	Record the start of a scheduled run
+ */
+func (service *SchedulerService) StartJobRun(run dto.JobRun) (dto.JobRun, error) {
+	now := time.Now()
+	run.Id = util.NewUuid()
+	run.Status = constant.StatusJobRunRunning
+	run.CreatedAt = now
+	run.StartedAt = &now
+
+	startRunStatement := `INSERT INTO scheduler_job_run(Id, JobName, TriggerType, TriggeredBy, Status, Instance, StartedAt)
+							VALUES (uuid_to_bin(?), ?, ?, ?, ?, ?, NOW());`
+	_, err := service.MySql.DbContext.Exec(startRunStatement, run.Id, run.JobName, run.TriggerType, run.TriggeredBy, run.Status, run.Instance)
+	if err != nil {
+		logger.Error(err.Error())
+		return run, err
+	}
+
+	return run, nil
+}
+
+/*
+	Take the oldest queued manual run, at most one instance gets it
+ */
+func (service *SchedulerService) ClaimQueuedJobRun(instance string) (dto.JobRun, bool, error) {
+	var run dto.JobRun
+
+	tx, err := service.MySql.DbContext.Begin()
+	if err != nil {
+		logger.Error(err.Error())
+		return run, false, err
+	}
+
+	queuedQuery := `SELECT ` + jobRunColumns + `
+					FROM scheduler_job_run
+					WHERE Status = ?
+					ORDER BY CreatedAt ASC
+					LIMIT 1
+					FOR UPDATE SKIP LOCKED;`
+	queuedResult, err := tx.Query(queuedQuery, constant.StatusJobRunQueued)
+	if err != nil {
+		_ = tx.Rollback()
+		service.MySql.HandleError(err)
+		return run, false, err
+	}
+	isQueued := queuedResult.Next()
+	if isQueued {
+		run, err = scanJobRun(queuedResult)
+	}
+	_ = queuedResult.Close()
+	if err != nil || !isQueued {
+		_ = tx.Rollback()
+		return run, false, err
+	}
+
+	now := time.Now()
+	run.Status = constant.StatusJobRunRunning
+	run.Instance = instance
+	run.StartedAt = &now
+	_, err = tx.Exec(`UPDATE scheduler_job_run SET Status = ?, Instance = ?, StartedAt = NOW() WHERE Id = uuid_to_bin(?);`, run.Status, run.Instance, run.Id)
+	if err != nil {
+		_ = tx.Rollback()
+		logger.Error(err.Error())
+		return run, false, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error(err.Error())
+		return run, false, err
+	}
+
+	return run, true, nil
+}
+
+func (service *SchedulerService) FinishJobRun(run dto.JobRun) error {
+	finishRunStatement := `UPDATE scheduler_job_run SET Status = ?, Result = LEFT(?, 512), Error = LEFT(?, 512), FinishedAt = NOW() WHERE Id = uuid_to_bin(?);`
+	_, err := service.MySql.DbContext.Exec(finishRunStatement, run.Status, run.Result, run.Error, run.Id)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+/*
+	Delete runs older than retentionDays
+ */
+func (service *SchedulerService) PurgeJobRuns(ctx context.Context, retentionDays int) (int, error) {
+	purgeResult, err := service.MySql.DbContext.Exec(`DELETE FROM scheduler_job_run WHERE CreatedAt < NOW() - INTERVAL ? DAY AND Status <> ?;`,
+		retentionDays, constant.StatusJobRunQueued)
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+
+	rowsAffected, err := purgeResult.RowsAffected()
+	return int(rowsAffected), err
+}
+
+/*
+	StartedAt and FinishedAt are nil until set
+ */
+func scanJobRun(row repository.MySqlRowScanner) (dto.JobRun, error) {
+	var run dto.JobRun
+	err := row.Scan(&run.Id, &run.JobName, &run.TriggerType, &run.TriggeredBy, &run.Status, &run.Result, &run.Error, &run.Instance,
+		&run.CreatedAt, &run.StartedAt, &run.FinishedAt)
+	return run, err
+}
+
+func isScheduledJob(jobName string) bool {
+	for _, job := range scheduledJobs {
+		if job == jobName {
+			return true
+		}
+	}
+	return false
+}
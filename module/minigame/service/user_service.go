@@ -10,28 +10,33 @@ import (
 	"g-tech.com/infrastructure/cache"
 	"g-tech.com/infrastructure/logger"
 	"g-tech.com/infrastructure/repository"
+	"g-tech.com/infrastructure/util"
 	"github.com/go-redis/redis"
+	"strings"
 	"time"
 )
 
 type IUserService interface {
 	GetWalletByUserId(ctx context.Context, userId string) (dto.User, error)
 	ListTransactions(ctx context.Context, userId string, option int, pageSize int, pageIndex int) ([]dto.User, error)
-	ResetRedis(ctx context.Context) error
+	ListTransactionHistory(ctx context.Context, userId string, filter dto.TransactionFilter) (dto.TransactionPage, error)
+	GetWalletStatement(ctx context.Context, userId string, month time.Time) (dto.WalletStatement, error)
 }
 
 type UserService struct {
 	MySql 			repository.MySqlRepository
 	Cache 			cache.CacheManager
 	RedisService	RedisService
+	WalletService	IWalletService
 	Timeout    		time.Duration
 }
 
-func NewUserService (dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, timeout time.Duration) IUserService {
+func NewUserService (dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, walletService IWalletService, timeout time.Duration) IUserService {
 	service := UserService{}
 	service.MySql.SetDbContext(dbContext)
 	service.Cache = cache
 	service.RedisService = redisService
+	service.WalletService = walletService
 	service.Timeout = timeout
 	return &service
 }
@@ -45,7 +50,7 @@ func (service *UserService) GetWalletByUserIdSQL(ctx context.Context, userId str
 
 	userWalletQuery := `SELECT IFNULL(uuid_from_bin(game_inviting.InvitingUser), "") As InvitingUser, IFNULL(SUM(user_wallet.Value), 0) AS Wallet  
 				FROM game_inviting, user_wallet 
-				WHERE uuid_from_bin(user_wallet.UserId) = ? AND uuid_from_bin(game_inviting.InvitedUser) = ?;`
+				WHERE user_wallet.UserId = uuid_to_bin(?) AND game_inviting.InvitedUser = uuid_to_bin(?);`
 	userWalletResult, err := service.MySql.DbContext.Query(userWalletQuery, userId, userId)
 	if err != nil {
 		service.MySql.HandleError(err)
@@ -62,7 +67,7 @@ func (service *UserService) GetWalletByUserIdSQL(ctx context.Context, userId str
 
 	readDaysQuery := `SELECT dayofweek(LastUpdatedAt) 
 					FROM game_read_daily 
-					WHERE uuid_from_bin(UserId) = ? AND DATE(LastUpdatedAt) >= STR_TO_DATE(?,'%Y-%m-%d');`
+					WHERE UserId = uuid_to_bin(?) AND LastUpdatedAt >= STR_TO_DATE(?,'%Y-%m-%d');`
 	readDaysResult, err := service.MySql.DbContext.Query(readDaysQuery, userId, lastMonday)
 	if err != nil {
 		service.MySql.HandleError(err)
@@ -132,7 +137,7 @@ func (service *UserService) GetWalletByUserIdRedis(ctx context.Context, userId s
 
 	readDaysQuery := `SELECT dayofweek(LastUpdatedAt) 
 					FROM game_read_daily 
-					WHERE uuid_from_bin(UserId) = ? AND DATE(LastUpdatedAt) >= STR_TO_DATE(?,'%Y-%m-%d');`
+					WHERE UserId = uuid_to_bin(?) AND LastUpdatedAt >= STR_TO_DATE(?,'%Y-%m-%d');`
 	readDaysResult, err := service.MySql.DbContext.Query(readDaysQuery, userId, lastMonday)
 	if err != nil {
 		service.MySql.HandleError(err)
@@ -167,18 +172,24 @@ func (service *UserService) GetWalletByUserId(ctx context.Context, userId string
 	var user dto.User
 	user, err := service.GetWalletByUserIdRedis(ctx, userId)
 	if err == redis.Nil {
-		err = service.RedisService.UpdateUserWalletRedis(userId)
+		err = service.Cache.Rebuild(constant.RedisPrefixKeyUserWallet + userId, func() error {
+			return service.RedisService.UpdateUserWalletRedis(userId)
+		})
 		if err == nil {
 			user, err = service.GetWalletByUserIdRedis(ctx, userId)
 		}
 	}
 
-	if err == nil {
-		return user, nil
+	if err != nil {
+		// Update error  or get error
+		user, err = service.GetWalletByUserIdSQL(ctx, userId)
+		if err != nil {
+			return user, err
+		}
 	}
 
-	// Update error  or get error
-	user, err = service.GetWalletByUserIdSQL(ctx, userId)
+	// Coins expiring soon change over time, they are not cached
+	user.ExpiringSoon, err = service.WalletService.GetExpiringSoon(ctx, userId)
 
 	return user, err
 }
@@ -193,7 +204,9 @@ func (service *UserService) ListTransactions(ctx context.Context, userId string,
 	var listTransaction []dto.User
 	listTransaction, err := service.ListTransactionsRedis(ctx, userId, option, pageSize, pageIndex)
 	if err == redis.Nil {
-		err = service.RedisService.UpdateTransactionRedis(userId)
+		err = service.Cache.Rebuild(constant.RedisPrefixKeyAllTransaction + userId, func() error {
+			return service.RedisService.RebuildTransactionRedis(userId)
+		})
 		if err == nil {
 			listTransaction, err = service.ListTransactionsRedis(ctx, userId, option, pageSize, pageIndex)
 		}
@@ -220,11 +233,29 @@ func (service *UserService) ListTransactionsSQL(ctx context.Context, userId stri
 	var listTransactionQuery string
 	switch option {
 	case 0:
-		listTransactionQuery = `SELECT uuid_from_bin(user_wallet.UserId) AS UserId, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt FROM user_wallet, user_prize WHERE user_wallet.PrizeId = user_prize.Id AND uuid_from_bin(user_wallet.UserId) = ? ORDER BY user_wallet.LastUpdatedAt desc LIMIT ? OFFSET ?;`
+		listTransactionQuery = `SELECT uuid_from_bin(user_wallet.UserId) AS UserId, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt, 
+									IFNULL(uuid_from_bin(COALESCE(sent.RecipientId, received.SenderId)), "") AS Counterparty 
+								FROM user_wallet 
+								INNER JOIN user_prize ON user_wallet.PrizeId = user_prize.Id 
+								LEFT JOIN user_wallet_transfer AS sent ON sent.SenderWalletId = user_wallet.Id 
+								LEFT JOIN user_wallet_transfer AS received ON received.RecipientWalletId = user_wallet.Id 
+								WHERE user_wallet.UserId = uuid_to_bin(?) ORDER BY user_wallet.LastUpdatedAt desc LIMIT ? OFFSET ?;`
 	case 1:
-		listTransactionQuery = `SELECT uuid_from_bin(user_wallet.UserId) AS UserId, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt FROM user_wallet, user_prize WHERE user_wallet.PrizeId = user_prize.Id AND uuid_from_bin(user_wallet.UserId) = ? AND user_wallet.Value >= 0 ORDER BY user_wallet.LastUpdatedAt desc LIMIT ? OFFSET ?;`
+		listTransactionQuery = `SELECT uuid_from_bin(user_wallet.UserId) AS UserId, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt, 
+									IFNULL(uuid_from_bin(COALESCE(sent.RecipientId, received.SenderId)), "") AS Counterparty 
+								FROM user_wallet 
+								INNER JOIN user_prize ON user_wallet.PrizeId = user_prize.Id 
+								LEFT JOIN user_wallet_transfer AS sent ON sent.SenderWalletId = user_wallet.Id 
+								LEFT JOIN user_wallet_transfer AS received ON received.RecipientWalletId = user_wallet.Id 
+								WHERE user_wallet.UserId = uuid_to_bin(?) AND user_wallet.Value >= 0 ORDER BY user_wallet.LastUpdatedAt desc LIMIT ? OFFSET ?;`
 	case -1:
-		listTransactionQuery = `SELECT uuid_from_bin(user_wallet.UserId) AS UserId, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt FROM user_wallet, user_prize WHERE user_wallet.PrizeId = user_prize.Id AND uuid_from_bin(user_wallet.UserId) = ? AND user_wallet.Value < 0 ORDER BY user_wallet.LastUpdatedAt desc LIMIT ? OFFSET ?;`
+		listTransactionQuery = `SELECT uuid_from_bin(user_wallet.UserId) AS UserId, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt, 
+									IFNULL(uuid_from_bin(COALESCE(sent.RecipientId, received.SenderId)), "") AS Counterparty 
+								FROM user_wallet 
+								INNER JOIN user_prize ON user_wallet.PrizeId = user_prize.Id 
+								LEFT JOIN user_wallet_transfer AS sent ON sent.SenderWalletId = user_wallet.Id 
+								LEFT JOIN user_wallet_transfer AS received ON received.RecipientWalletId = user_wallet.Id 
+								WHERE user_wallet.UserId = uuid_to_bin(?) AND user_wallet.Value < 0 ORDER BY user_wallet.LastUpdatedAt desc LIMIT ? OFFSET ?;`
 	}
 
 	listTransactionResult, err := service.MySql.DbContext.Query(listTransactionQuery, userId, limit, offset)
@@ -238,7 +269,7 @@ func (service *UserService) ListTransactionsSQL(ctx context.Context, userId stri
 
 	for listTransactionResult.Next(){
 		var userTransaction dto.User
-		err = listTransactionResult.Scan(&userTransaction.UserId, &userTransaction.Description, &userTransaction.Value, &userTransaction.LastUpdatedAt)
+		err = listTransactionResult.Scan(&userTransaction.UserId, &userTransaction.Description, &userTransaction.Value, &userTransaction.LastUpdatedAt, &userTransaction.Counterparty)
 		if err != nil {
 			return nil, err
 		}
@@ -257,55 +288,208 @@ func (service *UserService) ListTransactionsRedis(ctx context.Context, userId st
 	limit := pageSize
 	offset := (pageIndex - 1) * pageSize
 
-	result, err := service.Cache.GetWithError(constant.RedisPrefixKeyAllTransaction + userId)
-	if err == redis.Nil {
-		return nil, err
-	} else if err != nil {
-		logger.Error(err.Error())
-		return nil, err
+	return service.RedisService.GetTransactionPageRedis(userId, option, offset, limit)
+}
+
+/*
+	Get transaction history of user from MySql, newest first
+	Pages are read by keyset (LastUpdatedAt, Id), the cursor of next page is the last transaction returned
+ */
+func (service *UserService) ListTransactionHistory(ctx context.Context, userId string, filter dto.TransactionFilter) (dto.TransactionPage, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	page := dto.TransactionPage{
+		Items: []dto.Transaction{},
 	}
 
-	var listTransaction []dto.User
-	err = json.Unmarshal([]byte(result), &listTransaction)
-	if err != nil {
-		logger.Error(err.Error())
-		return nil, err
+	if filter.PageSize <= 0 {
+		filter.PageSize = constant.DefaultTransactionPageSize
+	} else if filter.PageSize > constant.MaximumTransactionPageSize {
+		filter.PageSize = constant.MaximumTransactionPageSize
 	}
 
-	var selectedTransaction []dto.User
-	if option == 1 {
-		for _, trans := range listTransaction {
-			if trans.Value >= 0 {
-				selectedTransaction = append(selectedTransaction, trans)
-			}
-		}
-	} else if option == -1 {
-		for _, trans := range listTransaction {
-			if trans.Value < 0 {
-				selectedTransaction = append(selectedTransaction, trans)
-			}
+	listTransactionQuery := `SELECT uuid_from_bin(user_wallet.Id), uuid_from_bin(user_wallet.UserId), user_prize.Name, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt,
+								CASE
+									WHEN game_mobile_card.Id IS NOT NULL THEN ?
+									WHEN invitation.Id IS NOT NULL OR inviting.Id IS NOT NULL OR commission.Id IS NOT NULL THEN ?
+									WHEN game_lottery.Id IS NOT NULL THEN ?
+									WHEN game_read_daily.Id IS NOT NULL THEN ?
+									WHEN sent.Id IS NOT NULL OR received.Id IS NOT NULL THEN ?
+									WHEN adjustment.Id IS NOT NULL THEN ?
+									ELSE ''
+								END AS ReferenceType,
+								IFNULL(COALESCE(uuid_from_bin(game_mobile_card.MobileCardId), uuid_from_bin(invitation.Id), uuid_from_bin(inviting.Id), uuid_from_bin(commission.Id), DATE_FORMAT(game_lottery.Date, '%Y-%m-%d'),
+									uuid_from_bin(game_read_daily.Id), uuid_from_bin(sent.Id), uuid_from_bin(received.Id), uuid_from_bin(adjustment.Id)), '') AS ReferenceId,
+								IFNULL(uuid_from_bin(COALESCE(sent.RecipientId, received.SenderId)), '') AS Counterparty
+							FROM user_wallet
+							INNER JOIN user_prize ON user_wallet.PrizeId = user_prize.Id
+							LEFT JOIN game_mobile_card ON game_mobile_card.WalletId = user_wallet.Id
+							LEFT JOIN game_inviting AS invitation ON invitation.WalletId = user_wallet.Id
+							LEFT JOIN game_inviting AS inviting ON inviting.InvitingWalletId = user_wallet.Id
+							LEFT JOIN game_inviting AS commission ON commission.CommissionWalletId = user_wallet.Id
+							LEFT JOIN game_lottery ON game_lottery.WalletId = user_wallet.Id
+							LEFT JOIN game_read_daily ON game_read_daily.WalletId = user_wallet.Id
+							LEFT JOIN user_wallet_transfer AS sent ON sent.SenderWalletId = user_wallet.Id
+							LEFT JOIN user_wallet_transfer AS received ON received.RecipientWalletId = user_wallet.Id
+							LEFT JOIN user_wallet_adjustment AS adjustment ON adjustment.WalletId = user_wallet.Id
+							WHERE user_wallet.UserId = uuid_to_bin(?)`
+	args := []interface{}{
+		constant.TransactionReferenceMobileCard, constant.TransactionReferenceInvitation, constant.TransactionReferenceLottery,
+		constant.TransactionReferenceReadDaily, constant.TransactionReferenceTransfer, constant.TransactionReferenceAdjustment,
+		userId,
+	}
+
+	if len(filter.Programs) > 0 {
+		listTransactionQuery += ` AND user_prize.Name IN (?` + strings.Repeat(`, ?`, len(filter.Programs) - 1) + `)`
+		for _, program := range filter.Programs {
+			args = append(args, program)
 		}
-	} else {
-		selectedTransaction = listTransaction
+	}
+	if !filter.FromDate.IsZero() {
+		listTransactionQuery += ` AND user_wallet.LastUpdatedAt >= ?`
+		args = append(args, filter.FromDate)
+	}
+	if !filter.ToDate.IsZero() {
+		listTransactionQuery += ` AND user_wallet.LastUpdatedAt < ?`
+		args = append(args, filter.ToDate)
+	}
+	if filter.Direction == 1 {
+		listTransactionQuery += ` AND user_wallet.Value >= 0`
+	} else if filter.Direction == -1 {
+		listTransactionQuery += ` AND user_wallet.Value < 0`
+	}
+	if filter.CursorId != "" {
+		listTransactionQuery += ` AND (user_wallet.LastUpdatedAt < ? OR (user_wallet.LastUpdatedAt = ? AND user_wallet.Id < uuid_to_bin(?)))`
+		args = append(args, filter.CursorTime, filter.CursorTime, filter.CursorId)
 	}
 
-	if len(selectedTransaction) >= limit + offset {
-		return selectedTransaction[offset:offset+limit], nil
-	} else if len(selectedTransaction) > offset &&  len(selectedTransaction) < limit + offset{
-		return selectedTransaction[offset:], nil
-	}else {
-		return selectedTransaction[0:0], nil
+	// One more row tells whether there is a next page
+	listTransactionQuery += ` ORDER BY user_wallet.LastUpdatedAt DESC, user_wallet.Id DESC LIMIT ?;`
+	args = append(args, filter.PageSize + 1)
+
+	listTransactionResult, err := service.MySql.DbContext.Query(listTransactionQuery, args...)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return page, err
 	}
+	defer listTransactionResult.Close()
 
-}
+	var lastCreatedAt time.Time
+	for listTransactionResult.Next(){
+		if len(page.Items) == filter.PageSize {
+			last := page.Items[len(page.Items) - 1]
+			page.NextCursor = util.EncodeCursor(lastCreatedAt, last.Id)
+			break
+		}
 
+		var transaction dto.Transaction
+		err = listTransactionResult.Scan(&transaction.Id, &transaction.UserId, &transaction.Program, &transaction.Description, &transaction.Value, &lastCreatedAt,
+			&transaction.ReferenceType, &transaction.ReferenceId, &transaction.Counterparty)
+		if err != nil {
+			logger.Error(err.Error())
+			return page, err
+		}
+		transaction.CreatedAt = lastCreatedAt.Format(time.RFC3339)
 
-func (service *UserService) ResetRedis(ctx context.Context) error {
+		page.Items = append(page.Items, transaction)
+	}
+
+	return page, nil
+}
+/*
+	Get statement of user's wallet for the month containing given time
+	Credits and debits are grouped by program (prize), entries are listed oldest first with running balance
+ */
+func (service *UserService) GetWalletStatement(ctx context.Context, userId string, month time.Time) (dto.WalletStatement, error) {
 	// 	Setting up timeout
 	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
 	defer cancel()
 
-	// Check if it exist in Redis
-	return service.RedisService.ResetRedis()
+	monthStart := time.Date(month.Year(), month.Month(), 1, 0, 0, 0, 0, month.Location())
+	monthEnd := monthStart.AddDate(0, 1, 0)
+
+	statement := dto.WalletStatement{
+		UserId:		userId,
+		Month:		monthStart.Format("2006-01"),
+		Credits:	[]dto.StatementLine{},
+		Debits:		[]dto.StatementLine{},
+		Entries:	[]dto.StatementEntry{},
+	}
+
+	// Opening balance
+	openingQuery := `SELECT IFNULL(SUM(Value), 0) FROM user_wallet WHERE UserId = uuid_to_bin(?) AND LastUpdatedAt < ?;`
+	openingResult, err := service.MySql.DbContext.Query(openingQuery, userId, monthStart)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return statement, err
+	}
+	defer openingResult.Close()
+
+	if openingResult.Next(){
+		err = openingResult.Scan(&statement.OpeningBalance)
+		if err != nil {
+			logger.Error(err.Error())
+			return statement, err
+		}
+	}
+
+	// Movements of the month
+	entryQuery := `SELECT user_prize.Name, user_prize.Description, user_wallet.Value, user_wallet.LastUpdatedAt
+					FROM user_wallet
+					INNER JOIN user_prize ON user_wallet.PrizeId = user_prize.Id
+					WHERE user_wallet.UserId = uuid_to_bin(?) AND user_wallet.LastUpdatedAt >= ? AND user_wallet.LastUpdatedAt < ?
+					ORDER BY user_wallet.LastUpdatedAt ASC, user_wallet.Id ASC;`
+	entryResult, err := service.MySql.DbContext.Query(entryQuery, userId, monthStart, monthEnd)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return statement, err
+	}
+	defer entryResult.Close()
+
+	creditIndex := make(map[string]int)
+	debitIndex := make(map[string]int)
+	balance := statement.OpeningBalance
+	for entryResult.Next(){
+		var entry dto.StatementEntry
+		var createdAt time.Time
+		err = entryResult.Scan(&entry.Program, &entry.Description, &entry.Value, &createdAt)
+		if err != nil {
+			logger.Error(err.Error())
+			return statement, err
+		}
+		balance += entry.Value
+		entry.Balance = balance
+		entry.Date = createdAt.Format(time.RFC3339)
+		statement.Entries = append(statement.Entries, entry)
+
+		if entry.Value >= 0 {
+			statement.Credits = addStatementLine(statement.Credits, creditIndex, entry)
+			statement.TotalCredit += entry.Value
+		} else {
+			statement.Debits = addStatementLine(statement.Debits, debitIndex, entry)
+			statement.TotalDebit += entry.Value
+		}
+	}
+
+	statement.ClosingBalance = balance
+
+	return statement, nil
+}
+
+/*
+	Add entry to the line of its program
+ */
+func addStatementLine(lines []dto.StatementLine, index map[string]int, entry dto.StatementEntry) []dto.StatementLine {
+	i, existed := index[entry.Program]
+	if !existed {
+		index[entry.Program] = len(lines)
+		return append(lines, dto.StatementLine{Program: entry.Program, Description: entry.Description, Count: 1, Value: entry.Value})
+	}
+
+	lines[i].Count++
+	lines[i].Value += entry.Value
+	return lines
 }
 
@@ -0,0 +1,807 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"g-tech.com/constant"
+	"g-tech.com/dto"
+	"g-tech.com/gerror"
+	"g-tech.com/infrastructure/cache"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/infrastructure/repository"
+	"g-tech.com/infrastructure/util"
+	"github.com/go-sql-driver/mysql"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+/*
+	Returned by ConsumeCredits when coins of user do not cover the amount
+ */
+var ErrNotEnoughCoin = errors.New("Not enough coin to consume")
+
+type IWalletService interface {
+	// For other services (inside their transaction)
+	CreateCredit(tx *sql.Tx, walletId string, userId string, prizeId string, value int) error
+	CreateDebit(tx *sql.Tx, walletId string, userId string, prizeId string, value int) error
+	ConsumeCredits(tx *sql.Tx, userId string, amount int) error
+	ExpiryDays(programName string) int
+
+	// For app
+	GetExpiringSoon(ctx context.Context, userId string) (int, error)
+	Transfer(ctx context.Context, transfer dto.WalletTransfer) (dto.WalletTransfer, int, error)
+
+	// For web
+	CreateAdjustment(ctx context.Context, adjustment dto.WalletAdjustment) (dto.WalletAdjustment, int, error)
+	CreateBulkAdjustment(ctx context.Context, adjustment dto.WalletAdjustment, userIds []string) (dto.BulkAdjustmentResult, int, error)
+	ListAdjustments(ctx context.Context, userId string, pageSize int, pageIndex int) ([]dto.WalletAdjustment, error)
+
+	// For scheduler
+	ExpireCredits(ctx context.Context) (int, error)
+}
+
+type WalletService struct {
+	MySql 			repository.MySqlRepository
+	Cache 			cache.CacheManager
+	RedisService	RedisService
+	ConfigService	ConfigService
+	OutboxService	IOutboxService
+	Config			WalletConfig
+	Timeout    		time.Duration
+}
+
+/*
+	Coin expiry configuration
+	+ ExpiryDays: days before coins of a program expire (by program name), 0 means never
+	+ DefaultExpiryDays: used for programs not listed in ExpiryDays
+	Coin transfer limits (0 means unlimited)
+	+ TransferDailyLimit: coins a user can send per day
+	+ TransferDailyCount: transfers a user can make per day
+	+ TransferMinimumBalance: coins sender must keep after a transfer
+ */
+type WalletConfig struct {
+	DefaultExpiryDays		int
+	ExpiryDays				map[string]int
+	ExpiringSoonDays		int
+	ExpiryBatchSize			int
+	TransferMinimumValue	int
+	TransferMinimumBalance	int
+	TransferDailyLimit		int
+	TransferDailyCount		int
+}
+
+func NewWalletService(dbContext *sql.DB, cache cache.CacheManager, redisService RedisService, configService ConfigService, outboxService IOutboxService, timeout time.Duration) IWalletService {
+	service := WalletService{}
+	service.MySql.SetDbContext(dbContext)
+	service.Cache = cache
+	service.RedisService = redisService
+	service.ConfigService = configService
+	service.OutboxService = outboxService
+	service.Config = loadWalletConfig()
+	service.Timeout = timeout
+	return &service
+}
+
+func loadWalletConfig() WalletConfig {
+	viper.SetDefault("Wallet.DefaultExpiryDays", 0)
+	viper.SetDefault("Wallet.ExpiringSoonDays", 7)
+	viper.SetDefault("Wallet.ExpiryBatchSize", 500)
+	viper.SetDefault("Wallet.Transfer.MinimumValue", 1)
+	viper.SetDefault("Wallet.Transfer.MinimumBalance", 0)
+	viper.SetDefault("Wallet.Transfer.DailyLimit", 0)
+	viper.SetDefault("Wallet.Transfer.DailyCount", 0)
+
+	// Viper keys are case insensitive (lower case)
+	expiryDays := make(map[string]int)
+	for programName := range viper.GetStringMap("Wallet.ExpiryDays") {
+		expiryDays[programName] = viper.GetInt("Wallet.ExpiryDays." + programName)
+	}
+
+	return WalletConfig{
+		DefaultExpiryDays:		viper.GetInt("Wallet.DefaultExpiryDays"),
+		ExpiryDays:				expiryDays,
+		ExpiringSoonDays:		viper.GetInt("Wallet.ExpiringSoonDays"),
+		ExpiryBatchSize:		viper.GetInt("Wallet.ExpiryBatchSize"),
+		TransferMinimumValue:	viper.GetInt("Wallet.Transfer.MinimumValue"),
+		TransferMinimumBalance:	viper.GetInt("Wallet.Transfer.MinimumBalance"),
+		TransferDailyLimit:		viper.GetInt("Wallet.Transfer.DailyLimit"),
+		TransferDailyCount:		viper.GetInt("Wallet.Transfer.DailyCount"),
+	}
+}
+
+/*
+	Days before coins earned from a program expire, 0 means never
+ */
+func (service *WalletService) ExpiryDays(programName string) int {
+	days, existed := service.Config.ExpiryDays[strings.ToLower(programName)]
+	if !existed {
+		return service.Config.DefaultExpiryDays
+	}
+	return days
+}
+
+/*
+	Add an earning record to user's wallet (table: user_wallet)
+	Its RemainingValue is consumed by spending (FIFO) or by the expiry job
+ */
+func (service *WalletService) CreateCredit(tx *sql.Tx, walletId string, userId string, prizeId string, value int) error {
+	var programName string
+	prizeResult, err := tx.Query(`SELECT Name FROM user_prize WHERE Id = uuid_to_bin(?);`, prizeId)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+	if prizeResult.Next(){
+		err = prizeResult.Scan(&programName)
+	}
+	_ = prizeResult.Close()
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	return service.insertCredit(tx, walletId, userId, prizeId, programName, value, mysql.NullTime{}, service.ExpiryDays(programName))
+}
+
+/*
+	Insert an earning record expiring at expiredAt, or after expiryDays when expiredAt is not set
+	Neither set: coins never expire
+ */
+func (service *WalletService) insertCredit(tx *sql.Tx, walletId string, userId string, prizeId string, programName string, value int, expiredAt mysql.NullTime, expiryDays int) error {
+	createCreditStatement := `INSERT INTO user_wallet(Id, UserId, PrizeId, Value, RemainingValue, ExpiredAt)
+								VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?, ?, IFNULL(?, IF(? > 0, NOW() + INTERVAL ? DAY, NULL)));`
+	_, err := tx.Exec(createCreditStatement, walletId, userId, prizeId, value, value, expiredAt, expiryDays, expiryDays)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	event := dto.WalletCreditedEvent{
+		UserId:		userId,
+		WalletId:	walletId,
+		Program:	programName,
+		Value:		value,
+	}
+	return service.OutboxService.Add(tx, userId, []string{constant.CacheFamilyTransaction, constant.CacheFamilyUserWallet}, []string{walletId}, constant.EventWalletCredited, event)
+}
+
+/*
+	Add a spending record (negative value) to user's wallet (table: user_wallet), after its coins are consumed
+ */
+func (service *WalletService) CreateDebit(tx *sql.Tx, walletId string, userId string, prizeId string, value int) error {
+	createDebitStatement := `INSERT INTO user_wallet(Id, UserId, PrizeId, Value) VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?);`
+	_, err := tx.Exec(createDebitStatement, walletId, userId, prizeId, value)
+	if err != nil {
+		logger.Error(err.Error())
+		return err
+	}
+
+	return service.OutboxService.Add(tx, userId, []string{constant.CacheFamilyTransaction, constant.CacheFamilyUserWallet}, []string{walletId}, "", nil)
+}
+
+/*
+	Consume coins of user (FIFO) before adding a spending record
+	+ coins earned before expiry was introduced are not tracked, they are the oldest and consumed first
+	+ then earning records are consumed from the oldest one
+	User's records are locked until the transaction ends, ErrNotEnoughCoin is returned when the wallet does not cover amount
+ */
+func (service *WalletService) ConsumeCredits(tx *sql.Tx, userId string, amount int) error {
+	_, err := service.consumeCredits(tx, userId, amount)
+	return err
+}
+
+/*
+	Consume coins of user (FIFO)
+	Returns the earliest expiry of the consumed records, not set when none of them expires
+ */
+func (service *WalletService) consumeCredits(tx *sql.Tx, userId string, amount int) (mysql.NullTime, error) {
+	var earliestExpiry mysql.NullTime
+
+	var wallet, tracked int
+	walletResult, err := tx.Query(`SELECT IFNULL(SUM(Value), 0), IFNULL(SUM(RemainingValue), 0) FROM user_wallet WHERE UserId = uuid_to_bin(?) FOR UPDATE;`, userId)
+	if err != nil {
+		logger.Error(err.Error())
+		return earliestExpiry, err
+	}
+	if walletResult.Next(){
+		err = walletResult.Scan(&wallet, &tracked)
+	}
+	_ = walletResult.Close()
+	if err != nil {
+		logger.Error(err.Error())
+		return earliestExpiry, err
+	}
+
+	creditQuery := `SELECT uuid_from_bin(Id), RemainingValue, ExpiredAt
+					FROM user_wallet
+					WHERE UserId = uuid_to_bin(?) AND RemainingValue > 0
+					ORDER BY LastUpdatedAt ASC;`
+	creditResult, err := tx.Query(creditQuery, userId)
+	if err != nil {
+		logger.Error(err.Error())
+		return earliestExpiry, err
+	}
+
+	var credits []walletCredit
+	for creditResult.Next(){
+		var c walletCredit
+		err = creditResult.Scan(&c.Id, &c.RemainingValue, &c.ExpiredAt)
+		if err != nil {
+			_ = creditResult.Close()
+			logger.Error(err.Error())
+			return earliestExpiry, err
+		}
+		credits = append(credits, c)
+	}
+	_ = creditResult.Close()
+
+	consumptions, missing := allocateCredits(wallet - tracked, credits, amount)
+	if missing > 0 {
+		return earliestExpiry, ErrNotEnoughCoin
+	}
+
+	// Keep LastUpdatedAt, it is the date of the record in the history
+	consumeStatement := `UPDATE user_wallet SET RemainingValue = RemainingValue - ?, LastUpdatedAt = LastUpdatedAt WHERE Id = uuid_to_bin(?);`
+	for _, c := range consumptions {
+		_, err = tx.Exec(consumeStatement, c.Value, c.Id)
+		if err != nil {
+			logger.Error(err.Error())
+			return earliestExpiry, err
+		}
+	}
+
+	return earliestExpiryOf(consumptions), nil
+}
+
+/*
+	Earning record of user with coins left
+ */
+type walletCredit struct {
+	Id				string
+	RemainingValue	int
+	ExpiredAt		mysql.NullTime
+}
+
+/*
+	Coins taken from an earning record
+ */
+type creditConsumption struct {
+	Id				string
+	Value			int
+	ExpiredAt		mysql.NullTime
+}
+
+/*
+	Split amount over coins of user (FIFO)
+	+ untracked coins are consumed first
+	+ then credits, given from the oldest one
+	Returns coins taken from each credit and the part of amount left unpaid
+ */
+func allocateCredits(untracked int, credits []walletCredit, amount int) ([]creditConsumption, int) {
+	if untracked > 0 {
+		amount -= untracked
+	}
+
+	var consumptions []creditConsumption
+	for _, c := range credits {
+		if amount <= 0 {
+			break
+		}
+
+		consumed := c.RemainingValue
+		if consumed > amount {
+			consumed = amount
+		}
+
+		consumptions = append(consumptions, creditConsumption{Id: c.Id, Value: consumed, ExpiredAt: c.ExpiredAt})
+		amount -= consumed
+	}
+
+	if amount < 0 {
+		amount = 0
+	}
+
+	return consumptions, amount
+}
+
+/*
+	Earliest expiry of consumed coins, not set when none of them expires
+ */
+func earliestExpiryOf(consumptions []creditConsumption) mysql.NullTime {
+	var earliestExpiry mysql.NullTime
+	for _, c := range consumptions {
+		if c.ExpiredAt.Valid && (!earliestExpiry.Valid || c.ExpiredAt.Time.Before(earliestExpiry.Time)) {
+			earliestExpiry = c.ExpiredAt
+		}
+	}
+
+	return earliestExpiry
+}
+
+/*
+	Coins of user expiring within the next ExpiringSoonDays
+ */
+func (service *WalletService) GetExpiringSoon(ctx context.Context, userId string) (int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	expiringSoonQuery := `SELECT IFNULL(SUM(RemainingValue), 0)
+							FROM user_wallet
+							WHERE UserId = uuid_to_bin(?) AND RemainingValue > 0 AND ExpiredAt > NOW() AND ExpiredAt <= NOW() + INTERVAL ? DAY;`
+	expiringSoonResult, err := service.MySql.DbContext.Query(expiringSoonQuery, userId, service.Config.ExpiringSoonDays)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return 0, err
+	}
+	defer expiringSoonResult.Close()
+
+	var expiringSoon int
+	if expiringSoonResult.Next(){
+		err = expiringSoonResult.Scan(&expiringSoon)
+		if err != nil {
+			logger.Error(err.Error())
+			return 0, err
+		}
+	}
+
+	return expiringSoon, nil
+}
+
+/*
+	Expire the remaining coins of expired earning records
+	+ a spending record (program CoinExpired) is added for each of them
+	Returns number of expired records
+ */
+func (service *WalletService) ExpireCredits(ctx context.Context) (int, error) {
+	expiredPrize, status, err := service.ConfigService.GetPrize(constant.ProgramCoinExpired)
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+	if status == false || expiredPrize.Id == "" {
+		return 0, errors.New("Program " + constant.ProgramCoinExpired + " not found")
+	}
+
+	expiredQuery := `SELECT uuid_from_bin(Id), uuid_from_bin(UserId), RemainingValue
+						FROM user_wallet
+						WHERE ExpiredAt <= NOW() AND RemainingValue > 0
+						LIMIT ?;`
+	consumeStatement := `UPDATE user_wallet SET RemainingValue = 0, LastUpdatedAt = LastUpdatedAt WHERE Id = uuid_to_bin(?) AND RemainingValue = ?;`
+
+	expired := 0
+	expiryWalletIds := make(map[string][]string)
+	for {
+		type credit struct {
+			Id				string
+			UserId			string
+			RemainingValue	int
+		}
+
+		expiredResult, err := service.MySql.DbContext.Query(expiredQuery, service.Config.ExpiryBatchSize)
+		if err != nil {
+			service.MySql.HandleError(err)
+			return expired, err
+		}
+
+		var credits []credit
+		for expiredResult.Next(){
+			var c credit
+			err = expiredResult.Scan(&c.Id, &c.UserId, &c.RemainingValue)
+			if err != nil {
+				_ = expiredResult.Close()
+				logger.Error(err.Error())
+				return expired, err
+			}
+			credits = append(credits, c)
+		}
+		_ = expiredResult.Close()
+
+		if len(credits) == 0 {
+			break
+		}
+
+		for _, c := range credits {
+			tx, err := service.MySql.DbContext.Begin()
+			if err != nil {
+				service.MySql.HandleError(err)
+				return expired, err
+			}
+
+			// Record may have been consumed meanwhile, it will be selected again
+			consumeResult, err := tx.Exec(consumeStatement, c.Id, c.RemainingValue)
+			if err != nil {
+				_ = tx.Rollback()
+				logger.Error(err.Error())
+				return expired, err
+			}
+			rowsAffected, err := consumeResult.RowsAffected()
+			if err != nil || rowsAffected == 0 {
+				_ = tx.Rollback()
+				continue
+			}
+
+			expiryWalletId := util.NewUuid()
+			err = service.CreateDebit(tx, expiryWalletId, c.UserId, expiredPrize.Id, -c.RemainingValue)
+			if err != nil {
+				_ = tx.Rollback()
+				return expired, err
+			}
+
+			err = tx.Commit()
+			if err != nil {
+				logger.Error(err.Error())
+				return expired, err
+			}
+			expired++
+			expiryWalletIds[c.UserId] = append(expiryWalletIds[c.UserId], expiryWalletId)
+		}
+	}
+
+	/*
+		Update Redis
+	 */
+	for userId, walletIds := range expiryWalletIds {
+		service.RedisService.RefreshTransactionRedis(userId, walletIds...)
+
+		service.RedisService.RefreshUserWalletRedis(userId)
+	}
+
+	return expired, nil
+}
+
+/*
+	Credit (positive value) or debit (negative value) coins of user by an operator
+	Adjustment is recorded in table user_wallet_adjustment (audit trail)
+ */
+func (service *WalletService) CreateAdjustment(ctx context.Context, adjustment dto.WalletAdjustment) (dto.WalletAdjustment, int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	if adjustment.Value == 0 || strings.TrimSpace(adjustment.Reason) == "" || strings.TrimSpace(adjustment.CreatedBy) == "" {
+		return adjustment, gerror.ErrorWalletAdjustmentInvalid, nil
+	}
+
+	adjustmentPrize, status, err := service.ConfigService.GetPrize(constant.ProgramAdminAdjustment)
+	if err != nil {
+		logger.Error(err.Error())
+		return adjustment, 0, err
+	}
+	if status == false || adjustmentPrize.Id == "" {
+		return adjustment, gerror.ErrorWalletAdjustmentProgramNotFound, nil
+	}
+
+	adjustment.BatchId = ""
+	return service.adjust(adjustment, adjustmentPrize)
+}
+
+/*
+	Grant the same amount of coins to a list of users (compensation)
+	Users are adjusted one by one, failed ones are reported and do not stop the others
+ */
+func (service *WalletService) CreateBulkAdjustment(ctx context.Context, adjustment dto.WalletAdjustment, userIds []string) (dto.BulkAdjustmentResult, int, error) {
+	result := dto.BulkAdjustmentResult{
+		BatchId:	util.NewUuid(),
+		Total:		len(userIds),
+		Failed:		[]dto.BulkAdjustmentFailure{},
+	}
+
+	if adjustment.Value <= 0 || len(userIds) == 0 || strings.TrimSpace(adjustment.Reason) == "" || strings.TrimSpace(adjustment.CreatedBy) == "" {
+		return result, gerror.ErrorWalletAdjustmentInvalid, nil
+	}
+
+	adjustmentPrize, status, err := service.ConfigService.GetPrize(constant.ProgramAdminAdjustment)
+	if err != nil {
+		logger.Error(err.Error())
+		return result, 0, err
+	}
+	if status == false || adjustmentPrize.Id == "" {
+		return result, gerror.ErrorWalletAdjustmentProgramNotFound, nil
+	}
+
+	adjustment.BatchId = result.BatchId
+	for _, userId := range userIds {
+		adjustment.UserId = userId
+		_, errorCode, err := service.adjust(adjustment, adjustmentPrize)
+		if err != nil {
+			result.Failed = append(result.Failed, dto.BulkAdjustmentFailure{UserId: userId, ErrorCode: gerror.ErrorSaveData, Message: err.Error()})
+			continue
+		}
+		if errorCode != 0 {
+			result.Failed = append(result.Failed, dto.BulkAdjustmentFailure{UserId: userId, ErrorCode: errorCode, Message: gerror.T(errorCode)})
+			continue
+		}
+		result.Succeeded++
+	}
+
+	logger.Info("Bulk adjustment %s by %s: %d/%d succeeded", result.BatchId, adjustment.CreatedBy, result.Succeeded, result.Total)
+
+	return result, 0, nil
+}
+
+/*
+	Write adjustment of one user in a transaction then update Redis
+ */
+func (service *WalletService) adjust(adjustment dto.WalletAdjustment, adjustmentPrize dto.Prize) (dto.WalletAdjustment, int, error) {
+	// Check user
+	userResult, err := service.MySql.DbContext.Query(`SELECT Id FROM sso_user WHERE Id = uuid_to_bin(?);`, adjustment.UserId)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return adjustment, 0, err
+	}
+	isExisted := userResult.Next()
+	_ = userResult.Close()
+	if !isExisted {
+		return adjustment, gerror.ErrorWalletUserNotExisted, nil
+	}
+
+	// Start transaction
+	tx, err := service.MySql.DbContext.Begin()
+	if err != nil {
+		service.MySql.HandleError(err)
+		return adjustment, 0, err
+	}
+
+	adjustment.Id = util.NewUuid()
+	adjustment.WalletId = util.NewUuid()
+	if adjustment.Value > 0 {
+		err = service.CreateCredit(tx, adjustment.WalletId, adjustment.UserId, adjustmentPrize.Id, adjustment.Value)
+		if err != nil {
+			_ = tx.Rollback()
+			return adjustment, 0, err
+		}
+	} else {
+		// Wallet can not be negative
+		wallet, err := service.lockWallet(tx, adjustment.UserId)
+		if err != nil {
+			_ = tx.Rollback()
+			return adjustment, 0, err
+		}
+		if wallet < - adjustment.Value {
+			_ = tx.Rollback()
+			return adjustment, gerror.ErrorNotEnoughCoin, nil
+		}
+
+		err = service.ConsumeCredits(tx, adjustment.UserId, - adjustment.Value)
+		if err != nil {
+			_ = tx.Rollback()
+			return adjustment, 0, err
+		}
+
+		err = service.CreateDebit(tx, adjustment.WalletId, adjustment.UserId, adjustmentPrize.Id, adjustment.Value)
+		if err != nil {
+			_ = tx.Rollback()
+			return adjustment, 0, err
+		}
+	}
+
+	createAdjustmentStatement := `INSERT INTO user_wallet_adjustment(Id, WalletId, UserId, Value, Reason, TicketReference, CreatedBy, BatchId)
+									VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?, ?, ?, ?, uuid_to_bin(NULLIF(?, '')));`
+	_, err = tx.Exec(createAdjustmentStatement, adjustment.Id, adjustment.WalletId, adjustment.UserId, adjustment.Value,
+		adjustment.Reason, adjustment.TicketReference, adjustment.CreatedBy, adjustment.BatchId)
+	if err != nil {
+		_ = tx.Rollback()
+		logger.Error(err.Error())
+		return adjustment, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error(err.Error())
+		return adjustment, 0, err
+	}
+
+	/*
+		Update Redis
+	 */
+	service.RedisService.RefreshTransactionRedis(adjustment.UserId, adjustment.WalletId)
+
+	service.RedisService.RefreshUserWalletRedis(adjustment.UserId)
+
+	return adjustment, 0, nil
+}
+
+/*
+	Get audit trail of adjustments, of a user or of all users (empty userId)
+ */
+func (service *WalletService) ListAdjustments(ctx context.Context, userId string, pageSize int, pageIndex int) ([]dto.WalletAdjustment, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	limit := pageSize
+	offset := (pageIndex - 1) * pageSize
+
+	listAdjustmentQuery := `SELECT uuid_from_bin(Id), uuid_from_bin(WalletId), uuid_from_bin(UserId), Value, Reason, TicketReference, CreatedBy,
+								IFNULL(uuid_from_bin(BatchId), ""), CreatedAt
+							FROM user_wallet_adjustment
+							WHERE ? = '' OR UserId = uuid_to_bin(?)
+							ORDER BY CreatedAt DESC
+							LIMIT ? OFFSET ?;`
+	listAdjustmentResult, err := service.MySql.DbContext.Query(listAdjustmentQuery, userId, userId, limit, offset)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return nil, err
+	}
+	defer listAdjustmentResult.Close()
+
+	var listAdjustment []dto.WalletAdjustment
+	for listAdjustmentResult.Next(){
+		var adjustment dto.WalletAdjustment
+		err = listAdjustmentResult.Scan(&adjustment.Id, &adjustment.WalletId, &adjustment.UserId, &adjustment.Value, &adjustment.Reason,
+			&adjustment.TicketReference, &adjustment.CreatedBy, &adjustment.BatchId, &adjustment.CreatedAt)
+		if err != nil {
+			logger.Error(err.Error())
+			return nil, err
+		}
+		listAdjustment = append(listAdjustment, adjustment)
+	}
+
+	return listAdjustment, nil
+}
+
+/*
+	Get wallet of user and lock its records until the transaction ends
+ */
+func (service *WalletService) lockWallet(tx *sql.Tx, userId string) (int, error) {
+	var wallet int
+	walletResult, err := tx.Query(`SELECT IFNULL(SUM(Value), 0) FROM user_wallet WHERE UserId = uuid_to_bin(?) FOR UPDATE;`, userId)
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+	if walletResult.Next(){
+		err = walletResult.Scan(&wallet)
+	}
+	_ = walletResult.Close()
+	if err != nil {
+		logger.Error(err.Error())
+		return 0, err
+	}
+
+	return wallet, nil
+}
+
+/*
+	Gift coins to another user
+	Debit of sender and credit of recipient are written in the same transaction (table: user_wallet_transfer)
+ */
+func (service *WalletService) Transfer(ctx context.Context, transfer dto.WalletTransfer) (dto.WalletTransfer, int, error) {
+	// 	Setting up timeout
+	ctx, cancel := context.WithTimeout(ctx, service.Timeout)
+	defer cancel()
+
+	if transfer.Value <= 0 || transfer.Value < service.Config.TransferMinimumValue {
+		return transfer, gerror.ErrorTransferInvalid, nil
+	}
+	if transfer.SenderId == transfer.RecipientId {
+		return transfer, gerror.ErrorTransferToYourself, nil
+	}
+
+	sentPrize, status, err := service.ConfigService.GetPrize(constant.ProgramTransferSent)
+	if err != nil {
+		logger.Error(err.Error())
+		return transfer, 0, err
+	}
+	if status == false || sentPrize.Id == "" {
+		return transfer, gerror.ErrorTransferProgramNotFound, nil
+	}
+	receivedPrize, status, err := service.ConfigService.GetPrize(constant.ProgramTransferReceived)
+	if err != nil {
+		logger.Error(err.Error())
+		return transfer, 0, err
+	}
+	if status == false || receivedPrize.Id == "" {
+		return transfer, gerror.ErrorTransferProgramNotFound, nil
+	}
+
+	// Check recipient
+	recipientResult, err := service.MySql.DbContext.Query(`SELECT Id FROM sso_user WHERE Id = uuid_to_bin(?);`, transfer.RecipientId)
+	if err != nil {
+		service.MySql.HandleError(err)
+		return transfer, 0, err
+	}
+	isExisted := recipientResult.Next()
+	_ = recipientResult.Close()
+	if !isExisted {
+		return transfer, gerror.ErrorWalletUserNotExisted, nil
+	}
+
+	// Start transaction
+	tx, err := service.MySql.DbContext.Begin()
+	if err != nil {
+		service.MySql.HandleError(err)
+		return transfer, 0, err
+	}
+
+	// Transfers of sender are serialized by the lock
+	wallet, err := service.lockWallet(tx, transfer.SenderId)
+	if err != nil {
+		_ = tx.Rollback()
+		return transfer, 0, err
+	}
+	if wallet - transfer.Value < 0 {
+		_ = tx.Rollback()
+		return transfer, gerror.ErrorNotEnoughCoin, nil
+	}
+	if wallet - transfer.Value < service.Config.TransferMinimumBalance {
+		_ = tx.Rollback()
+		return transfer, gerror.ErrorTransferBelowMinimumBalance, nil
+	}
+
+	// Daily limits
+	var sentToday, countToday int
+	todayQuery := `SELECT IFNULL(SUM(Value), 0), COUNT(Id) FROM user_wallet_transfer WHERE SenderId = uuid_to_bin(?) AND CreatedAt >= CURRENT_DATE;`
+	todayResult, err := tx.Query(todayQuery, transfer.SenderId)
+	if err != nil {
+		_ = tx.Rollback()
+		logger.Error(err.Error())
+		return transfer, 0, err
+	}
+	if todayResult.Next(){
+		err = todayResult.Scan(&sentToday, &countToday)
+	}
+	_ = todayResult.Close()
+	if err != nil {
+		_ = tx.Rollback()
+		logger.Error(err.Error())
+		return transfer, 0, err
+	}
+	if (service.Config.TransferDailyLimit > 0 && sentToday + transfer.Value > service.Config.TransferDailyLimit) ||
+		(service.Config.TransferDailyCount > 0 && countToday >= service.Config.TransferDailyCount) {
+		_ = tx.Rollback()
+		return transfer, gerror.ErrorTransferExceedDailyLimit, nil
+	}
+
+	// Debit of sender
+	earliestExpiry, err := service.consumeCredits(tx, transfer.SenderId, transfer.Value)
+	if err != nil {
+		_ = tx.Rollback()
+		return transfer, 0, err
+	}
+
+	senderWalletId := util.NewUuid()
+	err = service.CreateDebit(tx, senderWalletId, transfer.SenderId, sentPrize.Id, - transfer.Value)
+	if err != nil {
+		_ = tx.Rollback()
+		return transfer, 0, err
+	}
+
+	// Credit of recipient, gifted coins keep the earliest expiry of the coins taken from sender
+	recipientWalletId := util.NewUuid()
+	err = service.insertCredit(tx, recipientWalletId, transfer.RecipientId, receivedPrize.Id, constant.ProgramTransferReceived, transfer.Value, earliestExpiry, 0)
+	if err != nil {
+		_ = tx.Rollback()
+		return transfer, 0, err
+	}
+
+	transfer.Id = util.NewUuid()
+	createTransferStatement := `INSERT INTO user_wallet_transfer(Id, SenderId, RecipientId, SenderWalletId, RecipientWalletId, Value, Message)
+								VALUES (uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), uuid_to_bin(?), ?, ?);`
+	_, err = tx.Exec(createTransferStatement, transfer.Id, transfer.SenderId, transfer.RecipientId, senderWalletId, recipientWalletId, transfer.Value, transfer.Message)
+	if err != nil {
+		_ = tx.Rollback()
+		logger.Error(err.Error())
+		return transfer, 0, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		logger.Error(err.Error())
+		return transfer, 0, err
+	}
+
+	/*
+		Update Redis
+	 */
+	for userId, walletId := range map[string]string{transfer.SenderId: senderWalletId, transfer.RecipientId: recipientWalletId} {
+		service.RedisService.RefreshTransactionRedis(userId, walletId)
+
+		service.RedisService.RefreshUserWalletRedis(userId)
+	}
+
+	return transfer, 0, nil
+}
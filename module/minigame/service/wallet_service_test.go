@@ -0,0 +1,75 @@
+package service
+
+import (
+	"github.com/go-sql-driver/mysql"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestAllocateCredits(t *testing.T) {
+	credits := []walletCredit{
+		{Id: "oldest", RemainingValue: 10},
+		{Id: "middle", RemainingValue: 5},
+		{Id: "newest", RemainingValue: 20},
+	}
+
+	tests := []struct {
+		name			string
+		untracked		int
+		credits			[]walletCredit
+		amount			int
+		consumptions	[]creditConsumption
+		missing			int
+	}{
+		{"nothing to consume", 0, credits, 0, nil, 0},
+		{"part of the oldest credit", 0, credits, 4, []creditConsumption{{Id: "oldest", Value: 4}}, 0},
+		{"whole oldest credit", 0, credits, 10, []creditConsumption{{Id: "oldest", Value: 10}}, 0},
+		{"spans credits from the oldest", 0, credits, 17, []creditConsumption{{Id: "oldest", Value: 10}, {Id: "middle", Value: 5}, {Id: "newest", Value: 2}}, 0},
+		{"every credit", 0, credits, 35, []creditConsumption{{Id: "oldest", Value: 10}, {Id: "middle", Value: 5}, {Id: "newest", Value: 20}}, 0},
+		{"untracked coins first", 8, credits, 6, nil, 0},
+		{"untracked coins then the oldest credit", 8, credits, 12, []creditConsumption{{Id: "oldest", Value: 4}}, 0},
+		{"negative untracked coins are ignored", -3, credits, 2, []creditConsumption{{Id: "oldest", Value: 2}}, 0},
+		{"not enough coins", 0, credits, 40, []creditConsumption{{Id: "oldest", Value: 10}, {Id: "middle", Value: 5}, {Id: "newest", Value: 20}}, 5},
+		{"no credit", 0, nil, 3, nil, 3},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			consumptions, missing := allocateCredits(test.untracked, test.credits, test.amount)
+			if !reflect.DeepEqual(consumptions, test.consumptions) {
+				t.Errorf("consumptions = %v, want %v", consumptions, test.consumptions)
+			}
+			if missing != test.missing {
+				t.Errorf("missing = %d, want %d", missing, test.missing)
+			}
+		})
+	}
+}
+
+func TestEarliestExpiryOf(t *testing.T) {
+	day := func(d int) mysql.NullTime {
+		return mysql.NullTime{Time: time.Date(2020, 1, d, 0, 0, 0, 0, time.UTC), Valid: true}
+	}
+
+	tests := []struct {
+		name			string
+		consumptions	[]creditConsumption
+		expiry			mysql.NullTime
+	}{
+		{"nothing consumed", nil, mysql.NullTime{}},
+		{"never expiring coins", []creditConsumption{{Id: "a", Value: 1}, {Id: "b", Value: 2}}, mysql.NullTime{}},
+		{"single expiring credit", []creditConsumption{{Id: "a", Value: 1, ExpiredAt: day(5)}}, day(5)},
+		{"earliest of credits", []creditConsumption{{Id: "a", Value: 1, ExpiredAt: day(9)}, {Id: "b", Value: 2, ExpiredAt: day(3)}, {Id: "c", Value: 2, ExpiredAt: day(7)}}, day(3)},
+		{"never expiring coins are skipped", []creditConsumption{{Id: "a", Value: 1}, {Id: "b", Value: 2, ExpiredAt: day(7)}}, day(7)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			expiry := earliestExpiryOf(test.consumptions)
+			if expiry != test.expiry {
+				t.Errorf("expiry = %v, want %v", expiry, test.expiry)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package scheduler
+
+import (
+	"github.com/pkg/errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+/*
+	Schedule of a job
+	+ cron expression of 5 fields: minute hour day-of-month month day-of-week
+	  each field is a value, a range a-b, all values (star), with an optional step /n, or a list of them separated by commas
+	+ @every <duration>, e.g. @every 10s
+	+ @hourly, @daily (midnight), @weekly (Sunday midnight), @monthly
+ */
+type schedule interface {
+	Next(now time.Time) time.Time
+}
+
+/*
+	Ticks are multiples of the interval, every instance waits for the same ones
+ */
+type everySchedule struct {
+	Interval	time.Duration
+}
+
+func (s everySchedule) Next(now time.Time) time.Time {
+	return now.Truncate(s.Interval).Add(s.Interval)
+}
+
+/*
+	Allowed values of each field, as bit sets
+ */
+type cronSchedule struct {
+	Minute		uint64
+	Hour		uint64
+	Day			uint64
+	Month		uint64
+	Weekday		uint64
+	AnyDay		bool		// Day of month is *
+	AnyWeekday	bool		// Day of week is *
+}
+
+type cronField struct {
+	Min		int
+	Max		int
+}
+
+var cronFields = []cronField{
+	{0, 59},	// Minute
+	{0, 23},	// Hour
+	{1, 31},	// Day of month
+	{1, 12},	// Month
+	{0, 6},		// Day of week, 0 is Sunday
+}
+
+var cronDescriptors = map[string]string{
+	"@hourly":	"0 * * * *",
+	"@daily":	"0 0 * * *",
+	"@weekly":	"0 0 * * 0",
+	"@monthly":	"0 0 1 * *",
+}
+
+func parseSchedule(spec string) (schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		interval, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, err
+		}
+		if interval <= 0 {
+			return nil, errors.Errorf("Invalid interval in %s", spec)
+		}
+		return everySchedule{Interval: interval}, nil
+	}
+	if expression, existed := cronDescriptors[spec]; existed {
+		spec = expression
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, errors.Errorf("Cron expression %s must have %d fields", spec, len(cronFields))
+	}
+
+	var bits [5]uint64
+	for i, field := range fields {
+		var err error
+		bits[i], err = parseCronField(field, cronFields[i])
+		if err != nil {
+			return nil, errors.Wrapf(err, "Invalid cron expression %s", spec)
+		}
+	}
+
+	return cronSchedule{
+		Minute:		bits[0],
+		Hour:		bits[1],
+		Day:		bits[2],
+		Month:		bits[3],
+		Weekday:	bits[4],
+		AnyDay:		fields[2] == "*",
+		AnyWeekday:	fields[4] == "*",
+	}, nil
+}
+
+func parseCronField(field string, bounds cronField) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if index := strings.Index(part, "/"); index >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[index+1:])
+			if err != nil || step <= 0 {
+				return 0, errors.Errorf("Invalid step in %s", part)
+			}
+			part = part[:index]
+		}
+
+		min, max := bounds.Min, bounds.Max
+		if part != "*" {
+			values := strings.SplitN(part, "-", 2)
+			var err error
+			min, err = strconv.Atoi(values[0])
+			if err != nil {
+				return 0, errors.Errorf("Invalid value %s", part)
+			}
+			max = min
+			if len(values) == 2 {
+				max, err = strconv.Atoi(values[1])
+				if err != nil {
+					return 0, errors.Errorf("Invalid value %s", part)
+				}
+			} else if step > 1 {
+				// a/n means from a to the end
+				max = bounds.Max
+			}
+		}
+		if min < bounds.Min || max > bounds.Max || min > max {
+			return 0, errors.Errorf("Value %s out of range %d-%d", part, bounds.Min, bounds.Max)
+		}
+
+		for value := min; value <= max; value += step {
+			bits |= 1 << uint(value)
+		}
+	}
+	return bits, nil
+}
+
+/*
+	Next minute matching the expression after now, within 5 years
+ */
+func (s cronSchedule) Next(now time.Time) time.Time {
+	next := now.Truncate(time.Minute).Add(time.Minute)
+	limit := now.AddDate(5, 0, 0)
+
+	for next.Before(limit) {
+		if s.Month & (1 << uint(next.Month())) == 0 {
+			next = time.Date(next.Year(), next.Month() + 1, 1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if !s.matchDay(next) {
+			next = time.Date(next.Year(), next.Month(), next.Day() + 1, 0, 0, 0, 0, next.Location())
+			continue
+		}
+		if s.Hour & (1 << uint(next.Hour())) == 0 {
+			next = time.Date(next.Year(), next.Month(), next.Day(), next.Hour() + 1, 0, 0, 0, next.Location())
+			continue
+		}
+		if s.Minute & (1 << uint(next.Minute())) == 0 {
+			next = next.Add(time.Minute)
+			continue
+		}
+		return next
+	}
+	return limit
+}
+
+/*
+	Like cron: when both day fields are restricted, either of them matches
+ */
+func (s cronSchedule) matchDay(t time.Time) bool {
+	day := s.Day & (1 << uint(t.Day())) != 0
+	weekday := s.Weekday & (1 << uint(t.Weekday())) != 0
+	if s.AnyDay || s.AnyWeekday {
+		return day && weekday
+	}
+	return day || weekday
+}
@@ -0,0 +1,381 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"g-tech.com/constant"
+	"g-tech.com/dto"
+	"g-tech.com/infrastructure/broker"
+	"g-tech.com/infrastructure/cache"
+	"g-tech.com/infrastructure/logger"
+	"g-tech.com/module/minigame/service"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+/*
+	A periodic job
+	+ Schedule: cron expression or @every, loaded from Scheduler.Jobs.<Name> (empty or "off" disables the job)
+	+ Concurrent: job is safe to run on several instances at once, no lock is taken
+	+ Quiet: frequent job, only manual runs and failures are recorded in history
+ */
+type job struct {
+	Name		string
+	Schedule	string
+	Concurrent	bool
+	Quiet		bool
+	Run			func(ctx context.Context) (string, error)
+	schedule	schedule
+}
+
+/*
+	Scheduler configuration
+	+ LockSeconds: lock of a running job, extended while it runs
+	+ PollSeconds: manual runs are picked up every PollSeconds
+ */
+type Config struct {
+	LockTimeout				time.Duration
+	PollInterval			time.Duration
+	HistoryRetentionDays	int
+	StockAlertThreshold		int
+	Instance				string
+}
+
+var mJobs []job
+var mConfig Config
+var mCache cache.CacheManager
+var mSchedulerService service.ISchedulerService
+var mRunning sync.WaitGroup
+var mStopping bool
+var mMutex sync.Mutex
+
+func Initialize(rbClient *broker.Client, dbContext *sql.DB, cache cache.CacheManager, timeout time.Duration){
+	redisService 		:= service.NewRedisService(dbContext, cache, timeout)
+	configService 		:= service.NewConfigService(dbContext, cache, redisService, timeout)
+	outboxService 		:= service.NewOutboxService(dbContext, redisService, timeout)
+	walletService 		:= service.NewWalletService(dbContext, cache, redisService, configService, outboxService, timeout)
+	fraudService 		:= service.NewFraudService(dbContext, cache, redisService, walletService, timeout)
+	campaignService 	:= service.NewCampaignService(dbContext, cache, timeout)
+	invitingService 	:= service.NewInvitingService(dbContext, cache, redisService, configService, fraudService, campaignService, walletService, outboxService, timeout)
+	vendorService 		:= service.NewMobileCardVendorService(dbContext, cache, redisService, timeout)
+	mSchedulerService 	= service.NewSchedulerService(dbContext, timeout)
+	mCache 				= cache
+
+	mConfig = loadConfig()
+
+	mJobs = []job{
+		{
+			Name:		constant.JobSettleReferralRewards,
+			Run: func(ctx context.Context) (string, error) {
+				settled, expired, err := invitingService.SettleReferralRewards(ctx)
+				return fmt.Sprintf("%d settled, %d expired", settled, expired), err
+			},
+		},
+		{
+			Name:		constant.JobExpireCredits,
+			Run: func(ctx context.Context) (string, error) {
+				expired, err := walletService.ExpireCredits(ctx)
+				return fmt.Sprintf("%d records expired", expired), err
+			},
+		},
+		{
+			Name:		constant.JobRelayOutbox,
+			Concurrent:	true,
+			Quiet:		true,
+			Run: func(ctx context.Context) (string, error) {
+				relayed, err := outboxService.Relay(ctx, rbClient)
+				return fmt.Sprintf("%d messages relayed", relayed), err
+			},
+		},
+		{
+			Name:		constant.JobPurgeOutbox,
+			Run: func(ctx context.Context) (string, error) {
+				purged, err := outboxService.PurgeProcessed(ctx)
+				return fmt.Sprintf("%d processed messages purged", purged), err
+			},
+		},
+		{
+			Name:		constant.JobWarmUpCache,
+			Run: func(ctx context.Context) (string, error) {
+				redisService.RefreshAllPrizeRedis()
+				redisService.RefreshAllVendorRedis()
+				return "Prizes and vendors cached", nil
+			},
+		},
+		{
+			Name:		constant.JobAlertMobileCardStock,
+			Run: func(ctx context.Context) (string, error) {
+				lowStock, err := vendorService.ListLowStockMobileCard(ctx, mConfig.StockAlertThreshold)
+				for _, card := range lowStock {
+					logger.Warn("Mobile card stock is low: %s %d, %d ready", card.Name, card.Value, card.Quantity)
+				}
+				return fmt.Sprintf("%d values below %d cards", len(lowStock), mConfig.StockAlertThreshold), err
+			},
+		},
+		{
+			Name:		constant.JobPurgeJobRuns,
+			Run: func(ctx context.Context) (string, error) {
+				purged, err := mSchedulerService.PurgeJobRuns(ctx, mConfig.HistoryRetentionDays)
+				return fmt.Sprintf("%d runs purged", purged), err
+			},
+		},
+	}
+
+	for i := range mJobs {
+		mJobs[i].Schedule = strings.TrimSpace(viper.GetString("Scheduler.Jobs." + mJobs[i].Name))
+		if mJobs[i].Schedule == "" || mJobs[i].Schedule == "off" {
+			logger.Info("Job %s is disabled", mJobs[i].Name)
+			continue
+		}
+
+		var err error
+		mJobs[i].schedule, err = parseSchedule(mJobs[i].Schedule)
+		if err != nil {
+			logger.Error("Job %s not scheduled: %s", mJobs[i].Name, err.Error())
+		}
+	}
+}
+
+func loadConfig() Config {
+	viper.SetDefault("Scheduler.LockSeconds", 60)
+	viper.SetDefault("Scheduler.PollSeconds", 5)
+	viper.SetDefault("Scheduler.HistoryRetentionDays", 30)
+	viper.SetDefault("Scheduler.StockAlertThreshold", 20)
+
+	viper.SetDefault("Scheduler.Jobs." + constant.JobSettleReferralRewards, "*/10 * * * *")
+	viper.SetDefault("Scheduler.Jobs." + constant.JobExpireCredits, "0 1 * * *")
+	viper.SetDefault("Scheduler.Jobs." + constant.JobRelayOutbox, "@every 1s")
+	viper.SetDefault("Scheduler.Jobs." + constant.JobPurgeOutbox, "0 2 * * *")
+	viper.SetDefault("Scheduler.Jobs." + constant.JobWarmUpCache, "*/30 * * * *")
+	viper.SetDefault("Scheduler.Jobs." + constant.JobAlertMobileCardStock, "0 * * * *")
+	viper.SetDefault("Scheduler.Jobs." + constant.JobPurgeJobRuns, "30 2 * * *")
+
+	hostname, _ := os.Hostname()
+	return Config{
+		LockTimeout:			time.Duration(viper.GetInt("Scheduler.LockSeconds")) * time.Second,
+		PollInterval:			time.Duration(viper.GetInt("Scheduler.PollSeconds")) * time.Second,
+		HistoryRetentionDays:	viper.GetInt("Scheduler.HistoryRetentionDays"),
+		StockAlertThreshold:	viper.GetInt("Scheduler.StockAlertThreshold"),
+		Instance:				fmt.Sprintf("%s-%d", hostname, os.Getpid()),
+	}
+}
+
+/*
+	Runs every job on its own goroutine and picks up manual runs until stop is closed
+	Jobs running then are completed before returning
+ */
+func Execute(stop <-chan struct{}) {
+	for _, j := range mJobs {
+		if j.schedule != nil {
+			go runSchedule(j, stop)
+		}
+	}
+	go pollManualRuns(stop)
+
+	<-stop
+	mMutex.Lock()
+	mStopping = true
+	mMutex.Unlock()
+	mRunning.Wait()
+}
+
+/*
+	Count a job as running, false once stopping
+ */
+func beginRun() bool {
+	mMutex.Lock()
+	defer mMutex.Unlock()
+	if mStopping {
+		return false
+	}
+	mRunning.Add(1)
+	return true
+}
+
+func runSchedule(j job, stop <-chan struct{}) {
+	for {
+		next := j.schedule.Next(time.Now())
+		select {
+		case <-stop:
+			return
+		case <-time.After(time.Until(next)):
+		}
+
+		run := dto.JobRun{
+			JobName:		j.Name,
+			TriggerType:	constant.JobTriggerSchedule,
+			Instance:		mConfig.Instance,
+		}
+		// Tick run by another instance (clocks of instances may differ), or Redis is unavailable
+		if !lockTick(j, next) {
+			continue
+		}
+		// Running on another instance, or Redis is unavailable
+		token, locked := lockJob(j)
+		if !locked {
+			continue
+		}
+		if !beginRun() {
+			unlockJob(j, token)
+			return
+		}
+		execute(j, run, token)
+	}
+}
+
+/*
+	Manual runs are queued in MySql, each one is claimed by a single instance
+ */
+func pollManualRuns(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(mConfig.PollInterval):
+		}
+
+		for {
+			run, isClaimed, err := mSchedulerService.ClaimQueuedJobRun(mConfig.Instance)
+			if err != nil || !isClaimed {
+				break
+			}
+			startManualRun(run)
+		}
+	}
+}
+
+func startManualRun(run dto.JobRun) {
+	logger.Info("Job %s triggered by %s", run.JobName, run.TriggeredBy)
+
+	for _, j := range mJobs {
+		if j.Name != run.JobName {
+			continue
+		}
+
+		token, locked := lockJob(j)
+		if !locked {
+			run.Status = constant.StatusJobRunSkipped
+			run.Error = "Job is running on another instance or Redis is unavailable"
+			_ = mSchedulerService.FinishJobRun(run)
+			return
+		}
+		if !beginRun() {
+			// Picked up again by the next instance
+			unlockJob(j, token)
+			run.Status = constant.StatusJobRunQueued
+			_ = mSchedulerService.FinishJobRun(run)
+			return
+		}
+		go execute(j, run, token)
+		return
+	}
+
+	run.Status = constant.StatusJobRunFailed
+	run.Error = "Job not found"
+	_ = mSchedulerService.FinishJobRun(run)
+}
+
+/*
+	Run job while keeping its lock, then record the run
+	Run is started (Running) already for manual runs
+ */
+func execute(j job, run dto.JobRun, token string) {
+	defer mRunning.Done()
+	defer unlockJob(j, token)
+
+	isRecorded := run.Id != "" || !j.Quiet
+	if run.Id == "" && isRecorded {
+		var err error
+		run, err = mSchedulerService.StartJobRun(run)
+		isRecorded = err == nil
+	}
+
+	stopExtending := keepLock(j, token)
+	result, err := j.Run(context.Background())
+	close(stopExtending)
+
+	run.Result = result
+	run.Status = constant.StatusJobRunSucceeded
+	if err != nil {
+		logger.Error("Job %s failed: %s", j.Name, err.Error())
+		run.Status = constant.StatusJobRunFailed
+		run.Error = err.Error()
+	} else if !j.Quiet {
+		logger.Info("Job %s: %s", j.Name, result)
+	}
+
+	// Failures of quiet jobs are recorded too
+	if !isRecorded && err != nil {
+		failed := run
+		run, err = mSchedulerService.StartJobRun(run)
+		run.Status, run.Result, run.Error = failed.Status, failed.Result, failed.Error
+		isRecorded = err == nil
+	}
+	if isRecorded {
+		_ = mSchedulerService.FinishJobRun(run)
+	}
+}
+
+/*
+	A scheduled tick runs once across instances
+	Its lock is never released, it expires after LockTimeout, once every instance has gone past the tick
+ */
+func lockTick(j job, tick time.Time) bool {
+	if j.Concurrent {
+		return true
+	}
+	token, err := mCache.Lock(fmt.Sprintf("%s%s_%d", constant.RedisPrefixKeyJobTick, j.Name, tick.Unix()), mConfig.LockTimeout)
+	return err == nil && token != ""
+}
+
+/*
+	A job runs on one instance at a time, its lock is released once the run is over
+ */
+func lockJob(j job) (string, bool) {
+	if j.Concurrent {
+		return "", true
+	}
+	token, err := mCache.Lock(constant.RedisPrefixKeyJobLock + j.Name, mConfig.LockTimeout)
+	return token, err == nil && token != ""
+}
+
+func unlockJob(j job, token string) {
+	if token == "" {
+		return
+	}
+	err := mCache.Unlock(constant.RedisPrefixKeyJobLock + j.Name, token)
+	if err != nil {
+		logger.Error(err.Error())
+	}
+}
+
+/*
+	Extend the lock of a long running job until stop is closed
+ */
+func keepLock(j job, token string) chan struct{} {
+	stop := make(chan struct{})
+	if token == "" {
+		return stop
+	}
+
+	go func() {
+		ticker := time.NewTicker(mConfig.LockTimeout / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				extended, err := mCache.ExtendLock(constant.RedisPrefixKeyJobLock + j.Name, token, mConfig.LockTimeout)
+				if err != nil || !extended {
+					logger.Warn("Job %s lost its lock", j.Name)
+				}
+			}
+		}
+	}()
+	return stop
+}